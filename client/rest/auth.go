@@ -5,6 +5,8 @@ type AuthType string
 const (
 	BearerToken AuthType = "bearer_token"
 	BasicAuth   AuthType = "basic_auth"
+	// OAuth2 client_credentials模式
+	ClientCredentialsAuth AuthType = "client_credentials"
 )
 
 type User struct {
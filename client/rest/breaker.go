@@ -0,0 +1,144 @@
+package rest
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrCircuitOpen = errors.New("circuit breaker is open")
+)
+
+type BreakerState string
+
+const (
+	BREAKER_STATE_CLOSED    BreakerState = "closed"
+	BREAKER_STATE_OPEN      BreakerState = "open"
+	BREAKER_STATE_HALF_OPEN BreakerState = "half_open"
+)
+
+// NewDefaultBreakerConfig 默认熔断配置: 连续失败5次熔断, 30秒后半开探测
+func NewDefaultBreakerConfig() *BreakerConfig {
+	return &BreakerConfig{
+		FailureThreshold: 5,
+		OpenTimeout:      30 * time.Second,
+		HalfOpenProbes:   1,
+	}
+}
+
+// BreakerConfig 熔断器配置, 按请求的Host维度熔断
+type BreakerConfig struct {
+	// 连续失败多少次后熔断
+	FailureThreshold int
+	// 熔断持续时间, 到期后进入半开状态
+	OpenTimeout time.Duration
+	// 半开状态下允许通过的探测请求数, 全部成功后关闭熔断
+	HalfOpenProbes int
+}
+
+func newBreakerGroup(conf *BreakerConfig) *breakerGroup {
+	return &breakerGroup{
+		conf:     conf,
+		breakers: map[string]*breaker{},
+	}
+}
+
+type breakerGroup struct {
+	conf     *BreakerConfig
+	breakers map[string]*breaker
+	lock     sync.Mutex
+}
+
+func (g *breakerGroup) get(host string) *breaker {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	b, ok := g.breakers[host]
+	if !ok {
+		b = &breaker{conf: g.conf, state: BREAKER_STATE_CLOSED}
+		g.breakers[host] = b
+	}
+	return b
+}
+
+type breaker struct {
+	conf *BreakerConfig
+
+	state     BreakerState
+	failures  int
+	openedAt  time.Time
+	probing   int
+	successes int
+	lock      sync.Mutex
+}
+
+func (b *breaker) State() BreakerState {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.state
+}
+
+// 判断请求是否允许通过
+func (b *breaker) allow() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	switch b.state {
+	case BREAKER_STATE_OPEN:
+		if time.Since(b.openedAt) < b.conf.OpenTimeout {
+			return ErrCircuitOpen
+		}
+		b.state = BREAKER_STATE_HALF_OPEN
+		b.probing = 0
+		b.successes = 0
+		fallthrough
+	case BREAKER_STATE_HALF_OPEN:
+		if b.probing >= b.probes() {
+			return ErrCircuitOpen
+		}
+		b.probing++
+	}
+	return nil
+}
+
+func (b *breaker) probes() int {
+	if b.conf.HalfOpenProbes < 1 {
+		return 1
+	}
+	return b.conf.HalfOpenProbes
+}
+
+// 记录请求结果
+func (b *breaker) done(success bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	switch b.state {
+	case BREAKER_STATE_HALF_OPEN:
+		if !success {
+			b.open()
+			return
+		}
+		b.successes++
+		if b.successes >= b.probes() {
+			b.state = BREAKER_STATE_CLOSED
+			b.failures = 0
+		}
+	case BREAKER_STATE_CLOSED:
+		if success {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.conf.FailureThreshold {
+			b.open()
+		}
+	}
+}
+
+func (b *breaker) open() {
+	b.state = BREAKER_STATE_OPEN
+	b.openedAt = time.Now()
+	b.failures = 0
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/infraboard/mcube/v2/flowcontrol/tokenbucket"
 	"github.com/infraboard/mcube/v2/ioc/config/log"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"go.opentelemetry.io/otel/propagation"
 	oteltrace "go.opentelemetry.io/otel/trace"
 )
@@ -19,10 +20,9 @@ import (
 // NewRESTClient creates a new RESTClient. This client performs generic REST functions
 // such as Get, Put, Post, and Delete on specified paths.
 func NewRESTClient() *RESTClient {
-	client := http.DefaultClient
-	// 保存Transport信息, 便于修改
-	transport := http.DefaultTransport.(*http.Transport)
-	client.Transport = transport
+	// 保存Transport信息, 便于修改, 复制一份避免修改全局的DefaultTransport
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	client := &http.Client{Transport: transport}
 	return &RESTClient{
 		rateLimiter: tokenbucket.NewBucketWithRate(10, 10),
 		client:      client,
@@ -43,6 +43,7 @@ func NewDefaultHeader() http.Header {
 type RESTClient struct {
 	rateLimiter flowcontrol.RateLimiter
 	transport   *http.Transport
+	rt          http.RoundTripper
 	client      *http.Client
 	cookies     []*http.Cookie
 	headers     http.Header
@@ -52,11 +53,16 @@ type RESTClient struct {
 	authType AuthType
 	user     *User
 	token    string
+	cc       *ClientCredentials
 
 	provider     oteltrace.TracerProvider
 	propagators  propagation.TextMapPropagator
 	tr           oteltrace.Tracer
 	expceptionFn ExceptionHandleFunc
+
+	retryPolicy *RetryPolicy
+	breakers    *breakerGroup
+	middlewares []Middleware
 }
 
 func (c *RESTClient) SetBaseURL(url string) *RESTClient {
@@ -69,6 +75,25 @@ func (c *RESTClient) SetTLSConfig(conf *tls.Config) *RESTClient {
 	return c
 }
 
+// SetTransport 替换底层的RoundTripper, 常用于测试时接入Mock或者录制回放,
+// 设置后SetTLSConfig不再生效
+func (c *RESTClient) SetTransport(rt http.RoundTripper) *RESTClient {
+	c.rt = rt
+	if c.tr != nil {
+		c.client.Transport = otelhttp.NewTransport(c.roundTripper())
+	} else {
+		c.client.Transport = c.roundTripper()
+	}
+	return c
+}
+
+func (c *RESTClient) roundTripper() http.RoundTripper {
+	if c.rt != nil {
+		return c.rt
+	}
+	return c.transport
+}
+
 func (c *RESTClient) WithExceptionHandleFunc(fn ExceptionHandleFunc) {
 	c.expceptionFn = fn
 }
@@ -101,6 +126,30 @@ func (c *RESTClient) SetRequestRate(rate float64, capacity int64) *RESTClient {
 	return c
 }
 
+// SetRetryPolicy 设置全局重试策略, 为nil时关闭重试
+func (c *RESTClient) SetRetryPolicy(p *RetryPolicy) *RESTClient {
+	c.retryPolicy = p
+	return c
+}
+
+// SetCircuitBreaker 开启按Host维度的熔断, 为nil时关闭熔断
+func (c *RESTClient) SetCircuitBreaker(conf *BreakerConfig) *RESTClient {
+	if conf == nil {
+		c.breakers = nil
+		return c
+	}
+	c.breakers = newBreakerGroup(conf)
+	return c
+}
+
+// BreakerState 查询某个Host当前的熔断状态
+func (c *RESTClient) BreakerState(host string) BreakerState {
+	if c.breakers == nil {
+		return BREAKER_STATE_CLOSED
+	}
+	return c.breakers.get(host).State()
+}
+
 func (c *RESTClient) SetHeader(key string, values ...string) *RESTClient {
 	if c.headers == nil {
 		c.headers = http.Header{}
@@ -174,6 +223,16 @@ func (c *RESTClient) SetBearerTokenAuth(token string) *RESTClient {
 	return c
 }
 
+// SetClientCredentialsAuth 使用OAuth2 client_credentials模式认证, Token会被缓存并在过期前自动刷新,
+// 请求返回401时会作废当前Token并使用新Token重试一次
+//
+//	client.SetClientCredentialsAuth(rest.NewClientCredentials("http://auth/oauth2/token", "client_id", "client_secret"))
+func (c *RESTClient) SetClientCredentialsAuth(cc *ClientCredentials) *RESTClient {
+	c.authType = ClientCredentialsAuth
+	c.cc = cc
+	return c
+}
+
 // Verb begins a request with a verb (GET, POST, PUT, DELETE).
 //
 // Example usage of RESTClient's request building interface:
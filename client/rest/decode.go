@@ -0,0 +1,121 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ArrayPath 流式解析时JSON数组所在的路径, 比如{"data": {"items": [...]}}的路径为"data", "items"
+func (r *Response) ArrayPath(keys ...string) *Response {
+	r.arrayPath = keys
+	return r
+}
+
+// Decode 流式解析JSON数组, 每个元素回调一次fn, 在fn中调用decode把当前元素解析到对象中,
+// 不会把整个Body读入内存, fn返回错误时停止解析
+//
+//	err := c.Get("/books/export").Do(ctx).ArrayPath("data", "items").Decode(func(decode func(v any) error) error {
+//		book := &Book{}
+//		if err := decode(book); err != nil {
+//			return err
+//		}
+//		return save(book)
+//	})
+func (r *Response) Decode(fn func(decode func(v any) error) error) error {
+	if r.err != nil || r.statusCode/100 != 2 {
+		if err := r.Error(); err != nil {
+			return err
+		}
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.body == nil || r.isRead {
+		return fmt.Errorf("response body has been read")
+	}
+	r.isRead = true
+	defer r.body.Close()
+
+	reader, err := r.bodyReader()
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(reader)
+	if err := seekArrayPath(dec, r.arrayPath); err != nil {
+		return err
+	}
+
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		return nil
+	}
+	if tok != json.Delim('[') {
+		return fmt.Errorf("expect json array, but got %v", tok)
+	}
+
+	for dec.More() {
+		decoded := false
+		err := fn(func(v any) error {
+			decoded = true
+			return dec.Decode(v)
+		})
+		if err != nil {
+			return err
+		}
+		// 回调未解析的元素直接跳过
+		if !decoded {
+			if err := dec.Decode(&json.RawMessage{}); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = dec.Token()
+	return err
+}
+
+// DecodeEach Decode的泛型版本, 每个元素解析为T后回调
+func DecodeEach[T any](r *Response, fn func(item *T) error) error {
+	return r.Decode(func(decode func(v any) error) error {
+		item := new(T)
+		if err := decode(item); err != nil {
+			return err
+		}
+		return fn(item)
+	})
+}
+
+// 定位到路径对应的值, 跳过路径之外的字段
+func seekArrayPath(dec *json.Decoder, path []string) error {
+	for _, key := range path {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if tok != json.Delim('{') {
+			return fmt.Errorf("expect json object at %s, but got %v", key, tok)
+		}
+
+		found := false
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			if tok == key {
+				found = true
+				break
+			}
+			if err := dec.Decode(&json.RawMessage{}); err != nil {
+				return err
+			}
+		}
+		if !found {
+			return fmt.Errorf("key %s not found", key)
+		}
+	}
+	return nil
+}
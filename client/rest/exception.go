@@ -1,9 +1,12 @@
 package rest
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
 
 	"github.com/infraboard/mcube/v2/client/negotiator"
+	"github.com/infraboard/mcube/v2/exception"
 )
 
 type ExceptionHandleFunc func(*Exception) error
@@ -16,9 +19,26 @@ func NewException(code int, body []byte) *Exception {
 }
 
 type Exception struct {
-	Code    int
-	Body    []byte
-	decoder negotiator.Decoder
+	Code        int
+	Body        []byte
+	ContentType string
+	decoder     negotiator.Decoder
+	cause       error
+}
+
+func (e *Exception) WithContentType(ct string) *Exception {
+	e.ContentType = ct
+	return e
+}
+
+// WithCause 记录导致异常的底层错误, 比如网络错误或者熔断
+func (e *Exception) WithCause(err error) *Exception {
+	e.cause = err
+	return e
+}
+
+func (e *Exception) Unwrap() error {
+	return e.cause
 }
 
 func (e *Exception) WithDecoder(decoder negotiator.Decoder) *Exception {
@@ -27,6 +47,9 @@ func (e *Exception) WithDecoder(decoder negotiator.Decoder) *Exception {
 }
 
 func (e *Exception) Error() string {
+	if e.cause != nil && len(e.Body) == 0 {
+		return fmt.Sprintf("code: %d, error: %s", e.Code, e.cause)
+	}
 	return fmt.Sprintf("code: %d, msg: %s", e.Code, string(e.Body))
 }
 
@@ -43,3 +66,31 @@ func (e *Exception) MustDecode(v any) {
 		panic(err)
 	}
 }
+
+// ApiException 把服务端返回的异常还原为ApiException, 支持application/problem+json与ApiException的JSON格式,
+// 都无法解析时使用HTTP状态码与Body构造
+func (e *Exception) ApiException() *exception.ApiException {
+	if e.ContentType == exception.MIME_PROBLEM_JSON {
+		if p, err := exception.NewProblemFromJson(e.Body); err == nil {
+			return p.ToApiException().WithCause(e)
+		}
+	}
+
+	if len(e.Body) > 0 && e.Body[0] == '{' {
+		ae := &exception.ApiException{}
+		if err := json.Unmarshal(e.Body, ae); err == nil && ae.Code != 0 {
+			if ae.HttpCode == 0 {
+				ae.HttpCode = e.Code
+			}
+			return ae.WithCause(e)
+		}
+	}
+
+	code := e.Code
+	if code <= 0 {
+		code = exception.CODE_UNKNOWN
+	}
+	return exception.NewApiException(code, http.StatusText(e.Code)).
+		WithMessage(string(e.Body)).
+		WithCause(e)
+}
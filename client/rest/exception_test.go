@@ -0,0 +1,30 @@
+package rest_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/infraboard/mcube/v2/client/rest"
+	"github.com/infraboard/mcube/v2/exception"
+	"github.com/infraboard/mcube/v2/http/response"
+)
+
+func TestProblemException(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response.Failed(w, exception.NewConflict("book exists").WithTraceID("trace01"), response.WithRequest(r))
+	}))
+	defer s.Close()
+
+	c := rest.NewRESTClient().SetBaseURL(s.URL)
+	c.SetHeader(rest.ACCEPT_HEADER, exception.MIME_PROBLEM_JSON)
+	err := c.Get("/books").Do(ctx).Error()
+	if err == nil || err.ContentType != exception.MIME_PROBLEM_JSON {
+		t.Fatalf("expect problem+json, got %v", err)
+	}
+
+	e := err.ApiException()
+	if !exception.IsConflictError(e) || e.Message != "book exists" || e.TraceID != "trace01" {
+		t.Fatalf("unexpected exception %s", e.ToJson())
+	}
+}
@@ -5,6 +5,7 @@ const (
 	CONTENT_TYPE_HEADER     = "Content-Type"
 	CONTENT_ENCODING_HEADER = "Content-Encoding"
 	AUTHORIZATION_HEADER    = "Authorization"
+	RETRY_AFTER_HEADER      = "Retry-After"
 )
 
 func HeaderFilterFlags(content string) string {
@@ -0,0 +1,39 @@
+package rest
+
+import (
+	"net/http"
+	"slices"
+)
+
+// Doer 发起HTTP请求, 与http.Client.Do签名一致
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// DoerFunc 函数形式的Doer
+type DoerFunc func(req *http.Request) (*http.Response, error)
+
+func (f DoerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Middleware 请求中间件, 包装下一个Doer
+type Middleware func(next Doer) Doer
+
+// Use 添加中间件, 先添加的中间件在外层, 最先处理请求, 最后处理响应
+//
+//	client.Use(middleware.RequestId(), middleware.MaxBodySize(10<<20))
+func (c *RESTClient) Use(mws ...Middleware) *RESTClient {
+	// 复制一份, 避免Group出来的客户端之间相互影响
+	c.middlewares = append(slices.Clone(c.middlewares), mws...)
+	return c
+}
+
+// 按添加顺序构造请求链, 最内层是真正发起请求的http.Client
+func (c *RESTClient) chain(core Doer) Doer {
+	d := core
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		d = c.middlewares[i](d)
+	}
+	return d
+}
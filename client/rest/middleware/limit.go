@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/infraboard/mcube/v2/client/rest"
+)
+
+var (
+	ErrBodyTooLarge = errors.New("response body too large")
+)
+
+// MaxBodySize 限制响应Body的大小, 超过限制时读取Body会返回ErrBodyTooLarge
+func MaxBodySize(limit int64) rest.Middleware {
+	return func(next rest.Doer) rest.Doer {
+		return rest.DoerFunc(func(req *http.Request) (*http.Response, error) {
+			resp, err := next.Do(req)
+			if err != nil {
+				return resp, err
+			}
+
+			if resp.ContentLength > limit {
+				resp.Body.Close()
+				return nil, ErrBodyTooLarge
+			}
+			resp.Body = &limitedBody{rc: resp.Body, remain: limit}
+			return resp, nil
+		})
+	}
+}
+
+type limitedBody struct {
+	rc     io.ReadCloser
+	remain int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remain < 0 {
+		return 0, ErrBodyTooLarge
+	}
+	// 多读一个字节用于判断是否超过限制
+	if int64(len(p)) > b.remain+1 {
+		p = p[:b.remain+1]
+	}
+	n, err := b.rc.Read(p)
+	b.remain -= int64(n)
+	if b.remain < 0 {
+		return n + int(b.remain), ErrBodyTooLarge
+	}
+	return n, err
+}
+
+func (b *limitedBody) Close() error {
+	return b.rc.Close()
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/infraboard/mcube/v2/client/rest"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func NewClientMetricCollector(appName string) *ClientMetricCollector {
+	labels := map[string]string{"app": appName}
+	return &ClientMetricCollector{
+		RequestTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "http_client_request_total",
+				Help:        "Total number of HTTP client requests",
+				ConstLabels: labels,
+			},
+			[]string{"method", "host", "status_code"},
+		),
+		RequestDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:        "http_client_request_duration_seconds",
+				Help:        "Histogram of the duration of HTTP client requests",
+				ConstLabels: labels,
+				Buckets:     prometheus.DefBuckets,
+			},
+			[]string{"method", "host"},
+		),
+	}
+}
+
+// ClientMetricCollector 客户端请求指标, 需要注册到prometheus
+//
+//	collector := middleware.NewClientMetricCollector(application.Get().AppName)
+//	prometheus.MustRegister(collector)
+//	client.Use(middleware.Metric(collector))
+type ClientMetricCollector struct {
+	RequestTotal    *prometheus.CounterVec
+	RequestDuration *prometheus.HistogramVec
+}
+
+func (c *ClientMetricCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.RequestTotal.Describe(ch)
+	c.RequestDuration.Describe(ch)
+}
+
+func (c *ClientMetricCollector) Collect(ch chan<- prometheus.Metric) {
+	c.RequestTotal.Collect(ch)
+	c.RequestDuration.Collect(ch)
+}
+
+// Metric 采集请求次数与耗时, 网络错误的status_code为error
+func Metric(c *ClientMetricCollector) rest.Middleware {
+	return func(next rest.Doer) rest.Doer {
+		return rest.DoerFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.Do(req)
+
+			code := "error"
+			if err == nil {
+				code = strconv.Itoa(resp.StatusCode)
+			}
+			c.RequestTotal.WithLabelValues(req.Method, req.URL.Host, code).Inc()
+			c.RequestDuration.WithLabelValues(req.Method, req.URL.Host).Observe(time.Since(start).Seconds())
+			return resp, err
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/infraboard/mcube/v2/client/rest"
+	"github.com/infraboard/mcube/v2/client/rest/middleware"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var (
+	ctx = context.Background()
+)
+
+func TestHMACSign(t *testing.T) {
+	signer := middleware.NewHMACSigner("key01", "secret01")
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := signer.Verify(r); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(err.Error()))
+			return
+		}
+	}))
+	defer s.Close()
+
+	c := rest.NewRESTClient().SetBaseURL(s.URL)
+	c.Use(middleware.HMACSign(signer))
+	err := c.Post("/books").Param("a", "b").Body(map[string]string{"title": "go"}).Do(ctx).Error()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := rest.NewRESTClient().SetBaseURL(s.URL)
+	other.Use(middleware.HMACSign(middleware.NewHMACSigner("key01", "wrong")))
+	err = other.Post("/books").Body(map[string]string{"title": "go"}).Do(ctx).Error()
+	if err == nil || err.Code != http.StatusUnauthorized {
+		t.Fatalf("expect 401, got %v", err)
+	}
+}
+
+func TestRequestId(t *testing.T) {
+	var rid string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rid = r.Header.Get(middleware.REQUEST_ID_HEADER)
+	}))
+	defer s.Close()
+
+	c := rest.NewRESTClient().SetBaseURL(s.URL).Use(middleware.RequestId())
+	if err := c.Get("/").Do(middleware.WithRequestId(ctx, "req01")).Error(); err != nil {
+		t.Fatal(err)
+	}
+	if rid != "req01" {
+		t.Fatalf("expect req01, got %s", rid)
+	}
+
+	if err := c.Get("/").Do(ctx).Error(); err != nil {
+		t.Fatal(err)
+	}
+	if rid == "" || rid == "req01" {
+		t.Fatalf("expect generated request id, got %s", rid)
+	}
+}
+
+func TestMaxBodySize(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("a", 1024)))
+	}))
+	defer s.Close()
+
+	c := rest.NewRESTClient().SetBaseURL(s.URL).Use(middleware.MaxBodySize(100))
+	err := c.Get("/").Do(ctx).Error()
+	if !errors.Is(err, middleware.ErrBodyTooLarge) {
+		t.Fatalf("expect body too large, got %v", err)
+	}
+
+	c = rest.NewRESTClient().SetBaseURL(s.URL).Use(middleware.MaxBodySize(2048))
+	if err := c.Get("/").Do(ctx).Error(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMetric(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer s.Close()
+
+	collector := middleware.NewClientMetricCollector("test")
+	c := rest.NewRESTClient().SetBaseURL(s.URL).Use(middleware.Metric(collector))
+	c.Get("/").Do(ctx).Error()
+	c.Get("/").Do(ctx).Error()
+
+	if n := testutil.CollectAndCount(collector.RequestTotal); n != 1 {
+		t.Fatalf("expect 1 series, got %d", n)
+	}
+	if v := testutil.ToFloat64(collector.RequestTotal); v != 2 {
+		t.Fatalf("expect 2 requests, got %v", v)
+	}
+}
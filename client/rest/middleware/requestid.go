@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/infraboard/mcube/v2/client/rest"
+)
+
+const (
+	REQUEST_ID_HEADER = "X-Request-Id"
+)
+
+type RequestIdCtxKey struct{}
+
+// WithRequestId 把请求ID放入上下文, 由RequestId中间件透传给下游
+func WithRequestId(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, RequestIdCtxKey{}, requestId)
+}
+
+// GetRequestIdFromCtx 从上下文中获取请求ID
+func GetRequestIdFromCtx(ctx context.Context) string {
+	if ctx != nil {
+		if v, ok := ctx.Value(RequestIdCtxKey{}).(string); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// RequestId 透传请求ID, 优先使用请求上已设置的Header, 其次使用上下文中的ID, 都没有时生成新的ID
+func RequestId() rest.Middleware {
+	return func(next rest.Doer) rest.Doer {
+		return rest.DoerFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get(REQUEST_ID_HEADER) == "" {
+				rid := GetRequestIdFromCtx(req.Context())
+				if rid == "" {
+					rid = uuid.NewString()
+				}
+				req.Header.Set(REQUEST_ID_HEADER, rid)
+			}
+			return next.Do(req)
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package rest
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	MIME_MULTIPART_FORM = "multipart/form-data"
+)
+
+// NewMultipartForm multipart/form-data请求体, 发送时边读文件边写入连接, 不会把文件整个读入内存
+//
+//	form := rest.NewMultipartForm().
+//		Field("name", "report").
+//		FilePath("file", "/tmp/report.csv")
+//	c.Post("/upload").Multipart(form).Do(ctx)
+func NewMultipartForm() *MultipartForm {
+	pr, pw := io.Pipe()
+	return &MultipartForm{
+		pr: pr,
+		pw: pw,
+		mw: multipart.NewWriter(pw),
+	}
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+type MultipartForm struct {
+	parts []*part
+
+	pr   *io.PipeReader
+	pw   *io.PipeWriter
+	mw   *multipart.Writer
+	once sync.Once
+}
+
+type part struct {
+	field       string
+	value       string
+	filename    string
+	contentType string
+	reader      io.Reader
+	path        string
+}
+
+// Field 普通表单字段
+func (f *MultipartForm) Field(name, value string) *MultipartForm {
+	f.parts = append(f.parts, &part{field: name, value: value})
+	return f
+}
+
+// File 文件字段, 数据从reader中读取, reader如果实现了io.Closer, 读取完成后会被关闭
+func (f *MultipartForm) File(field, filename string, reader io.Reader) *MultipartForm {
+	f.parts = append(f.parts, &part{field: field, filename: filename, reader: reader})
+	return f
+}
+
+// FileWithContentType 指定文件的Content-Type, 默认为application/octet-stream
+func (f *MultipartForm) FileWithContentType(field, filename, contentType string, reader io.Reader) *MultipartForm {
+	f.parts = append(f.parts, &part{field: field, filename: filename, contentType: contentType, reader: reader})
+	return f
+}
+
+// FilePath 本地文件字段, 发送时才打开文件
+func (f *MultipartForm) FilePath(field, path string) *MultipartForm {
+	f.parts = append(f.parts, &part{field: field, filename: filepath.Base(path), path: path})
+	return f
+}
+
+// ContentType 带boundary的Content-Type
+func (f *MultipartForm) ContentType() string {
+	return f.mw.FormDataContentType()
+}
+
+// 第一次读取时才开始写入, 避免请求未发送时写入协程泄露
+func (f *MultipartForm) Read(p []byte) (int, error) {
+	f.once.Do(func() {
+		go f.write()
+	})
+	return f.pr.Read(p)
+}
+
+// Close 请求结束时由http.Client调用, 中断未完成的写入
+func (f *MultipartForm) Close() error {
+	return f.pr.Close()
+}
+
+func (f *MultipartForm) write() {
+	for _, p := range f.parts {
+		if err := f.writePart(p); err != nil {
+			f.pw.CloseWithError(err)
+			return
+		}
+	}
+	f.pw.CloseWithError(f.mw.Close())
+}
+
+func (f *MultipartForm) writePart(p *part) error {
+	if p.reader == nil && p.path == "" {
+		return f.mw.WriteField(p.field, p.value)
+	}
+
+	reader := p.reader
+	if p.path != "" {
+		file, err := os.Open(p.path)
+		if err != nil {
+			return err
+		}
+		reader = file
+	}
+	if c, ok := reader.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	contentType := p.contentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+		quoteEscaper.Replace(p.field), quoteEscaper.Replace(p.filename)))
+	h.Set(CONTENT_TYPE_HEADER, contentType)
+	w, err := f.mw.CreatePart(h)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, reader)
+	return err
+}
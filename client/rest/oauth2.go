@@ -0,0 +1,145 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NewClientCredentials OAuth2 client_credentials模式获取Token
+func NewClientCredentials(tokenURL, clientId, clientSecret string) *ClientCredentials {
+	return &ClientCredentials{
+		TokenURL:     tokenURL,
+		ClientId:     clientId,
+		ClientSecret: clientSecret,
+		ExpiryDelta:  30 * time.Second,
+		client:       &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// ClientCredentials 从Token端点获取并缓存Token, 可并发使用
+type ClientCredentials struct {
+	TokenURL     string
+	ClientId     string
+	ClientSecret string
+	Scopes       []string
+	// Token过期前多久提前刷新
+	ExpiryDelta time.Duration
+	// 客户端凭证是否通过表单参数传递, 默认使用Basic认证
+	AuthInParams bool
+
+	client *http.Client
+	token  *Token
+	lock   sync.Mutex
+}
+
+// Token Token端点的返回
+type Token struct {
+	AccessToken string    `json:"access_token"`
+	TokenType   string    `json:"token_type"`
+	ExpiresIn   int64     `json:"expires_in"`
+	Scope       string    `json:"scope"`
+	ExpiredAt   time.Time `json:"-"`
+}
+
+// Valid Token存在且在delta时间内不会过期
+func (t *Token) Valid(delta time.Duration) bool {
+	if t == nil || t.AccessToken == "" {
+		return false
+	}
+	if t.ExpiredAt.IsZero() {
+		return true
+	}
+	return time.Now().Add(delta).Before(t.ExpiredAt)
+}
+
+func (c *ClientCredentials) SetScopes(scopes ...string) *ClientCredentials {
+	c.Scopes = scopes
+	return c
+}
+
+func (c *ClientCredentials) SetHTTPClient(client *http.Client) *ClientCredentials {
+	c.client = client
+	return c
+}
+
+// Token 获取有效的Token, 缓存的Token即将过期时重新获取
+func (c *ClientCredentials) Token(ctx context.Context) (*Token, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.token.Valid(c.ExpiryDelta) {
+		return c.token, nil
+	}
+
+	tk, err := c.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.token = tk
+	return tk, nil
+}
+
+// Invalidate 作废缓存的Token, 仅当缓存的仍是该Token时才作废, 避免作废其他请求刚刷新的Token
+func (c *ClientCredentials) Invalidate(accessToken string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.token != nil && c.token.AccessToken == accessToken {
+		c.token = nil
+	}
+}
+
+func (c *ClientCredentials) fetch(ctx context.Context) (*Token, error) {
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	if len(c.Scopes) > 0 {
+		form.Set("scope", strings.Join(c.Scopes, " "))
+	}
+	if c.AuthInParams {
+		form.Set("client_id", c.ClientId)
+		form.Set("client_secret", c.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(CONTENT_TYPE_HEADER, "application/x-www-form-urlencoded")
+	req.Header.Set(ACCEPT_HEADER, "application/json")
+	if !c.AuthInParams {
+		req.SetBasicAuth(url.QueryEscape(c.ClientId), url.QueryEscape(c.ClientSecret))
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, NewException(resp.StatusCode, body)
+	}
+
+	tk := &Token{}
+	if err := json.Unmarshal(body, tk); err != nil {
+		return nil, fmt.Errorf("decode token response error, %s", err)
+	}
+	if tk.AccessToken == "" {
+		return nil, fmt.Errorf("token response has no access_token")
+	}
+	if tk.ExpiresIn > 0 {
+		tk.ExpiredAt = time.Now().Add(time.Duration(tk.ExpiresIn) * time.Second)
+	}
+	return tk, nil
+}
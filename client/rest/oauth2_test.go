@@ -0,0 +1,123 @@
+package rest_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/infraboard/mcube/v2/client/rest"
+)
+
+func TestClientCredentialsAuth(t *testing.T) {
+	var issued int32
+	var revoked atomic.Value
+	revoked.Store("")
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth2/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if id != "client01" || secret != "secret01" || r.FormValue("grant_type") != "client_credentials" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		n := atomic.AddInt32(&issued, 1)
+		w.Header().Set(rest.CONTENT_TYPE_HEADER, "application/json")
+		fmt.Fprintf(w, `{"access_token":"token%d","token_type":"Bearer","expires_in":3600}`, n)
+	})
+	mux.HandleFunc("/api", func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get(rest.AUTHORIZATION_HEADER)
+		if auth == "" || auth == "Bearer "+revoked.Load().(string) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method == http.MethodPost {
+			if b, _ := io.ReadAll(r.Body); string(b) != "payload" {
+				t.Errorf("unexpected body %q", b)
+			}
+		}
+	})
+	s := httptest.NewServer(mux)
+	defer s.Close()
+
+	c := rest.NewRESTClient().SetBaseURL(s.URL)
+	c.SetClientCredentialsAuth(rest.NewClientCredentials(s.URL+"/oauth2/token", "client01", "secret01"))
+
+	// 并发请求只获取一次Token
+	wg := sync.WaitGroup{}
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.Get("/api").Do(ctx).Error(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if issued != 1 {
+		t.Fatalf("expect 1 token issued, got %d", issued)
+	}
+
+	// Token被服务端作废后刷新Token重试
+	revoked.Store("token1")
+	if err := c.Get("/api").Do(ctx).Error(); err != nil {
+		t.Fatal(err)
+	}
+	if issued != 2 {
+		t.Fatalf("expect token refreshed, got %d issued", issued)
+	}
+
+	// 不能重新读取的Body不重试, 下次请求使用新Token
+	revoked.Store("token2")
+	body := io.MultiReader(strings.NewReader("payload"))
+	if err := c.Post("/api").BodyReader(body, -1).Do(ctx).Error(); err == nil {
+		t.Fatal("expect unauthorized for non-rewindable body")
+	}
+	if err := c.Post("/api").BodyReader(strings.NewReader("payload"), 7).Do(ctx).Error(); err != nil {
+		t.Fatal(err)
+	}
+	if issued != 3 {
+		t.Fatalf("expect token refreshed, got %d issued", issued)
+	}
+}
+
+func TestClientCredentialsBareResponse(t *testing.T) {
+	var issued int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth2/token", func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&issued, 1)
+		w.Header().Set(rest.CONTENT_TYPE_HEADER, "application/json")
+		fmt.Fprintf(w, `{"access_token":"token%d","token_type":"Bearer","expires_in":3600}`, n)
+	})
+	mux.HandleFunc("/api", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(rest.AUTHORIZATION_HEADER) == "Bearer token1" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	})
+	s := httptest.NewServer(mux)
+	defer s.Close()
+
+	c := rest.NewRESTClient().SetBaseURL(s.URL)
+	c.SetClientCredentialsAuth(rest.NewClientCredentials(s.URL+"/oauth2/token", "client01", "secret01"))
+	// 中间件返回的Response没有Request
+	c.Use(func(next rest.Doer) rest.Doer {
+		return rest.DoerFunc(func(req *http.Request) (*http.Response, error) {
+			resp, err := next.Do(req)
+			if err != nil {
+				return nil, err
+			}
+			return &http.Response{StatusCode: resp.StatusCode, Header: resp.Header, Body: resp.Body}, nil
+		})
+	})
+
+	if err := c.Get("/api").Do(ctx).Error(); err != nil {
+		t.Fatal(err)
+	}
+	if issued != 2 {
+		t.Fatalf("expect token refreshed, got %d issued", issued)
+	}
+}
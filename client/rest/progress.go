@@ -0,0 +1,35 @@
+package rest
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// ProgressFunc 传输进度回调, total未知时为-1
+type ProgressFunc func(transferred, total int64)
+
+func newProgressReader(r io.Reader, total int64, fn ProgressFunc) *progressReader {
+	return &progressReader{r: r, total: total, fn: fn}
+}
+
+type progressReader struct {
+	r           io.Reader
+	total       int64
+	transferred int64
+	fn          ProgressFunc
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.fn(atomic.AddInt64(&p.transferred, int64(n)), p.total)
+	}
+	return n, err
+}
+
+func (p *progressReader) Close() error {
+	if c, ok := p.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package rest
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptrace"
@@ -18,6 +19,7 @@ import (
 	"github.com/infraboard/mcube/v2/ioc/config/log"
 	"github.com/rs/zerolog"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/httptrace/otelhttptrace"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -28,11 +30,14 @@ func NewRequest(c *RESTClient) *Request {
 		rateLimiter: c.rateLimiter,
 		timeout:     c.client.Timeout,
 		basePath:    c.baseURL,
-		headers:     c.headers,
+		headers:     c.headers.Clone(),
 		cookies:     c.cookies,
+		bodySize:    -1,
 		authType:    c.authType,
 		user:        c.user,
 		token:       c.token,
+		cc:          c.cc,
+		retryPolicy: c.retryPolicy,
 		log:         log.Sub("http.request"),
 	}
 
@@ -48,10 +53,12 @@ type Request struct {
 	log         *zerolog.Logger
 	rateLimiter flowcontrol.RateLimiter
 	timeout     time.Duration
+	retryPolicy *RetryPolicy
 
 	authType AuthType
 	user     *User
 	token    string
+	cc       *ClientCredentials
 
 	// generic components accessible via method setters
 	method   string
@@ -64,6 +71,11 @@ type Request struct {
 	headers  http.Header
 	params   url.Values
 	body     io.Reader
+	// Body的长度, -1表示未知
+	bodySize int64
+
+	uploadProgress   ProgressFunc
+	downloadProgress ProgressFunc
 
 	err error
 }
@@ -80,6 +92,12 @@ func (c *Request) SetRequestRate(rate float64, capacity int64) *Request {
 	return c
 }
 
+// Retry 设置该请求的重试策略, 覆盖客户端的全局策略, 为nil时不重试
+func (r *Request) Retry(p *RetryPolicy) *Request {
+	r.retryPolicy = p
+	return r
+}
+
 // Prefix adds segments to the relative beginning to the request path. These
 // items will be placed before the optional Namespace, Resource, or Name sections.
 // Setting AbsPath will clear any previously set Prefix segments
@@ -229,6 +247,42 @@ func (r *Request) Body(v any) *Request {
 	}
 
 	r.body = bytes.NewReader(b)
+	r.bodySize = int64(len(b))
+	return r
+}
+
+// BodyReader 直接使用reader作为请求体, 不经过序列化, size为-1表示长度未知(使用chunked传输)
+//
+// 开启重试时, 不支持Seek的reader会被先读入内存
+func (r *Request) BodyReader(reader io.Reader, size int64) *Request {
+	if r.err != nil {
+		return r
+	}
+	r.body = reader
+	r.bodySize = size
+	return r
+}
+
+// Multipart 使用multipart/form-data发送表单与文件
+func (r *Request) Multipart(form *MultipartForm) *Request {
+	if r.err != nil {
+		return r
+	}
+	r.Header(CONTENT_TYPE_HEADER, form.ContentType())
+	r.body = form
+	r.bodySize = -1
+	return r
+}
+
+// OnUploadProgress 请求体上传进度回调
+func (r *Request) OnUploadProgress(fn ProgressFunc) *Request {
+	r.uploadProgress = fn
+	return r
+}
+
+// OnDownloadProgress 响应体下载进度回调
+func (r *Request) OnDownloadProgress(fn ProgressFunc) *Request {
+	r.downloadProgress = fn
 	return r
 }
 
@@ -242,17 +296,98 @@ func (r *Request) Do(ctx context.Context) *Response {
 		ctx = httptrace.WithClientTrace(ctx, otelhttptrace.NewClientTrace(ctx))
 	}
 
+	// 请求响应对象
+	resp := NewResponse(r.c)
+	if r.err != nil {
+		resp.err = r.err
+		return resp
+	}
+
+	// 重试次数
+	maxAttempts := 1
+	if p := r.retryPolicy; p != nil && p.MaxAttempts > 1 && p.allowMethod(r.method) {
+		maxAttempts = p.MaxAttempts
+		if err := r.bufferBody(); err != nil {
+			resp.err = err
+			return resp
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		raw, err := r.do(ctx)
+		retry := attempt < maxAttempts && r.shouldRetry(ctx, raw, err)
+		r.traceAttempt(span, attempt, raw, err)
+		if !retry {
+			if err != nil {
+				resp.err = err
+				return resp
+			}
+			// 设置返回
+			resp.withStatusCode(raw.StatusCode)
+			resp.withHeader(raw.Header)
+			if r.downloadProgress != nil {
+				resp.withBody(newProgressReader(raw.Body, raw.ContentLength, r.downloadProgress))
+			} else {
+				resp.withBody(raw.Body)
+			}
+			return resp
+		}
+
+		var header http.Header
+		if raw != nil {
+			header = raw.Header
+			io.Copy(io.Discard, raw.Body)
+			raw.Body.Close()
+		}
+
+		wait := r.retryPolicy.waitTime(attempt, header)
+		r.log.Debug().Msgf("[%s] %s attempt %d failed, retry after %s", r.method, r.url(), attempt, wait)
+		if err := sleepContext(ctx, wait); err != nil {
+			resp.err = err
+			return resp
+		}
+	}
+}
+
+// 发起一次请求, Token失效时刷新Token后重试一次,
+// Body不支持Seek时(未开启重试的BodyReader与Multipart)无法重新发送, 只作废Token, 由下次请求使用新Token
+func (r *Request) do(ctx context.Context) (*http.Response, error) {
+	req, raw, err := r.attempt(ctx)
+	if err != nil || raw.StatusCode != http.StatusUnauthorized || r.authType != ClientCredentialsAuth {
+		return raw, err
+	}
+
+	// 中间件与Transport返回的Response不一定带有Request, 作废本次发送的Token
+	r.cc.Invalidate(strings.TrimPrefix(req.Header.Get(AUTHORIZATION_HEADER), "Bearer "))
+	if !r.rewindable() {
+		return raw, nil
+	}
+	io.Copy(io.Discard, raw.Body)
+	raw.Body.Close()
+	_, raw, err = r.attempt(ctx)
+	return raw, err
+}
+
+// 构造并发送一次请求, 返回发送的请求
+func (r *Request) attempt(ctx context.Context) (*http.Request, *http.Response, error) {
 	// 请求速率控制
 	r.rateLimiter.Wait(1)
 
-	// 请求响应对象
-	resp := NewResponse(r.c)
+	if err := r.rewindBody(); err != nil {
+		return nil, nil, err
+	}
 
 	// 准备请求
-	req, err := http.NewRequestWithContext(ctx, r.method, r.url(), r.body)
+	var body io.Reader = r.body
+	if body != nil && r.uploadProgress != nil {
+		body = newProgressReader(body, r.bodySize, r.uploadProgress)
+	}
+	req, err := http.NewRequestWithContext(ctx, r.method, r.url(), body)
 	if err != nil {
-		resp.err = err
-		return resp
+		return nil, nil, err
+	}
+	if body != nil && r.bodySize >= 0 {
+		req.ContentLength = r.bodySize
 	}
 	req.URL.RawQuery = r.params.Encode()
 
@@ -264,7 +399,9 @@ func (r *Request) Do(ctx context.Context) *Response {
 	}
 
 	// 补充认证
-	r.buildAuth(req)
+	if err := r.buildAuth(req); err != nil {
+		return nil, nil, err
+	}
 
 	// 补充cookie
 	for i := range r.cookies {
@@ -274,18 +411,101 @@ func (r *Request) Do(ctx context.Context) *Response {
 	// debug信息
 	r.debug(req)
 
-	// 发起请求
+	// 经过中间件后发起请求
+	raw, err := r.c.chain(DoerFunc(r.send)).Do(req)
+	return req, raw, err
+}
+
+// 熔断检查后通过http.Client发起请求
+func (r *Request) send(req *http.Request) (*http.Response, error) {
+	var b *breaker
+	if r.c.breakers != nil {
+		b = r.c.breakers.get(req.URL.Host)
+		if err := b.allow(); err != nil {
+			return nil, err
+		}
+	}
+
 	raw, err := r.c.client.Do(req)
+	if b != nil {
+		b.done(err == nil && raw.StatusCode < http.StatusInternalServerError)
+	}
+	return raw, err
+}
+
+func (r *Request) shouldRetry(ctx context.Context, raw *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
 	if err != nil {
-		resp.err = err
-		return resp
+		return !errors.Is(err, ErrCircuitOpen)
 	}
+	return r.retryPolicy.isRetryableStatus(raw.StatusCode)
+}
 
-	// 设置返回
-	resp.withStatusCode(raw.StatusCode)
-	resp.withHeader(raw.Header)
-	resp.withBody(raw.Body)
-	return resp
+// 把请求的尝试记录到Trace中
+func (r *Request) traceAttempt(span trace.Span, attempt int, raw *http.Response, err error) {
+	if span == nil {
+		return
+	}
+
+	attrs := []attribute.KeyValue{attribute.Int("http.attempt", attempt)}
+	if raw != nil {
+		attrs = append(attrs, attribute.Int("http.status_code", raw.StatusCode))
+	}
+	if err != nil {
+		attrs = append(attrs, attribute.String("error", err.Error()))
+	}
+	span.AddEvent("http.attempt", trace.WithAttributes(attrs...))
+	span.SetAttributes(attribute.Int("http.attempts", attempt))
+}
+
+// 重试时需要重复读取Body, 不支持Seek的Body需要先读取到内存
+func (r *Request) bufferBody() error {
+	if r.body == nil {
+		return nil
+	}
+	if _, ok := r.body.(io.Seeker); ok {
+		return nil
+	}
+
+	b, err := io.ReadAll(r.body)
+	if err != nil {
+		return err
+	}
+	r.body = bytes.NewReader(b)
+	r.bodySize = int64(len(b))
+	return nil
+}
+
+func (r *Request) rewindable() bool {
+	if r.body == nil {
+		return true
+	}
+	_, ok := r.body.(io.Seeker)
+	return ok
+}
+
+func (r *Request) rewindBody() error {
+	if s, ok := r.body.(io.Seeker); ok {
+		_, err := s.Seek(0, io.SeekStart)
+		return err
+	}
+	return nil
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
 }
 
 func (r *Request) debug(req *http.Request) {
@@ -296,11 +516,18 @@ func (r *Request) debug(req *http.Request) {
 	}
 }
 
-func (r *Request) buildAuth(req *http.Request) {
+func (r *Request) buildAuth(req *http.Request) error {
 	switch r.authType {
 	case BasicAuth:
 		req.SetBasicAuth(r.user.Username, r.user.Password)
 	case BearerToken:
 		req.Header.Set(AUTHORIZATION_HEADER, "Bearer "+r.token)
+	case ClientCredentialsAuth:
+		tk, err := r.cc.Token(req.Context())
+		if err != nil {
+			return err
+		}
+		req.Header.Set(AUTHORIZATION_HEADER, "Bearer "+tk.AccessToken)
 	}
+	return nil
 }
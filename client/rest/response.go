@@ -1,6 +1,7 @@
 package rest
 
 import (
+	"fmt"
 	"io"
 	"net/http"
 	"strings"
@@ -28,6 +29,7 @@ type Response struct {
 	bf          []byte
 	contentType string
 	isRead      bool
+	arrayPath   []string
 
 	expceptionFn ExceptionHandleFunc
 	log          *zerolog.Logger
@@ -57,18 +59,10 @@ func (r *Response) readBody() {
 	r.isRead = true
 	defer r.body.Close()
 
-	var bodyReader io.Reader = r.body
-
-	// 解压缩
-	et := HeaderFilterFlags(r.headers.Get(CONTENT_ENCODING_HEADER))
-	if et != "" {
-		cp := compressor.GetCompressor(et)
-		dp, err := cp.Decompress(r.body)
-		if err != nil {
-			r.err = err
-			return
-		}
-		bodyReader = dp
+	bodyReader, err := r.bodyReader()
+	if err != nil {
+		r.err = err
+		return
 	}
 
 	// 读取数据
@@ -82,6 +76,19 @@ func (r *Response) readBody() {
 	r.bf = body
 }
 
+// 解压缩后的Body
+func (r *Response) bodyReader() (io.Reader, error) {
+	et := HeaderFilterFlags(r.headers.Get(CONTENT_ENCODING_HEADER))
+	if et == "" {
+		return r.body, nil
+	}
+	cp := compressor.GetCompressor(et)
+	if cp == nil {
+		return nil, fmt.Errorf("unsupported content encoding %s", et)
+	}
+	return cp.Decompress(r.body)
+}
+
 func (r *Response) debug(body []byte) {
 	r.log.Debug().Msgf("Status Code: %d", r.statusCode)
 
@@ -142,12 +149,13 @@ func (r *Response) Into(v any) error {
 // 不处理返回, 直接判断请求是否正常
 func (r *Response) Error() *Exception {
 	if r.readBody(); r.err != nil {
-		return NewException(-1, r.bf)
+		return NewException(-1, r.bf).WithCause(r.err)
 	}
 
 	// 判断status code
 	if r.statusCode/100 != 2 {
-		return NewException(r.statusCode, r.bf)
+		return NewException(r.statusCode, r.bf).
+			WithContentType(HeaderFilterFlags(r.headers.Get(CONTENT_TYPE_HEADER)))
 	}
 
 	return nil
@@ -0,0 +1,296 @@
+package resttest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+type MODE string
+
+const (
+	// 录制: 请求真实服务并保存到磁带文件
+	MODE_RECORD MODE = "record"
+	// 回放: 只从磁带文件中读取响应, 不访问网络
+	MODE_REPLAY MODE = "replay"
+	// 磁带文件存在时回放, 不存在时录制
+	MODE_AUTO MODE = "auto"
+)
+
+var (
+	ErrInteractionNotFound = errors.New("no recorded interaction matched")
+)
+
+// 默认不录制的敏感Header
+var DefaultFilterHeaders = []string{"Authorization", "Cookie", "Set-Cookie"}
+
+// Cassette 磁带文件, 保存录制的请求与响应
+type Cassette struct {
+	Interactions []*Interaction `yaml:"interactions"`
+}
+
+type Interaction struct {
+	Request  *RecordedRequest  `yaml:"request"`
+	Response *RecordedResponse `yaml:"response"`
+}
+
+type RecordedRequest struct {
+	Method  string              `yaml:"method"`
+	URL     string              `yaml:"url"`
+	Headers map[string][]string `yaml:"headers,omitempty"`
+	Body    string              `yaml:"body,omitempty"`
+}
+
+type RecordedResponse struct {
+	StatusCode int                 `yaml:"status_code"`
+	Headers    map[string][]string `yaml:"headers,omitempty"`
+	Body       string              `yaml:"body,omitempty"`
+}
+
+// LoadCassette 读取磁带文件
+func LoadCassette(filePath string) (*Cassette, error) {
+	b, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	c := &Cassette{}
+	if err := yaml.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("load cassette %s error, %s", filePath, err)
+	}
+	return c, nil
+}
+
+// Save 保存磁带文件
+func (c *Cassette) Save(filePath string) error {
+	b, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, b, 0644)
+}
+
+// NewRecorder 录制经过next的所有请求, next为nil时使用http.DefaultTransport
+func NewRecorder(filePath string, next http.RoundTripper) *Recorder {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Recorder{
+		path:          filePath,
+		next:          next,
+		cassette:      &Cassette{},
+		FilterHeaders: DefaultFilterHeaders,
+	}
+}
+
+type Recorder struct {
+	// 不录制的Header
+	FilterHeaders []string
+
+	path     string
+	next     http.RoundTripper
+	cassette *Cassette
+	lock     sync.Mutex
+}
+
+// RoundTrip 读取请求体后使用请求的副本发送, 不修改调用方的请求
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	out := req.Clone(req.Context())
+	if reqBody != nil {
+		out.Body = io.NopCloser(bytes.NewReader(reqBody))
+		out.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(reqBody)), nil
+		}
+	}
+	resp, err := r.next.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+
+	// 录制解压后的数据, 便于阅读与修改
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		gr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{gr, resp.Body}
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+		resp.Uncompressed = true
+	}
+	respBody, err := readAndRestore(&resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, &Interaction{
+		Request: &RecordedRequest{
+			Method:  req.Method,
+			URL:     req.URL.String(),
+			Headers: r.filter(req.Header),
+			Body:    string(reqBody),
+		},
+		Response: &RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Headers:    r.filter(resp.Header),
+			Body:       string(respBody),
+		},
+	})
+	return resp, nil
+}
+
+// Save 把录制的内容写入磁带文件
+func (r *Recorder) Save() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.cassette.Save(r.path)
+}
+
+func (r *Recorder) filter(h http.Header) map[string][]string {
+	m := map[string][]string{}
+	for k, v := range h {
+		if slices.Contains(r.FilterHeaders, http.CanonicalHeaderKey(k)) {
+			continue
+		}
+		m[k] = v
+	}
+	return m
+}
+
+// NewReplayer 从磁带文件回放响应
+func NewReplayer(filePath string) (*Replayer, error) {
+	c, err := LoadCassette(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return &Replayer{
+		cassette: c,
+		used:     make([]bool, len(c.Interactions)),
+	}, nil
+}
+
+// Replayer 按方法, URL与请求体匹配录制的请求, 优先使用未回放过的记录,
+// 都回放过后重复使用最后一条匹配的记录
+type Replayer struct {
+	cassette *Cassette
+	used     []bool
+	lock     sync.Mutex
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	matched := -1
+	for i, it := range r.cassette.Interactions {
+		if it.Request.Method != req.Method || it.Request.URL != req.URL.String() || it.Request.Body != string(body) {
+			continue
+		}
+		matched = i
+		if !r.used[i] {
+			break
+		}
+	}
+	if matched < 0 {
+		return nil, fmt.Errorf("%w: %s %s", ErrInteractionNotFound, req.Method, req.URL)
+	}
+	r.used[matched] = true
+
+	recorded := r.cassette.Interactions[matched].Response
+	header := http.Header{}
+	for k, v := range recorded.Headers {
+		header[k] = v
+	}
+	header.Set("Content-Length", strconv.Itoa(len(recorded.Body)))
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+		StatusCode:    recorded.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader([]byte(recorded.Body))),
+		ContentLength: int64(len(recorded.Body)),
+		Request:       req,
+	}, nil
+}
+
+// NewCassetteTransport 根据模式返回录制或者回放的Transport, 录制模式下测试结束时需要调用save保存
+//
+//	rt, save, err := resttest.NewCassetteTransport("testdata/books.yaml", resttest.MODE_AUTO)
+//	defer save()
+//	c := rest.NewRESTClient().SetTransport(rt)
+func NewCassetteTransport(filePath string, mode MODE) (http.RoundTripper, func() error, error) {
+	if mode == MODE_AUTO {
+		if _, err := os.Stat(filePath); err == nil {
+			mode = MODE_REPLAY
+		} else {
+			mode = MODE_RECORD
+		}
+	}
+
+	switch mode {
+	case MODE_REPLAY:
+		rp, err := NewReplayer(filePath)
+		if err != nil {
+			return nil, nil, err
+		}
+		return rp, func() error { return nil }, nil
+	case MODE_RECORD:
+		rc := NewRecorder(filePath, nil)
+		return rc, rc.Save, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown cassette mode %s", mode)
+	}
+}
+
+// 读取并关闭请求体, RoundTripper需要关闭请求体
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	defer req.Body.Close()
+	return io.ReadAll(req.Body)
+}
+
+// 读取Body后放回一份可重复读取的副本
+func readAndRestore(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+	b, err := io.ReadAll(*body)
+	if err != nil {
+		return nil, err
+	}
+	(*body).Close()
+	*body = io.NopCloser(bytes.NewReader(b))
+	return b, nil
+}
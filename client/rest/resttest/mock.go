@@ -0,0 +1,250 @@
+package resttest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// NewMockServer 声明式的Mock服务, 按添加顺序匹配请求
+//
+//	m := resttest.NewMockServer()
+//	m.On(http.MethodGet, "/api/v1/books").Query("page", "1").Reply(http.StatusOK).JSON(books)
+//	c := rest.NewRESTClient().SetBaseURL("http://mock").SetTransport(m.Transport())
+func NewMockServer() *MockServer {
+	return &MockServer{}
+}
+
+type MockServer struct {
+	stubs []*Stub
+	lock  sync.Mutex
+}
+
+// On 添加一个请求匹配规则, path支持path.Match通配符
+func (m *MockServer) On(method, urlPath string) *Stub {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	s := &Stub{
+		method:  method,
+		path:    urlPath,
+		query:   map[string]string{},
+		headers: map[string]string{},
+		reply:   &Reply{status: http.StatusOK, headers: http.Header{}},
+	}
+	m.stubs = append(m.stubs, s)
+	return s
+}
+
+// Reset 清空所有规则
+func (m *MockServer) Reset() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.stubs = nil
+}
+
+// ServeHTTP 返回第一个匹配规则的响应, 没有匹配时返回501
+func (m *MockServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body []byte
+	if r.Body != nil {
+		body, _ = io.ReadAll(r.Body)
+	}
+
+	m.lock.Lock()
+	var matched *Stub
+	for _, s := range m.stubs {
+		if s.match(r, body) {
+			matched = s
+			s.calls.Add(1)
+			break
+		}
+	}
+	m.lock.Unlock()
+
+	if matched == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		fmt.Fprintf(w, "no mock matched %s %s", r.Method, r.URL.RequestURI())
+		return
+	}
+	matched.reply.write(w)
+}
+
+// Start 启动一个真实的HTTP服务, 使用完成后需要Close
+func (m *MockServer) Start() *httptest.Server {
+	return httptest.NewServer(m)
+}
+
+// Transport 不经过网络直接由MockServer处理请求
+func (m *MockServer) Transport() http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, req)
+		resp := w.Result()
+		resp.Request = req
+		return resp, nil
+	})
+}
+
+// RoundTripperFunc 函数形式的RoundTripper
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Stub 请求匹配规则
+type Stub struct {
+	method  string
+	path    string
+	query   map[string]string
+	headers map[string]string
+	body    func(body []byte) bool
+	reply   *Reply
+	calls   atomic.Int64
+}
+
+// Query 要求请求包含该查询参数
+func (s *Stub) Query(key, value string) *Stub {
+	s.query[key] = value
+	return s
+}
+
+// Header 要求请求包含该Header
+func (s *Stub) Header(key, value string) *Stub {
+	s.headers[key] = value
+	return s
+}
+
+// Body 要求请求体与body完全一致
+func (s *Stub) Body(body string) *Stub {
+	s.body = func(b []byte) bool {
+		return string(b) == body
+	}
+	return s
+}
+
+// BodyJSON 要求请求体与v序列化后的JSON语义一致(忽略字段顺序与空白)
+func (s *Stub) BodyJSON(v any) *Stub {
+	expect, err := normalizeJSON(v)
+	if err != nil {
+		panic(err)
+	}
+	s.body = func(b []byte) bool {
+		var actual any
+		if err := json.Unmarshal(b, &actual); err != nil {
+			return false
+		}
+		return reflect.DeepEqual(expect, actual)
+	}
+	return s
+}
+
+// BodyMatch 自定义请求体匹配
+func (s *Stub) BodyMatch(fn func(body []byte) bool) *Stub {
+	s.body = fn
+	return s
+}
+
+// Reply 设置匹配后返回的状态码
+func (s *Stub) Reply(status int) *Reply {
+	s.reply.status = status
+	return s.reply
+}
+
+// Calls 该规则被匹配的次数
+func (s *Stub) Calls() int {
+	return int(s.calls.Load())
+}
+
+func (s *Stub) match(r *http.Request, body []byte) bool {
+	if s.method != "" && s.method != r.Method {
+		return false
+	}
+	if ok, _ := path.Match(s.path, r.URL.Path); !ok {
+		return false
+	}
+	q := r.URL.Query()
+	for k, v := range s.query {
+		if q.Get(k) != v {
+			return false
+		}
+	}
+	for k, v := range s.headers {
+		if r.Header.Get(k) != v {
+			return false
+		}
+	}
+	if s.body != nil && !s.body(body) {
+		return false
+	}
+	return true
+}
+
+// Reply 匹配后返回的响应
+type Reply struct {
+	status  int
+	headers http.Header
+	body    []byte
+}
+
+func (r *Reply) Header(key, value string) *Reply {
+	r.headers.Set(key, value)
+	return r
+}
+
+// Body 返回原始数据
+func (r *Reply) Body(body []byte) *Reply {
+	r.body = body
+	return r
+}
+
+// JSON 返回v序列化后的JSON
+func (r *Reply) JSON(v any) *Reply {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	r.headers.Set("Content-Type", "application/json")
+	r.body = b
+	return r
+}
+
+// File 返回fixture文件的内容
+func (r *Reply) File(filePath string) *Reply {
+	b, err := os.ReadFile(filePath)
+	if err != nil {
+		panic(err)
+	}
+	r.body = b
+	return r
+}
+
+func (r *Reply) write(w http.ResponseWriter) {
+	for k, vs := range r.headers {
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
+	w.WriteHeader(r.status)
+	io.Copy(w, bytes.NewReader(r.body))
+}
+
+func normalizeJSON(v any) (any, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var n any
+	if err := json.Unmarshal(b, &n); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
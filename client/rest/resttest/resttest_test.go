@@ -0,0 +1,114 @@
+package resttest_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/infraboard/mcube/v2/client/rest"
+	"github.com/infraboard/mcube/v2/client/rest/resttest"
+)
+
+var (
+	ctx = context.Background()
+)
+
+type Book struct {
+	Title string `json:"title"`
+}
+
+func TestMockServer(t *testing.T) {
+	m := resttest.NewMockServer()
+	list := m.On(http.MethodGet, "/api/v1/books").Query("page", "1")
+	list.Reply(http.StatusOK).JSON([]*Book{{Title: "go"}})
+	m.On(http.MethodPost, "/api/v1/books").BodyJSON(&Book{Title: "rust"}).Reply(http.StatusCreated)
+
+	c := rest.NewRESTClient().SetBaseURL("http://mock").SetTransport(m.Transport())
+
+	books := []*Book{}
+	if err := c.Get("/api/v1/books").Param("page", "1").Do(ctx).Into(&books); err != nil {
+		t.Fatal(err)
+	}
+	if len(books) != 1 || books[0].Title != "go" || list.Calls() != 1 {
+		t.Fatalf("unexpected books %v", books)
+	}
+
+	if err := c.Post("/api/v1/books").Body(&Book{Title: "rust"}).Do(ctx).Error(); err != nil {
+		t.Fatal(err)
+	}
+
+	err := c.Get("/api/v1/authors").Do(ctx).Error()
+	if err == nil || err.Code != http.StatusNotImplemented {
+		t.Fatalf("expect 501, got %v", err)
+	}
+}
+
+func TestRecordReplay(t *testing.T) {
+	m := resttest.NewMockServer()
+	m.On(http.MethodGet, "/api/v1/books/*").Reply(http.StatusOK).JSON(&Book{Title: "go"})
+	server := m.Start()
+
+	file := filepath.Join(t.TempDir(), "books.yaml")
+	rt, save, err := resttest.NewCassetteTransport(file, resttest.MODE_AUTO)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := rest.NewRESTClient().SetBaseURL(server.URL).SetTransport(rt).SetBearerTokenAuth("secret")
+	book := &Book{}
+	if err := c.Get("/api/v1/books/1").Do(ctx).Into(book); err != nil {
+		t.Fatal(err)
+	}
+	if err := save(); err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+
+	cassette, err := resttest.LoadCassette(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cassette.Interactions[0].Request.Headers["Authorization"]; ok {
+		t.Fatal("authorization header should not be recorded")
+	}
+
+	// 服务已经关闭, 从磁带回放
+	rt, _, err = resttest.NewCassetteTransport(file, resttest.MODE_AUTO)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = rest.NewRESTClient().SetBaseURL(server.URL).SetTransport(rt)
+	book = &Book{}
+	if err := c.Get("/api/v1/books/1").Do(ctx).Into(book); err != nil {
+		t.Fatal(err)
+	}
+	if book.Title != "go" {
+		t.Fatalf("unexpected book %v", book)
+	}
+}
+
+func TestRecorderKeepRequest(t *testing.T) {
+	m := resttest.NewMockServer()
+	m.On(http.MethodPost, "/api/v1/books").Reply(http.StatusCreated)
+	server := m.Start()
+	defer server.Close()
+
+	rc := resttest.NewRecorder(filepath.Join(t.TempDir(), "books.yaml"), nil)
+	body := io.NopCloser(strings.NewReader(`{"title":"go"}`))
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/api/v1/books", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := rc.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	// RoundTripper不能修改调用方的请求
+	if req.Body != body {
+		t.Fatal("request body should not be replaced")
+	}
+}
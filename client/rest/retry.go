@@ -0,0 +1,158 @@
+package rest
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// NewDefaultRetryPolicy 默认重试策略: 最多3次, 100ms起指数退避, 仅重试幂等方法
+func NewDefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: 3,
+		MinBackoff:  100 * time.Millisecond,
+		MaxBackoff:  5 * time.Second,
+		Jitter:      0.2,
+		RetryableStatusCodes: []int{
+			http.StatusRequestTimeout,
+			http.StatusTooManyRequests,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+		RespectRetryAfter: true,
+	}
+}
+
+// RetryPolicy 请求重试策略
+type RetryPolicy struct {
+	// 最大尝试次数(包含第一次请求), 小于等于1表示不重试
+	MaxAttempts int
+	// 第一次重试前的等待时间, 之后按2的指数增长
+	MinBackoff time.Duration
+	// 最大等待时间, 0表示不限制
+	MaxBackoff time.Duration
+	// 随机抖动比例, 取值[0, 1], 0.2表示在退避时间上下浮动20%
+	Jitter float64
+	// 需要重试的状态码, 网络错误总是会重试
+	RetryableStatusCodes []int
+	// 是否重试非幂等方法(POST, PATCH), 默认不重试
+	RetryNonIdempotent bool
+	// 是否遵循服务端返回的Retry-After Header, 最长不超过MaxBackoff
+	RespectRetryAfter bool
+}
+
+func (p *RetryPolicy) SetMaxAttempts(n int) *RetryPolicy {
+	p.MaxAttempts = n
+	return p
+}
+
+func (p *RetryPolicy) SetBackoff(min, max time.Duration) *RetryPolicy {
+	p.MinBackoff = min
+	p.MaxBackoff = max
+	return p
+}
+
+func (p *RetryPolicy) SetJitter(jitter float64) *RetryPolicy {
+	p.Jitter = jitter
+	return p
+}
+
+func (p *RetryPolicy) SetRetryableStatusCodes(codes ...int) *RetryPolicy {
+	p.RetryableStatusCodes = codes
+	return p
+}
+
+func (p *RetryPolicy) SetRetryNonIdempotent(v bool) *RetryPolicy {
+	p.RetryNonIdempotent = v
+	return p
+}
+
+func (p *RetryPolicy) SetRespectRetryAfter(v bool) *RetryPolicy {
+	p.RespectRetryAfter = v
+	return p
+}
+
+// 该方法的请求是否允许重试
+func (p *RetryPolicy) allowMethod(method string) bool {
+	if p.RetryNonIdempotent {
+		return true
+	}
+	return IsIdempotentMethod(method)
+}
+
+func (p *RetryPolicy) isRetryableStatus(code int) bool {
+	return slices.Contains(p.RetryableStatusCodes, code)
+}
+
+// Backoff 计算第attempt次重试前需要等待的时间, attempt从1开始
+func (p *RetryPolicy) Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	d := p.MinBackoff
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if p.MaxBackoff > 0 && d >= p.MaxBackoff {
+			d = p.MaxBackoff
+			break
+		}
+	}
+
+	if p.Jitter > 0 && d > 0 {
+		delta := float64(d) * p.Jitter
+		d = time.Duration(float64(d) - delta + rand.Float64()*2*delta)
+	}
+
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	return d
+}
+
+// 根据响应计算等待时间, 优先使用Retry-After
+func (p *RetryPolicy) waitTime(attempt int, header http.Header) time.Duration {
+	if p.RespectRetryAfter && header != nil {
+		if d, ok := ParseRetryAfter(header.Get(RETRY_AFTER_HEADER)); ok {
+			if p.MaxBackoff > 0 && d > p.MaxBackoff {
+				return p.MaxBackoff
+			}
+			return d
+		}
+	}
+	return p.Backoff(attempt)
+}
+
+// IsIdempotentMethod 判断HTTP方法是否幂等
+func IsIdempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions,
+		http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// ParseRetryAfter 解析Retry-After, 支持秒数与HTTP日期两种格式
+func ParseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(v); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
@@ -0,0 +1,111 @@
+package rest_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/infraboard/mcube/v2/client/rest"
+)
+
+func TestRetryOnStatus(t *testing.T) {
+	var count int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&count, 1) < 3 {
+			w.Header().Set(rest.RETRY_AFTER_HEADER, "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set(rest.CONTENT_TYPE_HEADER, "application/json")
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer s.Close()
+
+	c := rest.NewRESTClient().SetBaseURL(s.URL)
+	c.SetRetryPolicy(rest.NewDefaultRetryPolicy().SetBackoff(time.Millisecond, 10*time.Millisecond))
+
+	resp := map[string]bool{}
+	if err := c.Get("/").Do(ctx).Into(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 || !resp["ok"] {
+		t.Fatalf("expect 3 attempts, got %d", count)
+	}
+}
+
+func TestRetrySkipNonIdempotent(t *testing.T) {
+	var count int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer s.Close()
+
+	c := rest.NewRESTClient().SetBaseURL(s.URL)
+	c.SetRetryPolicy(rest.NewDefaultRetryPolicy().SetBackoff(time.Millisecond, 10*time.Millisecond))
+
+	err := c.Post("/").Body(map[string]string{"a": "b"}).Do(ctx).Error()
+	if err == nil || err.Code != http.StatusBadGateway {
+		t.Fatalf("expect 502, got %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("post should not be retried, got %d attempts", count)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	p := rest.NewDefaultRetryPolicy().SetJitter(0).SetBackoff(100*time.Millisecond, time.Second)
+	for attempt, expect := range map[int]time.Duration{
+		1: 100 * time.Millisecond,
+		2: 200 * time.Millisecond,
+		3: 400 * time.Millisecond,
+		5: time.Second,
+	} {
+		if d := p.Backoff(attempt); d != expect {
+			t.Fatalf("attempt %d expect %s, got %s", attempt, expect, d)
+		}
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var count int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&count, 1) <= 2 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer s.Close()
+	u, _ := url.Parse(s.URL)
+
+	c := rest.NewRESTClient().SetBaseURL(s.URL)
+	c.SetCircuitBreaker(&rest.BreakerConfig{
+		FailureThreshold: 2,
+		OpenTimeout:      50 * time.Millisecond,
+		HalfOpenProbes:   1,
+	})
+
+	c.Get("/").Do(ctx).Error()
+	c.Get("/").Do(ctx).Error()
+	if c.BreakerState(u.Host) != rest.BREAKER_STATE_OPEN {
+		t.Fatal("breaker should be open")
+	}
+
+	err := c.Get("/").Do(ctx).Error()
+	if !errors.Is(err, rest.ErrCircuitOpen) {
+		t.Fatalf("expect circuit open, got %v", err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if err := c.Get("/").Do(ctx).Error(); err != nil {
+		t.Fatal(err)
+	}
+	if c.BreakerState(u.Host) != rest.BREAKER_STATE_CLOSED {
+		t.Fatal("breaker should be closed after probe")
+	}
+}
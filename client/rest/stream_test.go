@@ -0,0 +1,94 @@
+package rest_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/infraboard/mcube/v2/client/rest"
+)
+
+func TestMultipartUpload(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f, h, err := r.FormFile("file")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		data, _ := io.ReadAll(f)
+		fmt.Fprintf(w, "%s:%s:%s", r.FormValue("name"), h.Filename, data)
+	}))
+	defer s.Close()
+
+	var uploaded int64
+	form := rest.NewMultipartForm().
+		Field("name", "report").
+		File("file", "report.csv", strings.NewReader("a,b,c"))
+	body, err := rest.NewRESTClient().SetBaseURL(s.URL).
+		Post("/upload").
+		Multipart(form).
+		OnUploadProgress(func(transferred, total int64) { uploaded = transferred }).
+		Do(ctx).
+		Raw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "report:report.csv:a,b,c" {
+		t.Fatalf("unexpected body %s", body)
+	}
+	if uploaded == 0 {
+		t.Fatal("upload progress not reported")
+	}
+}
+
+func TestBodyReader(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%d", r.ContentLength)
+	}))
+	defer s.Close()
+
+	body, err := rest.NewRESTClient().SetBaseURL(s.URL).
+		Put("/").
+		BodyReader(io.LimitReader(strings.NewReader("0123456789"), 10), 10).
+		Do(ctx).
+		Raw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "10" {
+		t.Fatalf("expect content length 10, got %s", body)
+	}
+}
+
+func TestStreamDecode(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(rest.CONTENT_TYPE_HEADER, "application/json")
+		fmt.Fprint(w, `{"code":0,"data":{"total":3,"items":[{"id":1},{"id":2},{"id":3}]}}`)
+	}))
+	defer s.Close()
+
+	type item struct {
+		Id int `json:"id"`
+	}
+	ids := []int{}
+	err := rest.DecodeEach(
+		rest.NewRESTClient().SetBaseURL(s.URL).Get("/").Do(ctx).ArrayPath("data", "items"),
+		func(v *item) error {
+			ids = append(ids, v.Id)
+			return nil
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(ids) != "[1 2 3]" {
+		t.Fatalf("unexpected items %v", ids)
+	}
+}
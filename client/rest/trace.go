@@ -8,7 +8,7 @@ import (
 
 // 开启后一定要配置全局Tracer
 func (c *RESTClient) EnableTrace() *RESTClient {
-	c.client.Transport = otelhttp.NewTransport(c.transport)
+	c.client.Transport = otelhttp.NewTransport(c.roundTripper())
 	c.provider = otel.GetTracerProvider()
 	c.propagators = otel.GetTextMapPropagator()
 
@@ -21,7 +21,7 @@ func (c *RESTClient) EnableTrace() *RESTClient {
 
 // 关闭Trace
 func (c *RESTClient) DisableTrace() *RESTClient {
-	c.client.Transport = c.transport
+	c.client.Transport = c.roundTripper()
 	c.tr = nil
 	return c
 }
@@ -21,8 +21,9 @@ func main() {
 	ioc.DevelopmentSetup()
 
 	// 消息生产者
-	bus.GetService().TopicSubscribe(context.Background(), TEST_SUBJECT, func(e *bus.Event) {
+	bus.GetService().TopicSubscribe(context.Background(), TEST_SUBJECT, func(e *bus.Event) error {
 		fmt.Println(string(e.Data))
+		return nil
 	})
 
 	// 发布消息
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/infraboard/mcube/v2/ioc/config/bus"
+	// 进程内总线, 不依赖外部服务
+	_ "github.com/infraboard/mcube/v2/ioc/config/bus/memory"
+
+	"github.com/infraboard/mcube/v2/ioc"
+	"github.com/infraboard/mcube/v2/ioc/server"
+)
+
+const (
+	TEST_SUBJECT = "event_bus"
+)
+
+func main() {
+	ioc.DevelopmentSetup()
+
+	// 消息生产者
+	bus.GetService().TopicSubscribe(context.Background(), TEST_SUBJECT, func(e *bus.Event) error {
+		fmt.Println(string(e.Data))
+		return nil
+	})
+
+	// 发布消息
+	go func() {
+		for {
+			time.Sleep(1 * time.Second)
+			err := bus.GetService().Publish(context.Background(), &bus.Event{
+				Subject: TEST_SUBJECT,
+				Data:    []byte("test"),
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+		}
+	}()
+
+	// 消息消费者
+	// 启动应用
+	err := server.Run(context.Background())
+	if err != nil {
+		panic(err)
+	}
+}
@@ -41,33 +41,38 @@ const (
 	CODE_UNKNOWN = 99999
 )
 
-var (
-	reasonMap = map[int]string{
-		CODE_UNAUTHORIZED:          "认证失败",
-		CODE_NOT_FOUND:             "资源未找到",
-		CODE_CONFLICT:              "资源已经存在",
-		CODE_BAD_REQUEST:           "请求不合法",
-		CODE_INTERNAL_SERVER_ERROR: "系统内部错误",
-		CODE_FORBIDDEN:             "访问未授权",
-		CODE_UNKNOWN:               "未知异常",
-		CODE_ACCESS_TOKEN_ILLEGAL:  "访问令牌不合法",
-		CODE_REFRESH_TOKEN_ILLEGAL: "刷新令牌不合法",
-		CODE_OTHER_PLACE_LGOIN:     "异地登录",
-		CODE_OTHER_IP_LOGIN:        "异常IP登录",
-		CODE_OTHER_CLIENT_LOGIN:    "用户已经通过其他端登录",
-		CODE_SESSION_TERMINATED:    "会话结束",
-		CODE_ACESS_TOKEN_EXPIRED:   "访问过期, 请刷新",
-		CODE_REFRESH_TOKEN_EXPIRED: "刷新过期, 请登录",
-		CODE_VERIFY_CODE_REQUIRED:  "异常操作, 需要验证码进行二次确认",
-		CODE_PASSWORD_EXPIRED:      "密码过期, 请找回密码或者联系管理员重置",
+func init() {
+	for _, c := range builtinCodes {
+		c.Namespace = NAMESPACE_MCUBE
+		Register(c)
 	}
-)
+}
+
+// mcube内置错误码
+var builtinCodes = []*ErrorCode{
+	{Code: CODE_UNAUTHORIZED, Name: "UNAUTHORIZED", Reasons: map[string]string{LOCALE_ZH_CN: "认证失败", LOCALE_EN: "Unauthorized"}},
+	{Code: CODE_NOT_FOUND, Name: "NOT_FOUND", Reasons: map[string]string{LOCALE_ZH_CN: "资源未找到", LOCALE_EN: "Not Found"}},
+	{Code: CODE_CONFLICT, Name: "CONFLICT", Reasons: map[string]string{LOCALE_ZH_CN: "资源已经存在", LOCALE_EN: "Conflict"}},
+	{Code: CODE_BAD_REQUEST, Name: "BAD_REQUEST", Reasons: map[string]string{LOCALE_ZH_CN: "请求不合法", LOCALE_EN: "Bad Request"}},
+	{Code: CODE_INTERNAL_SERVER_ERROR, Name: "INTERNAL_SERVER_ERROR", Reasons: map[string]string{LOCALE_ZH_CN: "系统内部错误", LOCALE_EN: "Internal Server Error"}},
+	{Code: CODE_FORBIDDEN, Name: "FORBIDDEN", Reasons: map[string]string{LOCALE_ZH_CN: "访问未授权", LOCALE_EN: "Forbidden"}},
+	{Code: CODE_UNKNOWN, Name: "UNKNOWN", Reasons: map[string]string{LOCALE_ZH_CN: "未知异常", LOCALE_EN: "Unknown Error"}},
+	{Code: CODE_ACCESS_TOKEN_ILLEGAL, Name: "ACCESS_TOKEN_ILLEGAL", Reasons: map[string]string{LOCALE_ZH_CN: "访问令牌不合法", LOCALE_EN: "Access token illegal"}},
+	{Code: CODE_REFRESH_TOKEN_ILLEGAL, Name: "REFRESH_TOKEN_ILLEGAL", Reasons: map[string]string{LOCALE_ZH_CN: "刷新令牌不合法", LOCALE_EN: "Refresh token illegal"}},
+	{Code: CODE_OTHER_PLACE_LGOIN, Name: "OTHER_PLACE_LOGIN", Reasons: map[string]string{LOCALE_ZH_CN: "异地登录", LOCALE_EN: "Logged in from another place"}},
+	{Code: CODE_OTHER_IP_LOGIN, Name: "OTHER_IP_LOGIN", Reasons: map[string]string{LOCALE_ZH_CN: "异常IP登录", LOCALE_EN: "Logged in from abnormal IP"}},
+	{Code: CODE_OTHER_CLIENT_LOGIN, Name: "OTHER_CLIENT_LOGIN", Reasons: map[string]string{LOCALE_ZH_CN: "用户已经通过其他端登录", LOCALE_EN: "Logged in from another client"}},
+	{Code: CODE_SESSION_TERMINATED, Name: "SESSION_TERMINATED", Reasons: map[string]string{LOCALE_ZH_CN: "会话结束", LOCALE_EN: "Session terminated"}},
+	{Code: CODE_ACESS_TOKEN_EXPIRED, Name: "ACCESS_TOKEN_EXPIRED", Reasons: map[string]string{LOCALE_ZH_CN: "访问过期, 请刷新", LOCALE_EN: "Access token expired, please refresh"}},
+	{Code: CODE_REFRESH_TOKEN_EXPIRED, Name: "REFRESH_TOKEN_EXPIRED", Reasons: map[string]string{LOCALE_ZH_CN: "刷新过期, 请登录", LOCALE_EN: "Refresh token expired, please login"}},
+	{Code: CODE_VERIFY_CODE_REQUIRED, Name: "VERIFY_CODE_REQUIRED", Reasons: map[string]string{LOCALE_ZH_CN: "异常操作, 需要验证码进行二次确认", LOCALE_EN: "Verify code required"}},
+	{Code: CODE_PASSWORD_EXPIRED, Name: "PASSWORD_EXPIRED", Reasons: map[string]string{LOCALE_ZH_CN: "密码过期, 请找回密码或者联系管理员重置", LOCALE_EN: "Password expired"}},
+}
 
 func codeReason(code int) string {
-	v, ok := reasonMap[code]
+	c, ok := GetErrorCode(code)
 	if !ok {
-		v = reasonMap[CODE_UNKNOWN]
+		c, _ = GetErrorCode(CODE_UNKNOWN)
 	}
-
-	return v
+	return c.Reason(DefaultLocale())
 }
@@ -78,6 +78,8 @@ type ApiException struct {
 	// 新增字段（向后兼容）
 	cause error  `json:"-"` // 原始错误，不序列化
 	stack string `json:"-"` // 堆栈信息，不序列化
+	// 消息模版参数, 用于Localize时重新格式化消息
+	msgArgs []any `json:"-"`
 
 	metaMu sync.RWMutex `json:"-"` // Meta的读写锁
 }
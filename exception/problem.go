@@ -0,0 +1,144 @@
+package exception
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+const (
+	// RFC 7807 定义的Content-Type
+	MIME_PROBLEM_JSON = "application/problem+json"
+)
+
+var (
+	problemConf = &problemConfig{}
+)
+
+type problemConfig struct {
+	enabled     bool
+	typeBaseURL string
+	lock        sync.RWMutex
+}
+
+// EnableProblemDetails 开启后所有异常响应都使用application/problem+json格式,
+// 未开启时只有请求的Accept包含application/problem+json才使用
+func EnableProblemDetails(enabled bool) {
+	problemConf.lock.Lock()
+	defer problemConf.lock.Unlock()
+	problemConf.enabled = enabled
+}
+
+// SetProblemTypeBaseURL 设置错误码文档地址, type为{baseURL}/{namespace}/{code}
+func SetProblemTypeBaseURL(baseURL string) {
+	problemConf.lock.Lock()
+	defer problemConf.lock.Unlock()
+	problemConf.typeBaseURL = strings.TrimRight(baseURL, "/")
+}
+
+// WantProblemDetails 根据配置与请求的Accept判断是否使用application/problem+json格式
+func WantProblemDetails(accept string) bool {
+	problemConf.lock.RLock()
+	defer problemConf.lock.RUnlock()
+	return problemConf.enabled || strings.Contains(accept, MIME_PROBLEM_JSON)
+}
+
+// ProblemTypeURI 根据注册的错误码生成type, 未注册的错误码使用about:blank,
+// 未设置文档地址时使用urn:mcube:error:{namespace}:{code}
+func ProblemTypeURI(code int) string {
+	c, ok := GetErrorCode(code)
+	if !ok {
+		return "about:blank"
+	}
+
+	problemConf.lock.RLock()
+	defer problemConf.lock.RUnlock()
+	if problemConf.typeBaseURL == "" {
+		return fmt.Sprintf("urn:mcube:error:%s:%d", c.Namespace, c.Code)
+	}
+	u, err := url.JoinPath(problemConf.typeBaseURL, c.Namespace, fmt.Sprint(c.Code))
+	if err != nil {
+		return "about:blank"
+	}
+	return u
+}
+
+// ProblemDetails RFC 7807 Problem Details, 业务相关字段作为扩展成员
+type ProblemDetails struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+
+	// 扩展成员
+	Code      int            `json:"code"`
+	Namespace string         `json:"namespace,omitempty"`
+	TraceID   string         `json:"trace_id,omitempty"`
+	RequestID string         `json:"request_id,omitempty"`
+	Meta      map[string]any `json:"meta,omitempty"`
+	Data      any            `json:"data,omitempty"`
+}
+
+// ToProblem 转换为Problem Details, instance一般为请求的路径
+func (e *ApiException) ToProblem(instance string) *ProblemDetails {
+	e.metaMu.RLock()
+	defer e.metaMu.RUnlock()
+
+	var meta map[string]any
+	if len(e.Meta) > 0 {
+		meta = make(map[string]any, len(e.Meta))
+		for k, v := range e.Meta {
+			meta[k] = v
+		}
+	}
+
+	return &ProblemDetails{
+		Type:      ProblemTypeURI(e.Code),
+		Title:     e.Reason,
+		Status:    e.GetHttpCode(),
+		Detail:    e.Message,
+		Instance:  instance,
+		Code:      e.Code,
+		Namespace: e.Service,
+		TraceID:   e.TraceID,
+		RequestID: e.RequestID,
+		Meta:      meta,
+		Data:      e.Data,
+	}
+}
+
+func (p *ProblemDetails) ToJson() string {
+	dj, _ := json.Marshal(p)
+	return string(dj)
+}
+
+// ToApiException 还原为ApiException, 保留原始的业务错误码
+func (p *ProblemDetails) ToApiException() *ApiException {
+	code := p.Code
+	if code == 0 {
+		code = p.Status
+	}
+	e := NewApiException(code, p.Title).
+		WithHttpCode(p.Status).
+		WithMessage(p.Detail).
+		WithNamespace(p.Namespace).
+		WithTraceID(p.TraceID).
+		WithRequestID(p.RequestID)
+	if p.Meta != nil {
+		e.Meta = p.Meta
+	}
+	e.Data = p.Data
+	return e
+}
+
+// NewProblemFromJson 解析application/problem+json格式的数据
+func NewProblemFromJson(data []byte) (*ProblemDetails, error) {
+	p := &ProblemDetails{}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
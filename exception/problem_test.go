@@ -0,0 +1,38 @@
+package exception_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/infraboard/mcube/v2/exception"
+)
+
+func TestProblemRoundTrip(t *testing.T) {
+	exception.SetProblemTypeBaseURL("https://errors.example.com/")
+	defer exception.SetProblemTypeBaseURL("")
+
+	e := ErrBookNotFound.New("b1").WithTraceID("trace01").WithRequestID("req01").WithMeta("id", "b1")
+	p := e.ToProblem("/api/book/v1/books/b1")
+	if p.Type != "https://errors.example.com/book/1001" || p.Status != http.StatusNotFound || p.Title != e.Reason {
+		t.Fatalf("unexpected problem %s", p.ToJson())
+	}
+
+	decoded, err := exception.NewProblemFromJson([]byte(p.ToJson()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	re := decoded.ToApiException()
+	if !ErrBookNotFound.Is(re) || re.GetHttpCode() != http.StatusNotFound ||
+		re.TraceID != "trace01" || re.RequestID != "req01" || re.GetMeta("id") != "b1" {
+		t.Fatalf("unexpected exception %s", re.ToJson())
+	}
+}
+
+func TestProblemTypeURI(t *testing.T) {
+	if v := exception.ProblemTypeURI(123456); v != "about:blank" {
+		t.Fatalf("unexpected type %s", v)
+	}
+	if v := exception.ProblemTypeURI(exception.CODE_NOT_FOUND); v != "urn:mcube:error:mcube:404" {
+		t.Fatalf("unexpected type %s", v)
+	}
+}
@@ -0,0 +1,374 @@
+package exception
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	LOCALE_ZH_CN = "zh-CN"
+	LOCALE_EN    = "en"
+
+	// mcube内置错误码的命名空间
+	NAMESPACE_MCUBE = "mcube"
+)
+
+var (
+	registry = &codeRegistry{
+		codes:         map[int]*ErrorCode{},
+		defaultLocale: LOCALE_ZH_CN,
+	}
+)
+
+// ErrorCode 错误码定义, 通过Register注册后统一管理
+//
+//	var ErrBookNotFound = exception.Register(&exception.ErrorCode{
+//		Namespace: "book",
+//		Code:      1001,
+//		Name:      "BOOK_NOT_FOUND",
+//		HttpCode:  http.StatusNotFound,
+//		Reasons:   map[string]string{"zh-CN": "书籍不存在", "en": "book not found"},
+//		Messages:  map[string]string{"zh-CN": "书籍 %s 不存在", "en": "book %s not found"},
+//	})
+//
+//	return ErrBookNotFound.New(id)
+type ErrorCode struct {
+	// 错误码所属模块
+	Namespace string `json:"namespace"`
+	// 业务错误码, 全局唯一
+	Code int `json:"code"`
+	// 错误码名称, 同一命名空间内唯一
+	Name string `json:"name"`
+	// 对应的HTTP状态码, 为0时根据Code推导
+	HttpCode int `json:"http_code"`
+	// 对应的GRPC状态码, 为0时根据HttpCode推导
+	GrpcCode codes.Code `json:"grpc_code"`
+	// 错误码说明, 用于生成错误码目录
+	Description string `json:"description,omitempty"`
+	// 不同语言的错误原因, key为语言标签, 比如zh-CN, en
+	Reasons map[string]string `json:"reasons"`
+	// 不同语言的消息模版, 使用fmt格式化参数
+	Messages map[string]string `json:"messages,omitempty"`
+}
+
+// GetHttpCode 获取HTTP状态码
+func (c *ErrorCode) GetHttpCode() int {
+	if c.HttpCode != 0 {
+		return c.HttpCode
+	}
+	if c.Code/100 >= 1 && c.Code/100 <= 5 {
+		return c.Code
+	}
+	return http.StatusInternalServerError
+}
+
+// GetGrpcCode 获取GRPC状态码
+func (c *ErrorCode) GetGrpcCode() codes.Code {
+	if c.GrpcCode != codes.OK {
+		return c.GrpcCode
+	}
+	return HttpCodeToGrpcCode(c.GetHttpCode())
+}
+
+// Reason 获取指定语言的错误原因
+func (c *ErrorCode) Reason(locale string) string {
+	return lookupLocale(c.Reasons, locale)
+}
+
+// Message 获取指定语言格式化后的消息
+func (c *ErrorCode) Message(locale string, args ...any) string {
+	tpl := lookupLocale(c.Messages, locale)
+	if tpl == "" {
+		return ""
+	}
+	if len(args) == 0 {
+		return tpl
+	}
+	return fmt.Sprintf(tpl, args...)
+}
+
+// New 使用默认语言创建异常, 响应时可以通过Localize转换为请求的语言
+func (c *ErrorCode) New(args ...any) *ApiException {
+	locale := DefaultLocale()
+	e := NewApiException(c.Code, c.Reason(locale)).
+		WithHttpCode(c.GetHttpCode()).
+		WithNamespace(c.Namespace)
+	e.Message = c.Message(locale, args...)
+	e.msgArgs = args
+	return e
+}
+
+// Is 判断err是否是该错误码的异常
+func (c *ErrorCode) Is(err error) bool {
+	return IsApiException(err, c.Code)
+}
+
+type codeRegistry struct {
+	codes         map[int]*ErrorCode
+	defaultLocale string
+	lock          sync.RWMutex
+}
+
+// Register 注册错误码, 错误码或者同一命名空间下的名称冲突时panic, 保证启动时即可发现冲突
+func Register(c *ErrorCode) *ErrorCode {
+	if err := registry.add(c); err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func (r *codeRegistry) add(c *ErrorCode) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if exist, ok := r.codes[c.Code]; ok {
+		return fmt.Errorf("error code %d(%s.%s) conflict with %s.%s",
+			c.Code, c.Namespace, c.Name, exist.Namespace, exist.Name)
+	}
+	if c.Name != "" {
+		for _, exist := range r.codes {
+			if exist.Namespace == c.Namespace && exist.Name == c.Name {
+				return fmt.Errorf("error code name %s.%s conflict between %d and %d",
+					c.Namespace, c.Name, exist.Code, c.Code)
+			}
+		}
+	}
+	r.codes[c.Code] = c
+	return nil
+}
+
+// GetErrorCode 查询已经注册的错误码
+func GetErrorCode(code int) (*ErrorCode, bool) {
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+	c, ok := registry.codes[code]
+	return c, ok
+}
+
+// ErrorCodes 所有已注册的错误码, 按命名空间与错误码排序
+func ErrorCodes() []*ErrorCode {
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+
+	items := make([]*ErrorCode, 0, len(registry.codes))
+	for _, c := range registry.codes {
+		items = append(items, c)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Namespace != items[j].Namespace {
+			return items[i].Namespace < items[j].Namespace
+		}
+		return items[i].Code < items[j].Code
+	})
+	return items
+}
+
+// SetDefaultLocale 设置默认语言, 默认为zh-CN
+func SetDefaultLocale(locale string) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	registry.defaultLocale = locale
+}
+
+func DefaultLocale() string {
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+	return registry.defaultLocale
+}
+
+// Localize 根据Accept-Language返回已注册错误码的异常对应语言的副本, 不修改传入的异常,
+// 未注册或者没有匹配语言时返回传入的异常
+func Localize(e *ApiException, acceptLanguage string) *ApiException {
+	if e == nil || acceptLanguage == "" {
+		return e
+	}
+	c, ok := GetErrorCode(e.Code)
+	if !ok {
+		return e
+	}
+
+	locale := MatchLocale(acceptLanguage, c.Reasons)
+	if locale == "" {
+		return e
+	}
+	l := e.clone()
+	l.Reason = c.Reason(locale)
+	if msg := c.Message(locale, e.msgArgs...); msg != "" {
+		l.Message = msg
+	}
+	return l
+}
+
+// 异常可能是多个请求共享的变量, 修改前复制
+func (e *ApiException) clone() *ApiException {
+	e.metaMu.RLock()
+	defer e.metaMu.RUnlock()
+
+	c := &ApiException{
+		Service:   e.Service,
+		TraceID:   e.TraceID,
+		RequestID: e.RequestID,
+		HttpCode:  e.HttpCode,
+		Code:      e.Code,
+		Reason:    e.Reason,
+		Message:   e.Message,
+		Data:      e.Data,
+		cause:     e.cause,
+		stack:     e.stack,
+		msgArgs:   e.msgArgs,
+	}
+	if e.Meta != nil {
+		c.Meta = make(map[string]any, len(e.Meta))
+		for k, v := range e.Meta {
+			c.Meta[k] = v
+		}
+	}
+	return c
+}
+
+// MatchLocale 从Accept-Language中选出available中最匹配的语言, 没有匹配时返回空,
+// 多个语言的主语言相同时按名称排序选择第一个, 比如en匹配en-GB与en-US时返回en-GB
+func MatchLocale(acceptLanguage string, available map[string]string) string {
+	locales := slices.Sorted(maps.Keys(available))
+	for _, tag := range ParseAcceptLanguage(acceptLanguage) {
+		// 完全匹配
+		for _, locale := range locales {
+			if strings.EqualFold(locale, tag) {
+				return locale
+			}
+		}
+		// 主语言匹配, 比如en-US匹配en, en匹配en-GB
+		primary := primaryLanguage(tag)
+		for _, locale := range locales {
+			if strings.EqualFold(primaryLanguage(locale), primary) {
+				return locale
+			}
+		}
+	}
+	return ""
+}
+
+// ParseAcceptLanguage 解析Accept-Language, 按权重从高到低返回语言标签
+func ParseAcceptLanguage(v string) []string {
+	type tag struct {
+		name string
+		q    float64
+	}
+	tags := []tag{}
+	for _, part := range strings.Split(v, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		if k, val, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(k) == "q" {
+			if f, err := strconv.ParseFloat(strings.TrimSpace(val), 64); err == nil {
+				q = f
+			}
+		}
+		name = strings.TrimSpace(name)
+		if name == "*" || q <= 0 {
+			continue
+		}
+		tags = append(tags, tag{name: name, q: q})
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	names := make([]string, 0, len(tags))
+	for _, t := range tags {
+		names = append(names, t.name)
+	}
+	return names
+}
+
+func primaryLanguage(tag string) string {
+	p, _, _ := strings.Cut(tag, "-")
+	p, _, _ = strings.Cut(p, "_")
+	return strings.ToLower(p)
+}
+
+// 查找语言对应的文本, 找不到时使用默认语言, 再找不到时使用任意一个
+func lookupLocale(m map[string]string, locale string) string {
+	if len(m) == 0 {
+		return ""
+	}
+	if v, ok := m[locale]; ok {
+		return v
+	}
+	if matched := MatchLocale(locale, m); matched != "" {
+		return m[matched]
+	}
+	if v, ok := m[DefaultLocale()]; ok {
+		return v
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return m[keys[0]]
+}
+
+// WriteCatalogJSON 把所有已注册的错误码以JSON格式输出
+func WriteCatalogJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(ErrorCodes())
+}
+
+// WriteCatalogMarkdown 把所有已注册的错误码以Markdown表格输出, 按命名空间分组
+func WriteCatalogMarkdown(w io.Writer) error {
+	items := ErrorCodes()
+
+	locales := map[string]bool{}
+	for _, c := range items {
+		for l := range c.Reasons {
+			locales[l] = true
+		}
+	}
+	localeList := make([]string, 0, len(locales))
+	for l := range locales {
+		localeList = append(localeList, l)
+	}
+	sort.Strings(localeList)
+
+	b := &strings.Builder{}
+	b.WriteString("# 错误码目录\n")
+	for i, c := range items {
+		if i == 0 || c.Namespace != items[i-1].Namespace {
+			fmt.Fprintf(b, "\n## %s\n\n", c.Namespace)
+			b.WriteString("| 错误码 | 名称 | HTTP状态码 | GRPC状态码 |")
+			for _, l := range localeList {
+				fmt.Fprintf(b, " %s |", l)
+			}
+			b.WriteString(" 说明 |\n|---|---|---|---|")
+			for range localeList {
+				b.WriteString("---|")
+			}
+			b.WriteString("---|\n")
+		}
+		fmt.Fprintf(b, "| %d | %s | %d | %s |", c.Code, c.Name, c.GetHttpCode(), c.GetGrpcCode())
+		for _, l := range localeList {
+			fmt.Fprintf(b, " %s |", escapeMarkdown(c.Reasons[l]))
+		}
+		fmt.Fprintf(b, " %s |\n", escapeMarkdown(c.Description))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func escapeMarkdown(s string) string {
+	return strings.ReplaceAll(s, "|", "\\|")
+}
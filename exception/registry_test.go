@@ -0,0 +1,93 @@
+package exception_test
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/infraboard/mcube/v2/exception"
+)
+
+var ErrBookNotFound = exception.Register(&exception.ErrorCode{
+	Namespace: "book",
+	Code:      1001,
+	Name:      "BOOK_NOT_FOUND",
+	HttpCode:  http.StatusNotFound,
+	Reasons:   map[string]string{exception.LOCALE_ZH_CN: "书籍不存在", exception.LOCALE_EN: "Book not found"},
+	Messages:  map[string]string{exception.LOCALE_ZH_CN: "书籍 %s 不存在", exception.LOCALE_EN: "book %s not found"},
+})
+
+func TestErrorCodeNew(t *testing.T) {
+	e := ErrBookNotFound.New("b1")
+	if e.Reason != "书籍不存在" || e.Message != "书籍 b1 不存在" || e.GetHttpCode() != http.StatusNotFound {
+		t.Fatalf("unexpected exception %s", e.ToJson())
+	}
+	if !ErrBookNotFound.Is(e) || e.GetNamespace() != "book" {
+		t.Fatal("exception should match error code")
+	}
+
+	l := exception.Localize(e, "en-US,en;q=0.9,zh-CN;q=0.8")
+	if l.Reason != "Book not found" || l.Message != "book b1 not found" {
+		t.Fatalf("unexpected localized exception %s", l.ToJson())
+	}
+	// 不修改传入的异常, 共享的异常变量不会被其他请求的语言覆盖
+	if e.Reason != "书籍不存在" || e.Message != "书籍 b1 不存在" {
+		t.Fatalf("original exception modified %s", e.ToJson())
+	}
+
+	// 内置错误码同样支持多语言
+	nf := exception.Localize(exception.NewNotFound("x"), "en")
+	if nf.Reason != "Not Found" || nf.Message != "x" {
+		t.Fatalf("unexpected localized exception %s", nf.ToJson())
+	}
+}
+
+func TestRegisterConflict(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("expect conflict panic")
+		}
+	}()
+	exception.Register(&exception.ErrorCode{Namespace: "author", Code: 1001, Name: "AUTHOR_NOT_FOUND"})
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	tags := exception.ParseAcceptLanguage("zh-CN;q=0.5, en-US, *;q=0.1, fr;q=0")
+	if strings.Join(tags, ",") != "en-US,zh-CN" {
+		t.Fatalf("unexpected tags %v", tags)
+	}
+}
+
+func TestMatchLocale(t *testing.T) {
+	available := map[string]string{"en-US": "", "en-GB": "", "zh-CN": ""}
+	for range 100 {
+		if l := exception.MatchLocale("en", available); l != "en-GB" {
+			t.Fatalf("expect en-GB, got %s", l)
+		}
+	}
+	if l := exception.MatchLocale("en-US", available); l != "en-US" {
+		t.Fatalf("expect en-US, got %s", l)
+	}
+	if l := exception.MatchLocale("fr", available); l != "" {
+		t.Fatalf("expect no match, got %s", l)
+	}
+}
+
+func TestWriteCatalog(t *testing.T) {
+	b := &bytes.Buffer{}
+	if err := exception.WriteCatalogMarkdown(b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "| 1001 | BOOK_NOT_FOUND | 404 | NotFound |") {
+		t.Fatal(b.String())
+	}
+
+	b.Reset()
+	if err := exception.WriteCatalogJSON(b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), `"name": "BOOK_NOT_FOUND"`) {
+		t.Fatal(b.String())
+	}
+}
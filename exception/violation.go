@@ -0,0 +1,40 @@
+package exception
+
+import "encoding/json"
+
+const (
+	// Meta中保存字段校验错误的key
+	META_FIELD_VIOLATIONS = "field_violations"
+)
+
+// FieldViolation 请求参数校验失败的字段
+type FieldViolation struct {
+	Field       string `json:"field"`
+	Description string `json:"description"`
+}
+
+// WithFieldViolation 添加字段校验错误, 传递给GRPC调用方时会转换为google.rpc.BadRequest
+func (e *ApiException) WithFieldViolation(field, description string) *ApiException {
+	violations := append(e.FieldViolations(), &FieldViolation{Field: field, Description: description})
+	return e.safeWithMeta(META_FIELD_VIOLATIONS, violations)
+}
+
+// FieldViolations 字段校验错误, 兼容从JSON反序列化得到的Meta
+func (e *ApiException) FieldViolations() []*FieldViolation {
+	switch v := e.safeGetMeta(META_FIELD_VIOLATIONS).(type) {
+	case nil:
+		return nil
+	case []*FieldViolation:
+		return v
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil
+		}
+		violations := []*FieldViolation{}
+		if err := json.Unmarshal(b, &violations); err != nil {
+			return nil
+		}
+		return violations
+	}
+}
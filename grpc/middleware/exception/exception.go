@@ -2,14 +2,16 @@ package exception
 
 import (
 	"context"
+	"io"
 
 	"github.com/infraboard/mcube/v2/exception"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
 )
 
-// grpc server端 的异常只支持 code 与 description, 为了能完整把异常传递给下游调用方, 把异常放到了grpc response header中
-// 客户端如果发现有这个key 说明该异常是我们定义的业务异常对象(API Exception), 需要还原。
+// 服务端通过NewUnaryServerInterceptor把异常转换为google.rpc.Status, 完整信息放在Status Details中,
+// 客户端通过该拦截器还原为ApiException, 调用方可以直接使用errors.As判断业务异常。
+// 兼容旧版本把异常JSON放到grpc trailer(TRAILER_ERROR_JSON_KEY)中的服务端
 func NewUnaryClientInterceptor() grpc.UnaryClientInterceptor {
 	return (&UnaryClientInterceptor{}).UnaryClientInterceptor
 }
@@ -28,9 +30,35 @@ func (e *UnaryClientInterceptor) UnaryClientInterceptor(
 	var trailer metadata.MD
 	opts = append(opts, grpc.Trailer(&trailer))
 	err := invoker(ctx, method, req, reply, cc, opts...)
+	if err == nil {
+		return nil
+	}
 	t := trailer.Get(exception.TRAILER_ERROR_JSON_KEY)
 	if len(t) > 0 {
-		err = exception.NewApiExceptionFromString(t[0])
+		return exception.NewApiExceptionFromString(t[0])
+	}
+	return FromError(err)
+}
+
+// NewStreamClientInterceptor 流式接口的异常还原, 包括建立连接与RecvMsg返回的异常
+func NewStreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			return nil, FromError(err)
+		}
+		return &clientStream{ClientStream: cs}, nil
+	}
+}
+
+type clientStream struct {
+	grpc.ClientStream
+}
+
+func (s *clientStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err == io.EOF {
+		return err
 	}
-	return err
+	return FromError(err)
 }
@@ -0,0 +1,116 @@
+package exception_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/infraboard/mcube/v2/exception"
+	mexception "github.com/infraboard/mcube/v2/grpc/middleware/exception"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type healthServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+	err error
+}
+
+func (h *healthServer) Check(context.Context, *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	return nil, h.err
+}
+
+func TestStatusRoundTrip(t *testing.T) {
+	e := exception.NewBadRequest("参数校验失败").
+		WithNamespace("book").
+		WithTraceID("trace-1").
+		WithRequestID("req-1").
+		WithMeta("retry", true).
+		WithFieldViolation("title", "required").
+		WithCause(fmt.Errorf("validate: %w", errors.New("title is empty")))
+
+	st := mexception.ToStatus(e)
+	if st.Code() != codes.InvalidArgument {
+		t.Fatalf("expect InvalidArgument, got %s", st.Code())
+	}
+
+	got := mexception.FromStatus(st)
+	if got.Code != e.Code || got.Service != "book" || got.Message != e.Message {
+		t.Fatalf("unexpected exception %s", got.ToJson())
+	}
+	if got.TraceID != "trace-1" || got.RequestID != "req-1" || got.GetMeta("retry") != true {
+		t.Fatalf("unexpected exception %s", got.ToJson())
+	}
+	if v := got.FieldViolations(); len(v) != 1 || v[0].Field != "title" {
+		t.Fatalf("unexpected field violations %v", v)
+	}
+	if got.Cause() == nil || got.Cause().Error() != "validate: title is empty → title is empty" {
+		t.Fatalf("unexpected cause %v", got.Cause())
+	}
+}
+
+func TestToErrorWrappedStatus(t *testing.T) {
+	e := exception.NewNotFound("book %s not found", "b1").
+		WithRequestID("req-1").
+		WithCause(status.Error(codes.Unavailable, "upstream unavailable"))
+
+	st, _ := status.FromError(mexception.ToError(e))
+	if st.Code() != codes.NotFound {
+		t.Fatalf("expect NotFound, got %s", st.Code())
+	}
+	if len(st.Details()) == 0 {
+		t.Fatal("expect exception details")
+	}
+	if got := mexception.FromStatus(st); got.Code != e.Code || got.RequestID != "req-1" {
+		t.Fatalf("unexpected exception %s", got.ToJson())
+	}
+
+	// 没有ApiException的GRPC状态原样返回
+	upstream := status.Error(codes.Unavailable, "upstream unavailable")
+	if err := mexception.ToError(upstream); err != upstream {
+		t.Fatalf("expect status passed through, got %v", err)
+	}
+}
+
+func TestInterceptor(t *testing.T) {
+	lis := bufconn.Listen(1 << 20)
+	svr := grpc.NewServer(grpc.ChainUnaryInterceptor(mexception.NewUnaryServerInterceptor()))
+	grpc_health_v1.RegisterHealthServer(svr, &healthServer{
+		err: exception.NewNotFound("book %s not found", "1").WithNamespace("book"),
+	})
+	go svr.Serve(lis)
+	defer svr.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, s string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(mexception.NewUnaryClientInterceptor()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	_, err = grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	var apiErr *exception.ApiException
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expect ApiException, got %v", err)
+	}
+	if !exception.IsNotFoundError(err) || apiErr.Service != "book" || apiErr.Message != "book 1 not found" {
+		t.Fatalf("unexpected exception %s", apiErr.ToJson())
+	}
+
+	// 非ApiException的状态错误按状态码还原
+	e := mexception.FromError(status.Error(codes.PermissionDenied, "denied"))
+	if !errors.As(e, &apiErr) || apiErr.GetHttpCode() != 403 {
+		t.Fatalf("unexpected exception %v", e)
+	}
+}
@@ -0,0 +1,22 @@
+package exception
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// NewUnaryServerInterceptor 把业务返回的ApiException转换为携带完整信息的google.rpc.Status
+func NewUnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		resp, err := handler(ctx, req)
+		return resp, ToError(err)
+	}
+}
+
+// NewStreamServerInterceptor 流式接口的异常转换
+func NewStreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return ToError(handler(srv, ss))
+	}
+}
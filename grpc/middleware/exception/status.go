@@ -0,0 +1,233 @@
+package exception
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/infraboard/mcube/v2/exception"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+const (
+	// ErrorInfo.Metadata 中使用的key
+	METADATA_CODE      = "code"
+	METADATA_HTTP_CODE = "http_code"
+	METADATA_REASON    = "reason"
+	METADATA_MESSAGE   = "message"
+	METADATA_TRACE_ID  = "trace_id"
+	METADATA_CAUSE     = "cause"
+	METADATA_META      = "meta"
+	METADATA_DATA      = "data"
+
+	// 错误链摘要的分隔符, 与ApiException.ErrorChainString保持一致
+	CAUSE_SEPARATOR = " → "
+)
+
+// ToStatus 把ApiException转换为google.rpc.Status, 异常的完整信息通过Details传递:
+//
+//   - ErrorInfo: 业务错误码, 命名空间, 原因, 错误链摘要, Meta与Data
+//   - BadRequest: 通过WithFieldViolation添加的字段校验错误
+//   - RequestInfo: 请求ID与追踪ID
+//
+// GRPC状态码优先使用注册错误码中定义的值, 否则根据HttpCode推导
+func ToStatus(e *exception.ApiException) *status.Status {
+	code := exception.HttpCodeToGrpcCode(e.GetHttpCode())
+	reason := strconv.Itoa(e.Code)
+	if c, ok := exception.GetErrorCode(e.Code); ok {
+		code = c.GetGrpcCode()
+		if c.Name != "" {
+			reason = c.Name
+		}
+	}
+	// 异常不能使用OK状态码, 否则details会被丢弃
+	if code == codes.OK {
+		code = codes.Unknown
+	}
+
+	info := &errdetails.ErrorInfo{
+		Reason: reason,
+		Domain: e.Service,
+		Metadata: map[string]string{
+			METADATA_CODE:      strconv.Itoa(e.Code),
+			METADATA_HTTP_CODE: strconv.Itoa(e.GetHttpCode()),
+			METADATA_REASON:    e.Reason,
+			METADATA_MESSAGE:   e.Message,
+		},
+	}
+	if e.TraceID != "" {
+		info.Metadata[METADATA_TRACE_ID] = e.TraceID
+	}
+	if chain := e.ErrorChain(); len(chain) > 1 {
+		info.Metadata[METADATA_CAUSE] = strings.Join(chain[1:], CAUSE_SEPARATOR)
+	}
+	if meta := metaWithoutViolations(e); len(meta) > 0 {
+		if b, err := json.Marshal(meta); err == nil {
+			info.Metadata[METADATA_META] = string(b)
+		}
+	}
+	if e.Data != nil {
+		if b, err := json.Marshal(e.Data); err == nil {
+			info.Metadata[METADATA_DATA] = string(b)
+		}
+	}
+
+	st := status.New(code, e.Error())
+	details := []protoadapt.MessageV1{info}
+	if violations := e.FieldViolations(); len(violations) > 0 {
+		br := &errdetails.BadRequest{}
+		for _, v := range violations {
+			br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       v.Field,
+				Description: v.Description,
+			})
+		}
+		details = append(details, br)
+	}
+	if e.RequestID != "" || e.TraceID != "" {
+		details = append(details, &errdetails.RequestInfo{
+			RequestId:   e.RequestID,
+			ServingData: e.TraceID,
+		})
+	}
+
+	withDetails, err := st.WithDetails(details...)
+	if err != nil {
+		return st
+	}
+	return withDetails
+}
+
+// FromStatus 从google.rpc.Status还原ApiException, 没有携带ErrorInfo的状态(比如框架本身返回的错误)
+// 根据状态码推导出HttpCode, 使用状态码名称作为Reason
+func FromStatus(st *status.Status) *exception.ApiException {
+	if st == nil || st.Code() == codes.OK {
+		return nil
+	}
+
+	var (
+		info    *errdetails.ErrorInfo
+		br      *errdetails.BadRequest
+		reqInfo *errdetails.RequestInfo
+	)
+	for _, d := range st.Details() {
+		switch v := d.(type) {
+		case *errdetails.ErrorInfo:
+			info = v
+		case *errdetails.BadRequest:
+			br = v
+		case *errdetails.RequestInfo:
+			reqInfo = v
+		}
+	}
+
+	httpCode := exception.GrpcCodeToHttpCode(st.Code())
+	if info == nil {
+		e := exception.NewApiException(httpCode, st.Code().String()).
+			WithHttpCode(httpCode).
+			WithMessage(st.Message())
+		appendDetails(e, br, reqInfo)
+		return e
+	}
+
+	md := info.Metadata
+	code, err := strconv.Atoi(md[METADATA_CODE])
+	if err != nil {
+		code = httpCode
+	}
+	if v, err := strconv.Atoi(md[METADATA_HTTP_CODE]); err == nil {
+		httpCode = v
+	}
+	reason := md[METADATA_REASON]
+	if reason == "" {
+		reason = info.Reason
+	}
+
+	e := exception.NewApiException(code, reason).
+		WithHttpCode(httpCode).
+		WithNamespace(info.Domain).
+		WithMessage(md[METADATA_MESSAGE])
+	if v := md[METADATA_META]; v != "" {
+		meta := map[string]any{}
+		if err := json.Unmarshal([]byte(v), &meta); err == nil {
+			for k, mv := range meta {
+				e.WithMeta(k, mv)
+			}
+		}
+	}
+	if v := md[METADATA_DATA]; v != "" {
+		var data any
+		if err := json.Unmarshal([]byte(v), &data); err == nil {
+			e.WithData(data)
+		}
+	}
+	if v := md[METADATA_TRACE_ID]; v != "" {
+		e.WithTraceID(v)
+	}
+	if v := md[METADATA_CAUSE]; v != "" {
+		e.WithCause(errors.New(v))
+	}
+	appendDetails(e, br, reqInfo)
+	return e
+}
+
+// FromError 把GRPC调用返回的错误还原为ApiException, 非GRPC状态的错误原样返回
+func FromError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var apiErr *exception.ApiException
+	if errors.As(err, &apiErr) {
+		return err
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	return FromStatus(st)
+}
+
+// ToError 把ApiException转换为GRPC状态错误, 其他错误原样返回,
+// 先检查ApiException, 避免Cause中的GRPC状态覆盖ApiException的错误码与详情
+func ToError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var apiErr *exception.ApiException
+	if errors.As(err, &apiErr) {
+		return ToStatus(apiErr).Err()
+	}
+	return err
+}
+
+func appendDetails(e *exception.ApiException, br *errdetails.BadRequest, reqInfo *errdetails.RequestInfo) {
+	if br != nil {
+		for _, v := range br.FieldViolations {
+			e.WithFieldViolation(v.Field, v.Description)
+		}
+	}
+	if reqInfo != nil {
+		if reqInfo.RequestId != "" {
+			e.WithRequestID(reqInfo.RequestId)
+		}
+		if reqInfo.ServingData != "" && e.TraceID == "" {
+			e.WithTraceID(reqInfo.ServingData)
+		}
+	}
+}
+
+// FieldViolations通过BadRequest传递, 不再重复放到Meta中
+func metaWithoutViolations(e *exception.ApiException) map[string]any {
+	meta := map[string]any{}
+	for k, v := range e.Meta {
+		if k == exception.META_FIELD_VIOLATIONS {
+			continue
+		}
+		meta[k] = v
+	}
+	return meta
+}
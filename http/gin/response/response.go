@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
 	"github.com/infraboard/mcube/v2/desense"
 	"github.com/infraboard/mcube/v2/exception"
 	"github.com/infraboard/mcube/v2/ioc/config/application"
@@ -41,5 +42,13 @@ func Failed(c *gin.Context, err error) {
 	if e.Service == "" {
 		e.WithNamespace(application.Get().AppName)
 	}
+	e = exception.Localize(e, c.GetHeader("Accept-Language"))
+
+	// RFC 7807
+	if exception.WantProblemDetails(c.GetHeader("Accept")) {
+		c.Header("Content-Type", exception.MIME_PROBLEM_JSON)
+		c.Render(e.GetHttpCode(), render.JSON{Data: e.ToProblem(c.Request.URL.Path)})
+		return
+	}
 	c.JSON(e.GetHttpCode(), e)
 }
@@ -1,6 +1,9 @@
 package response
 
-import "fmt"
+import (
+	"fmt"
+	"net/http"
+)
 
 // NewData new实例
 func NewData(data interface{}) *Data {
@@ -22,6 +25,22 @@ type Data struct {
 	Message   string      `json:"message,omitempty"`    // 关于这次响应的说明信息
 	Data      interface{} `json:"data,omitempty"`       // 返回的具体数据
 	Meta      interface{} `json:"meta,omitempty"`       // 数据meta
+
+	acceptLanguage string
+	request        *http.Request
+}
+
+// AcceptLanguage 通过WithAcceptLanguage设置的语言, 未设置时使用请求的Accept-Language
+func (d *Data) AcceptLanguage() string {
+	if d.acceptLanguage == "" && d.request != nil {
+		return d.request.Header.Get("Accept-Language")
+	}
+	return d.acceptLanguage
+}
+
+// Request 通过WithRequest设置的请求
+func (d *Data) Request() *http.Request {
+	return d.request
 }
 
 func (d *Data) Error() error {
@@ -66,6 +85,20 @@ func WithRecommend(msg string) Option {
 	})
 }
 
+// WithAcceptLanguage 按请求的Accept-Language返回已注册错误码的异常信息
+func WithAcceptLanguage(lang string) Option {
+	return newFuncOption(func(o *Data) {
+		o.acceptLanguage = lang
+	})
+}
+
+// WithRequest 传入当前请求, 用于内容协商(比如Accept-Language, application/problem+json)
+func WithRequest(r *http.Request) Option {
+	return newFuncOption(func(o *Data) {
+		o.request = r
+	})
+}
+
 func WithMeta(meta interface{}) Option {
 	return newFuncOption(func(o *Data) {
 		o.Meta = meta
@@ -19,8 +19,25 @@ func Failed(w http.ResponseWriter, err error, opts ...Option) {
 		meta     any
 	)
 
+	negotiation := &Data{}
+	for _, opt := range opts {
+		opt.Apply(negotiation)
+	}
+
+	// RFC 7807
+	accept := ""
+	if r := negotiation.Request(); r != nil {
+		accept = r.Header.Get("Accept")
+	}
+	if exception.WantProblemDetails(accept) {
+		Problem(w, negotiation.Request(), err)
+		return
+	}
+
 	switch t := err.(type) {
 	case *exception.ApiException:
+		t = exception.Localize(t, negotiation.AcceptLanguage())
+		err = t
 		errCode = t.ErrorCode()
 		reason = t.GetReason()
 		data = t.GetData()
@@ -64,6 +81,34 @@ func Failed(w http.ResponseWriter, err error, opts ...Option) {
 	w.Write(respByt)
 }
 
+// Problem 以application/problem+json格式返回异常, r可以为nil
+func Problem(w http.ResponseWriter, r *http.Request, err error) {
+	e, ok := err.(*exception.ApiException)
+	if !ok {
+		e = exception.NewApiException(exception.CODE_UNKNOWN, http.StatusText(http.StatusInternalServerError)).
+			WithHttpCode(http.StatusInternalServerError).
+			WithMessage(err.Error())
+	}
+
+	instance := ""
+	if r != nil {
+		e = exception.Localize(e, r.Header.Get("Accept-Language"))
+		instance = r.URL.Path
+	}
+
+	respByt, err := json.Marshal(e.ToProblem(instance))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		errMSG := fmt.Sprintf(`{"status":"error", "message": "encoding to json error, %s"}`, err)
+		w.Write([]byte(errMSG))
+		return
+	}
+
+	w.Header().Set("Content-Type", exception.MIME_PROBLEM_JSON)
+	w.WriteHeader(e.GetHttpCode())
+	w.Write(respByt)
+}
+
 // Success use to response success data
 func Success(w http.ResponseWriter, data any, opts ...Option) {
 	c := 0
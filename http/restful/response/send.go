@@ -1,6 +1,7 @@
 package response
 
 import (
+	"encoding/json"
 	"net/http"
 
 	"github.com/emicklei/go-restful/v3"
@@ -29,6 +30,27 @@ func Failed(w *restful.Response, err error, opts ...response.Option) {
 		e.WithNamespace(application.Get().AppName)
 	}
 
+	// 按请求语言返回错误信息
+	d := &response.Data{}
+	for _, opt := range opts {
+		opt.Apply(d)
+	}
+	e = exception.Localize(e, d.AcceptLanguage())
+
+	// RFC 7807, 需要通过response.WithRequest传入请求才能协商
+	accept, instance := "", ""
+	if r := d.Request(); r != nil {
+		accept, instance = r.Header.Get("Accept"), r.URL.Path
+	}
+	if exception.WantProblemDetails(accept) {
+		w.Header().Set(restful.HEADER_ContentType, exception.MIME_PROBLEM_JSON)
+		w.WriteHeader(e.GetHttpCode())
+		if err := json.NewEncoder(w).Encode(e.ToProblem(instance)); err != nil {
+			log.L().Error().Msgf("send failed response error, %s", err)
+		}
+		return
+	}
+
 	err = w.WriteHeaderAndEntity(e.GetHttpCode(), e)
 	if err != nil {
 		log.L().Error().Msgf("send failed response error, %s", err)
@@ -0,0 +1,90 @@
+package gin
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/infraboard/mcube/v2/http/gin/response"
+	"github.com/infraboard/mcube/v2/ioc"
+	"github.com/infraboard/mcube/v2/ioc/apps/cron"
+	ioc_gin "github.com/infraboard/mcube/v2/ioc/config/gin"
+	"github.com/infraboard/mcube/v2/ioc/config/http"
+	"github.com/infraboard/mcube/v2/ioc/config/log"
+	"github.com/rs/zerolog"
+)
+
+func init() {
+	ioc.Api().Registry(&CronHandler{})
+}
+
+type CronHandler struct {
+	ioc.ObjectImpl
+	log *zerolog.Logger
+
+	// 注册手动触发接口, 接口本身没有认证, 开启前需要通过网关或者认证中间件保护
+	EnableTrigger bool `json:"enable_trigger" yaml:"enable_trigger" toml:"enable_trigger" env:"ENABLE_TRIGGER"`
+}
+
+func (h *CronHandler) Name() string {
+	return cron.AppName
+}
+
+func (h *CronHandler) Version() string {
+	return "v1"
+}
+
+func (h *CronHandler) Init() error {
+	h.log = log.Sub(cron.AppName)
+	h.Registry()
+	return nil
+}
+
+func (h *CronHandler) Registry() {
+	r := ioc_gin.ObjectRouter(h)
+	r.GET("/jobs", h.ListJobs)
+	r.GET("/jobs/:name", h.DescribeJob)
+	if h.EnableTrigger {
+		r.POST("/jobs/:name/trigger", h.TriggerJob)
+		h.log.Warn().Msgf("cron trigger api enabled, protect it with authentication")
+	}
+	r.GET("/jobs/:name/runs", h.ListRuns)
+
+	h.log.Info().Msgf("Get the Cron Jobs using %s/jobs", http.Get().ApiObjectAddr(h))
+}
+
+func (h *CronHandler) ListJobs(c *gin.Context) {
+	count := cron.ParseCount(c.Query("count"), cron.DEFAULT_NEXT_COUNT)
+	response.Success(c, cron.ListJobs(count))
+}
+
+func (h *CronHandler) DescribeJob(c *gin.Context) {
+	count := cron.ParseCount(c.Query("count"), cron.DEFAULT_NEXT_COUNT)
+	job, err := cron.DescribeJob(c.Param("name"), count)
+	if err != nil {
+		response.Failed(c, err)
+		return
+	}
+	response.Success(c, job)
+}
+
+func (h *CronHandler) TriggerJob(c *gin.Context) {
+	name := c.Param("name")
+	if err := cron.TriggerJob(name); err != nil {
+		response.Failed(c, err)
+		return
+	}
+	job, err := cron.DescribeJob(name, cron.DEFAULT_NEXT_COUNT)
+	if err != nil {
+		response.Failed(c, err)
+		return
+	}
+	response.Success(c, job)
+}
+
+func (h *CronHandler) ListRuns(c *gin.Context) {
+	limit := cron.ParseCount(c.Query("limit"), cron.DEFAULT_RUN_LIMIT)
+	runs, err := cron.ListRuns(c.Request.Context(), c.Param("name"), limit)
+	if err != nil {
+		response.Failed(c, err)
+		return
+	}
+	response.Success(c, runs)
+}
@@ -0,0 +1,109 @@
+package cron
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/infraboard/mcube/v2/exception"
+	"github.com/infraboard/mcube/v2/ioc/config/mcron"
+)
+
+const (
+	AppName = "cron"
+)
+
+const (
+	// 默认返回的后续调度次数
+	DEFAULT_NEXT_COUNT = 5
+	// 默认返回的执行记录数量
+	DEFAULT_RUN_LIMIT = 20
+	// 后续调度次数与执行记录数量的上限
+	MAX_COUNT = 100
+)
+
+func NewJob(j *mcron.Job, count int) *Job {
+	return &Job{
+		Name:        j.Name,
+		Spec:        j.Spec,
+		Description: j.Description,
+		Singleton:   j.Singleton,
+		Timeout:     j.Timeout.String(),
+		Running:     j.Running(),
+		Prev:        j.Prev(),
+		NextRuns:    j.NextN(count),
+	}
+}
+
+// Job 任务的调度信息
+type Job struct {
+	// 任务名称
+	Name string `json:"name"`
+	// cron表达式
+	Spec string `json:"spec"`
+	// 任务描述
+	Description string `json:"description"`
+	// 是否为单例任务
+	Singleton bool `json:"singleton"`
+	// 单次执行的超时时间
+	Timeout string `json:"timeout"`
+	// 是否正在本实例执行
+	Running bool `json:"running"`
+	// 上一次调度的时间
+	Prev time.Time `json:"prev"`
+	// 后续的调度时间
+	NextRuns []time.Time `json:"next_runs"`
+}
+
+// ListJobs 所有任务的调度信息, count为每个任务返回的后续调度次数
+func ListJobs(count int) []*Job {
+	jobs := []*Job{}
+	for _, j := range mcron.GetScheduler().Jobs() {
+		jobs = append(jobs, NewJob(j, count))
+	}
+	return jobs
+}
+
+func DescribeJob(name string, count int) (*Job, error) {
+	j := mcron.GetScheduler().Job(name)
+	if j == nil {
+		return nil, exception.NewNotFound("cron job %s not found", name)
+	}
+	return NewJob(j, count), nil
+}
+
+// TriggerJob 在本实例立即执行一次任务
+func TriggerJob(name string) error {
+	err := mcron.GetScheduler().Trigger(name)
+	switch {
+	case errors.Is(err, mcron.ErrJobNotFound):
+		return exception.NewNotFound("cron job %s not found", name)
+	case errors.Is(err, mcron.ErrJobRunning):
+		return exception.NewConflict("cron job %s is running", name)
+	case err != nil:
+		return exception.NewBadRequest("%s", err)
+	}
+	return nil
+}
+
+// ListRuns 任务最近的执行记录, 需要开启执行历史
+func ListRuns(ctx context.Context, name string, limit int) ([]*mcron.Run, error) {
+	s := mcron.GetScheduler()
+	if s.Job(name) == nil {
+		return nil, exception.NewNotFound("cron job %s not found", name)
+	}
+	if !s.History {
+		return nil, exception.NewBadRequest("cron history not enabled")
+	}
+	return s.ListRuns(ctx, name, limit)
+}
+
+// ParseCount 解析查询参数中的数量, 为空或者不合法时使用默认值, 最大为MAX_COUNT
+func ParseCount(value string, defaultValue int) int {
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return defaultValue
+	}
+	return min(n, MAX_COUNT)
+}
@@ -0,0 +1,111 @@
+package restful
+
+import (
+	restfulspec "github.com/emicklei/go-restful-openapi/v2"
+	"github.com/emicklei/go-restful/v3"
+	"github.com/infraboard/mcube/v2/http/restful/response"
+	"github.com/infraboard/mcube/v2/ioc"
+	"github.com/infraboard/mcube/v2/ioc/apps/cron"
+	"github.com/infraboard/mcube/v2/ioc/config/gorestful"
+	"github.com/infraboard/mcube/v2/ioc/config/http"
+	"github.com/infraboard/mcube/v2/ioc/config/log"
+	"github.com/infraboard/mcube/v2/ioc/config/mcron"
+	"github.com/rs/zerolog"
+)
+
+func init() {
+	ioc.Api().Registry(&CronHandler{})
+}
+
+type CronHandler struct {
+	ioc.ObjectImpl
+	log *zerolog.Logger
+
+	// 注册手动触发接口, 接口本身没有认证, 开启前需要通过网关或者认证中间件保护
+	EnableTrigger bool `json:"enable_trigger" yaml:"enable_trigger" toml:"enable_trigger" env:"ENABLE_TRIGGER"`
+}
+
+func (h *CronHandler) Name() string {
+	return cron.AppName
+}
+
+func (h *CronHandler) Version() string {
+	return "v1"
+}
+
+func (h *CronHandler) Init() error {
+	h.log = log.Sub(cron.AppName)
+	h.Registry()
+	return nil
+}
+
+func (h *CronHandler) Registry() {
+	tags := []string{"定时任务"}
+
+	ws := gorestful.ObjectRouter(h)
+	ws.Route(ws.GET("/jobs").To(h.ListJobs).
+		Doc("查询定时任务").
+		Param(ws.QueryParameter("count", "返回的后续调度次数").DataType("integer")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Returns(200, "OK", []cron.Job{}))
+	ws.Route(ws.GET("/jobs/{name}").To(h.DescribeJob).
+		Doc("查询定时任务详情").
+		Param(ws.PathParameter("name", "任务名称")).
+		Param(ws.QueryParameter("count", "返回的后续调度次数").DataType("integer")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Returns(200, "OK", cron.Job{}))
+	if h.EnableTrigger {
+		ws.Route(ws.POST("/jobs/{name}/trigger").To(h.TriggerJob).
+			Doc("立即执行一次定时任务").
+			Param(ws.PathParameter("name", "任务名称")).
+			Metadata(restfulspec.KeyOpenAPITags, tags))
+		h.log.Warn().Msgf("cron trigger api enabled, protect it with authentication")
+	}
+	ws.Route(ws.GET("/jobs/{name}/runs").To(h.ListRuns).
+		Doc("查询定时任务的执行记录").
+		Param(ws.PathParameter("name", "任务名称")).
+		Param(ws.QueryParameter("limit", "返回的记录数量").DataType("integer")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Returns(200, "OK", []mcron.Run{}))
+
+	h.log.Info().Msgf("Get the Cron Jobs using %s/jobs", http.Get().ApiObjectAddr(h))
+}
+
+func (h *CronHandler) ListJobs(r *restful.Request, w *restful.Response) {
+	count := cron.ParseCount(r.QueryParameter("count"), cron.DEFAULT_NEXT_COUNT)
+	response.Success(w, cron.ListJobs(count))
+}
+
+func (h *CronHandler) DescribeJob(r *restful.Request, w *restful.Response) {
+	count := cron.ParseCount(r.QueryParameter("count"), cron.DEFAULT_NEXT_COUNT)
+	job, err := cron.DescribeJob(r.PathParameter("name"), count)
+	if err != nil {
+		response.Failed(w, err)
+		return
+	}
+	response.Success(w, job)
+}
+
+func (h *CronHandler) TriggerJob(r *restful.Request, w *restful.Response) {
+	name := r.PathParameter("name")
+	if err := cron.TriggerJob(name); err != nil {
+		response.Failed(w, err)
+		return
+	}
+	job, err := cron.DescribeJob(name, cron.DEFAULT_NEXT_COUNT)
+	if err != nil {
+		response.Failed(w, err)
+		return
+	}
+	response.Success(w, job)
+}
+
+func (h *CronHandler) ListRuns(r *restful.Request, w *restful.Response) {
+	limit := cron.ParseCount(r.QueryParameter("limit"), cron.DEFAULT_RUN_LIMIT)
+	runs, err := cron.ListRuns(r.Request.Context(), r.PathParameter("name"), limit)
+	if err != nil {
+		response.Failed(w, err)
+		return
+	}
+	response.Success(w, runs)
+}
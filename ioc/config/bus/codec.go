@@ -0,0 +1,105 @@
+package bus
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	CONTENT_TYPE_JSON     = "application/json"
+	CONTENT_TYPE_PROTOBUF = "application/protobuf"
+	CONTENT_TYPE_MSGPACK  = "application/msgpack"
+)
+
+var (
+	codecs  = map[string]Codec{}
+	codecMu sync.RWMutex
+)
+
+func init() {
+	RegistryCodec(JSON)
+	RegistryCodec(Protobuf)
+	RegistryCodec(Msgpack)
+}
+
+// Codec 事件数据的编解码, ContentType保存在事件的Content-Type Header中, 订阅方根据它选择解码方式
+type Codec interface {
+	ContentType() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// RegistryCodec 注册编解码, 相同ContentType的编解码会被覆盖
+func RegistryCodec(c Codec) {
+	codecMu.Lock()
+	defer codecMu.Unlock()
+	codecs[c.ContentType()] = c
+}
+
+// GetCodec 根据ContentType获取编解码, 没有注册时返回nil
+func GetCodec(contentType string) Codec {
+	codecMu.RLock()
+	defer codecMu.RUnlock()
+	return codecs[contentType]
+}
+
+var (
+	JSON     Codec = jsonCodec{}
+	Protobuf Codec = protobufCodec{}
+	Msgpack  Codec = msgpackCodec{}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string {
+	return CONTENT_TYPE_JSON
+}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// 事件类型需要是proto.Message, 比如pb目录下生成的类型
+type protobufCodec struct{}
+
+func (protobufCodec) ContentType() string {
+	return CONTENT_TYPE_PROTOBUF
+}
+
+func (protobufCodec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("protobuf codec: %T is not proto.Message", v)
+	}
+	return proto.Marshal(m)
+}
+
+func (protobufCodec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("protobuf codec: %T is not proto.Message", v)
+	}
+	return proto.Unmarshal(data, m)
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) ContentType() string {
+	return CONTENT_TYPE_MSGPACK
+}
+
+func (msgpackCodec) Marshal(v any) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v any) error {
+	return msgpack.Unmarshal(data, v)
+}
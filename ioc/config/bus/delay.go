@@ -0,0 +1,125 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/infraboard/mcube/v2/ioc"
+)
+
+const (
+	// 基于Redis有序集合的延迟投递, 提供方不支持延迟投递时使用
+	DELAY_APP_NAME = "bus_delay"
+)
+
+const (
+	// 延迟事件的目标主题, 提供方通过中转主题实现延迟时使用
+	HEADER_DELAY_SUBJECT = "X-Delay-Subject"
+	// 延迟事件的投递时间, unix毫秒
+	HEADER_DELAY_UNTIL = "X-Delay-Until"
+)
+
+var (
+	// ErrDelayNotSupported 提供方不支持或者没有开启延迟投递, 并且没有引入ioc/config/bus/delay
+	ErrDelayNotSupported = errors.New("bus: delayed publish not supported")
+)
+
+// DelayPublisher 延迟投递, at已经过去时立即投递, 事件的Header与Trace上下文在投递时保留
+type DelayPublisher interface {
+	PublishAt(ctx context.Context, e *Event, at time.Time) error
+}
+
+// PublishAt 在at时间投递事件, 优先使用提供方的延迟投递,
+// 提供方不支持时使用ioc/config/bus/delay, 都不可用时返回ErrDelayNotSupported
+func PublishAt(ctx context.Context, e *Event, at time.Time) error {
+	if d, ok := GetService().(DelayPublisher); ok {
+		err := d.PublishAt(ctx, e, at)
+		if !errors.Is(err, ErrDelayNotSupported) {
+			return err
+		}
+	}
+	if d, ok := ioc.Config().Get(DELAY_APP_NAME).(DelayPublisher); ok {
+		return d.PublishAt(ctx, e, at)
+	}
+	return ErrDelayNotSupported
+}
+
+// PublishAfter 在d时间之后投递事件
+func PublishAfter(ctx context.Context, e *Event, d time.Duration) error {
+	return PublishAt(ctx, e, time.Now().Add(d))
+}
+
+// DefaultDelayLevels 提供方通过固定级别实现延迟投递时的默认级别, 单位秒
+func DefaultDelayLevels() []int64 {
+	return []int64{1, 5, 10, 30, 60, 300, 600, 1800, 3600, 7200}
+}
+
+// DelayLevels 把配置的级别(单位秒)转换为升序的时长, 忽略不大于0的级别, 没有有效的级别时使用1秒
+func DelayLevels(levels []int64) []time.Duration {
+	ds := make([]time.Duration, 0, len(levels))
+	for _, l := range levels {
+		if l > 0 {
+			ds = append(ds, time.Duration(l)*time.Second)
+		}
+	}
+	if len(ds) == 0 {
+		ds = append(ds, time.Second)
+	}
+	slices.Sort(ds)
+	return ds
+}
+
+// DelayLevel 从升序的级别中选择不超过延迟时间的最大级别, 延迟时间小于最小级别时使用最小级别,
+// 消息在级别到期后按照剩余的延迟时间转发到下一个级别或者投递
+func DelayLevel(levels []time.Duration, delay time.Duration) time.Duration {
+	level := levels[0]
+	for _, l := range levels {
+		if l > delay {
+			break
+		}
+		level = l
+	}
+	return level
+}
+
+// DelayHeader 延迟事件的Header, 不修改调用方的Header
+func DelayHeader(e *Event, at time.Time) map[string][]string {
+	header := make(map[string][]string, len(e.Header)+2)
+	for k, v := range e.Header {
+		header[k] = v
+	}
+	header[HEADER_DELAY_SUBJECT] = []string{e.Subject}
+	header[HEADER_DELAY_UNTIL] = []string{strconv.FormatInt(at.UnixMilli(), 10)}
+	return header
+}
+
+// ParseDelayHeader 从延迟事件的Header中解析目标主题与投递时间, 返回去掉延迟Header的事件
+func ParseDelayHeader(e *Event) (*Event, time.Time, error) {
+	subject := EventCarrier(e.Header).Get(HEADER_DELAY_SUBJECT)
+	if subject == "" {
+		return nil, time.Time{}, errors.New("bus: delay subject header missing")
+	}
+	ms, err := strconv.ParseInt(EventCarrier(e.Header).Get(HEADER_DELAY_UNTIL), 10, 64)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("bus: parse delay until header error, %w", err)
+	}
+
+	// 提供方可能修改Header的大小写
+	header := make(map[string][]string, len(e.Header))
+	for k, v := range e.Header {
+		if strings.EqualFold(k, HEADER_DELAY_SUBJECT) || strings.EqualFold(k, HEADER_DELAY_UNTIL) {
+			continue
+		}
+		header[k] = v
+	}
+
+	c := *e
+	c.Subject = subject
+	c.Header = header
+	return &c, time.UnixMilli(ms), nil
+}
@@ -0,0 +1,252 @@
+package delay
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/infraboard/mcube/v2/ioc"
+	"github.com/infraboard/mcube/v2/ioc/config/bus"
+	"github.com/infraboard/mcube/v2/ioc/config/log"
+	ioc_redis "github.com/infraboard/mcube/v2/ioc/config/redis"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/xid"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+)
+
+func init() {
+	ioc.Config().Registry(defaultConfig)
+}
+
+var defaultConfig = New()
+
+// New 基于Redis有序集合的延迟投递, 默认使用ioc中的redis与bus
+func New() *Delay {
+	nop := zerolog.Nop()
+	return &Delay{
+		Key:       "mcube:bus:delay",
+		Relay:     true,
+		Interval:  1000,
+		BatchSize: 100,
+		Lease:     30,
+		log:       &nop,
+	}
+}
+
+// Delay 事件保存在有序集合中, 分数为投递时间, 所有实例的Relay都可以投递,
+// 取出到期的事件时把分数推迟Lease, 投递成功后删除, 实例宕机时事件在Lease之后被其他实例重新投递
+type Delay struct {
+	ioc.ObjectImpl
+	log *zerolog.Logger
+
+	// 保存延迟事件的有序集合
+	Key string `json:"key" yaml:"key" toml:"key" env:"KEY"`
+	// 是否启动Relay投递到期的事件
+	Relay bool `json:"relay" yaml:"relay" toml:"relay" env:"RELAY"`
+	// 扫描到期事件的间隔, 单位毫秒
+	Interval int64 `json:"interval" yaml:"interval" toml:"interval" env:"INTERVAL"`
+	// 每次投递的事件数量
+	BatchSize int `json:"batch_size" yaml:"batch_size" toml:"batch_size" env:"BATCH_SIZE"`
+	// 取出的事件在该时间内没有投递成功时重新投递, 单位秒
+	Lease int64 `json:"lease" yaml:"lease" toml:"lease" env:"LEASE"`
+
+	client    redis.UniversalClient
+	publisher bus.Publisher
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// 保存在有序集合中的事件, Id保证相同内容的事件不会被合并
+type envelope struct {
+	Id      string              `json:"id"`
+	Subject string              `json:"subject"`
+	Key     string              `json:"key,omitempty"`
+	Header  map[string][]string `json:"header,omitempty"`
+	Data    []byte              `json:"data,omitempty"`
+}
+
+func (e *envelope) Event() *bus.Event {
+	return &bus.Event{
+		Subject: e.Subject,
+		Key:     e.Key,
+		Header:  e.Header,
+		Data:    e.Data,
+	}
+}
+
+// 取出到期的事件, 并把分数推迟到租约到期的时间
+var claimScript = redis.NewScript(`
+local items = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, item in ipairs(items) do
+	redis.call('ZADD', KEYS[1], ARGV[3], item)
+end
+return items
+`)
+
+func (d *Delay) Name() string {
+	return AppName
+}
+
+func (d *Delay) Priority() int {
+	return PRIORITY
+}
+
+func (d *Delay) Init() error {
+	d.log = log.Sub(d.Name())
+	if d.Relay {
+		d.Start(context.Background())
+	}
+	return nil
+}
+
+// Close 停止Relay, 需要在bus关闭之前完成
+func (d *Delay) Close(ctx context.Context) {
+	d.Stop(ctx)
+}
+
+// SetClient 使用指定的Redis, 而不是ioc中的redis
+func (d *Delay) SetClient(client redis.UniversalClient) *Delay {
+	d.client = client
+	return d
+}
+
+// SetPublisher 使用指定的Publisher投递事件, 而不是ioc中的bus
+func (d *Delay) SetPublisher(p bus.Publisher) *Delay {
+	d.publisher = p
+	return d
+}
+
+// SetLogger 设置日志
+func (d *Delay) SetLogger(l *zerolog.Logger) *Delay {
+	d.log = l
+	return d
+}
+
+func (d *Delay) getClient() redis.UniversalClient {
+	if d.client != nil {
+		return d.client
+	}
+	return ioc_redis.Client()
+}
+
+func (d *Delay) getPublisher() bus.Publisher {
+	if d.publisher != nil {
+		return d.publisher
+	}
+	return bus.GetService()
+}
+
+// PublishAt 保存事件, 当前的Trace上下文写入Header, 投递时作为发送Span的父Span
+func (d *Delay) PublishAt(ctx context.Context, e *bus.Event, at time.Time) error {
+	header := make(map[string][]string, len(e.Header)+2)
+	for k, v := range e.Header {
+		header[k] = v
+	}
+	otel.GetTextMapPropagator().Inject(ctx, bus.EventCarrier(header))
+
+	member, err := json.Marshal(&envelope{
+		Id:      xid.New().String(),
+		Subject: e.Subject,
+		Key:     e.Key,
+		Header:  header,
+		Data:    e.Data,
+	})
+	if err != nil {
+		return err
+	}
+	return d.getClient().ZAdd(ctx, d.Key, redis.Z{
+		Score:  float64(at.UnixMilli()),
+		Member: string(member),
+	}).Err()
+}
+
+// Pending 等待投递的事件数量
+func (d *Delay) Pending(ctx context.Context) (int64, error) {
+	return d.getClient().ZCard(ctx, d.Key).Result()
+}
+
+// Start 启动Relay, ctx取消或者Stop后停止
+func (d *Delay) Start(ctx context.Context) {
+	if d.cancel != nil {
+		return
+	}
+	ctx, d.cancel = context.WithCancel(ctx)
+	d.done = make(chan struct{})
+	go d.run(ctx, d.done)
+}
+
+// Stop 停止Relay, 等待正在投递的批次完成
+func (d *Delay) Stop(ctx context.Context) {
+	if d.cancel == nil {
+		return
+	}
+	d.cancel()
+	select {
+	case <-d.done:
+	case <-ctx.Done():
+	}
+	d.cancel = nil
+}
+
+func (d *Delay) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(time.Duration(max(d.Interval, 1)) * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// 一批全部投递时继续投递下一批
+		for {
+			n, err := d.RelayOnce(ctx)
+			if err != nil {
+				d.log.Error().Msgf("relay delayed events error, %s", err)
+			}
+			if err != nil || n < max(d.BatchSize, 1) {
+				break
+			}
+		}
+	}
+}
+
+// RelayOnce 投递一批到期的事件, 返回取出的数量, 投递失败的事件在Lease之后重试,
+// 投递成功后删除失败时事件会被重复投递, 订阅方需要做幂等处理
+func (d *Delay) RelayOnce(ctx context.Context) (int, error) {
+	now := time.Now()
+	lease := now.Add(time.Duration(max(d.Lease, 1)) * time.Second)
+	items, err := claimScript.Run(ctx, d.getClient(), []string{d.Key},
+		now.UnixMilli(), max(d.BatchSize, 1), lease.UnixMilli()).StringSlice()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, item := range items {
+		env := &envelope{}
+		if err := json.Unmarshal([]byte(item), env); err != nil {
+			d.log.Error().Msgf("drop invalid delayed event, %s", err)
+			d.remove(ctx, item)
+			continue
+		}
+
+		e := env.Event()
+		pctx := otel.GetTextMapPropagator().Extract(ctx, bus.EventCarrier(e.Header))
+		if err := d.getPublisher().Publish(pctx, e); err != nil {
+			d.log.Error().Msgf("publish delayed event %s error, retry after %ds, %s", e.Subject, d.Lease, err)
+			continue
+		}
+		d.remove(ctx, item)
+	}
+	return len(items), nil
+}
+
+func (d *Delay) remove(ctx context.Context, item string) {
+	if err := d.getClient().ZRem(context.WithoutCancel(ctx), d.Key, item).Err(); err != nil {
+		d.log.Warn().Msgf("remove delayed event error, %s", err)
+	}
+}
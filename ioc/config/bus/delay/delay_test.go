@@ -0,0 +1,150 @@
+package delay_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/infraboard/mcube/v2/ioc/config/bus"
+	"github.com/infraboard/mcube/v2/ioc/config/bus/delay"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 记录发送的事件, fail不为空时发送失败
+type recorder struct {
+	mu     sync.Mutex
+	events []*bus.Event
+	fail   error
+}
+
+func (r *recorder) Publish(ctx context.Context, e *bus.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fail != nil {
+		return r.fail
+	}
+	r.events = append(r.events, e)
+	return nil
+}
+
+func (r *recorder) received() []*bus.Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*bus.Event(nil), r.events...)
+}
+
+func newDelay(t *testing.T, p bus.Publisher) *delay.Delay {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return delay.New().SetClient(client).SetPublisher(p)
+}
+
+func TestPublishAt(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+
+	traceId, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanId, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithRemoteSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceId,
+		SpanID:     spanId,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	r := &recorder{}
+	d := newDelay(t, r)
+	header := map[string][]string{"X-Tenant": {"t1"}}
+	if err := d.PublishAt(ctx, &bus.Event{Subject: "order.timeout", Key: "o1", Header: header, Data: []byte("o1")}, time.Now().Add(100*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if len(header) != 1 {
+		t.Fatalf("caller header modified, %v", header)
+	}
+
+	// 未到期
+	if n, err := d.RelayOnce(ctx); err != nil || n != 0 {
+		t.Fatalf("want 0 relayed before due, got %d %v", n, err)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if n, err := d.RelayOnce(ctx); err != nil || n != 1 {
+		t.Fatalf("want 1 relayed, got %d %v", n, err)
+	}
+	events := r.received()
+	if len(events) != 1 {
+		t.Fatalf("want 1 event, got %d", len(events))
+	}
+	e := events[0]
+	if e.Subject != "order.timeout" || e.Key != "o1" || string(e.Data) != "o1" || bus.EventCarrier(e.Header).Get("X-Tenant") != "t1" {
+		t.Fatalf("unexpected event %+v", e)
+	}
+	if tp := bus.EventCarrier(e.Header).Get("traceparent"); !strings.Contains(tp, traceId.String()) {
+		t.Fatalf("trace context not preserved, traceparent %q", tp)
+	}
+	if pending, err := d.Pending(ctx); err != nil || pending != 0 {
+		t.Fatalf("want 0 pending, got %d %v", pending, err)
+	}
+}
+
+func TestRelayRetry(t *testing.T) {
+	ctx := context.Background()
+	r := &recorder{fail: errors.New("broker down")}
+	d := newDelay(t, r)
+	d.Lease = 1
+
+	if err := d.PublishAt(ctx, &bus.Event{Subject: "reminder"}, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := d.RelayOnce(ctx); err != nil || n != 1 {
+		t.Fatalf("want 1 claimed, got %d %v", n, err)
+	}
+	// 投递失败的事件在租约到期前不会被再次取出
+	if n, err := d.RelayOnce(ctx); err != nil || n != 0 {
+		t.Fatalf("want 0 claimed during lease, got %d %v", n, err)
+	}
+	if pending, _ := d.Pending(ctx); pending != 1 {
+		t.Fatalf("want 1 pending, got %d", pending)
+	}
+
+	r.mu.Lock()
+	r.fail = nil
+	r.mu.Unlock()
+	time.Sleep(1100 * time.Millisecond)
+	if n, err := d.RelayOnce(ctx); err != nil || n != 1 {
+		t.Fatalf("want 1 relayed after lease, got %d %v", n, err)
+	}
+	if len(r.received()) != 1 {
+		t.Fatal("event not relayed after lease")
+	}
+}
+
+func TestRelay(t *testing.T) {
+	r := &recorder{}
+	d := newDelay(t, r)
+	d.Interval = 10
+
+	ctx := context.Background()
+	d.Start(ctx)
+	defer d.Stop(ctx)
+
+	for i := range 3 {
+		if err := d.PublishAt(ctx, &bus.Event{Subject: "batch"}, time.Now().Add(time.Duration(i)*20*time.Millisecond)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(r.received()) < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("want 3 relayed, got %d", len(r.received()))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,36 @@
+package delay
+
+import (
+	"context"
+	"time"
+
+	"github.com/infraboard/mcube/v2/ioc"
+	"github.com/infraboard/mcube/v2/ioc/config/bus"
+)
+
+const (
+	AppName = bus.DELAY_APP_NAME
+)
+
+const (
+	// 依赖redis与bus, 需要在它们之后初始化
+	PRIORITY = 596
+)
+
+func Get() *Delay {
+	obj := ioc.Config().Get(AppName)
+	if obj == nil {
+		return defaultConfig
+	}
+	return obj.(*Delay)
+}
+
+// PublishAt 把事件保存到Redis有序集合, 到期后由Relay投递到bus, 不使用提供方的延迟投递
+func PublishAt(ctx context.Context, e *bus.Event, at time.Time) error {
+	return Get().PublishAt(ctx, e, at)
+}
+
+// PublishAfter 在d时间之后投递事件
+func PublishAfter(ctx context.Context, e *bus.Event, d time.Duration) error {
+	return Get().PublishAt(ctx, e, time.Now().Add(d))
+}
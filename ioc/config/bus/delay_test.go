@@ -0,0 +1,32 @@
+package bus_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/infraboard/mcube/v2/ioc/config/bus"
+)
+
+func TestDelayLevel(t *testing.T) {
+	levels := bus.DelayLevels([]int64{60, 0, 5, 1, -1})
+	if len(levels) != 3 || levels[0] != time.Second || levels[2] != time.Minute {
+		t.Fatalf("unexpected levels %v", levels)
+	}
+
+	cases := map[time.Duration]time.Duration{
+		500 * time.Millisecond: time.Second,
+		7 * time.Second:        5 * time.Second,
+		time.Minute:            time.Minute,
+		time.Hour:              time.Minute,
+	}
+	for delay, want := range cases {
+		if got := bus.DelayLevel(levels, delay); got != want {
+			t.Fatalf("delay %s: expect level %s, got %s", delay, want, got)
+		}
+	}
+
+	// 没有有效的级别时使用1秒
+	if levels := bus.DelayLevels(nil); len(levels) != 1 || levels[0] != time.Second {
+		t.Fatalf("unexpected levels %v", levels)
+	}
+}
@@ -1,7 +1,63 @@
 package bus
 
+import (
+	"context"
+	"strings"
+)
+
 type Event struct {
 	Subject string
-	Header  map[string][]string
-	Data    []byte
+	// 消息键, kafka中相同键的事件发送到同一个分区并保证顺序, 其他提供方忽略
+	Key    string
+	Header map[string][]string
+	Data   []byte
+
+	ctx context.Context
+}
+
+// Context 消费时为消费Span的上下文, 未设置时返回context.Background()
+func (e *Event) Context() context.Context {
+	if e.ctx != nil {
+		return e.ctx
+	}
+	return context.Background()
+}
+
+// WithContext 返回设置了ctx的浅拷贝
+func (e *Event) WithContext(ctx context.Context) *Event {
+	c := *e
+	c.ctx = ctx
+	return &c
+}
+
+// EventCarrier 在Event.Header中读写Trace上下文, 读取时忽略key的大小写, 兼容不同提供方对Header的处理
+type EventCarrier map[string][]string
+
+func (c EventCarrier) Get(key string) string {
+	if v := c[key]; len(v) > 0 {
+		return v[0]
+	}
+	for k, v := range c {
+		if strings.EqualFold(k, key) && len(v) > 0 {
+			return v[0]
+		}
+	}
+	return ""
+}
+
+func (c EventCarrier) Set(key string, value string) {
+	for k := range c {
+		if k != key && strings.EqualFold(k, key) {
+			delete(c, k)
+		}
+	}
+	c[key] = []string{value}
+}
+
+func (c EventCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
 }
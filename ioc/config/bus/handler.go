@@ -0,0 +1,136 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	// 死信事件的Header, 记录失败的原因
+	// 原始主题
+	HEADER_DEAD_LETTER_SUBJECT = "X-Dead-Letter-Subject"
+	// 最后一次处理的错误
+	HEADER_DEAD_LETTER_ERROR = "X-Dead-Letter-Error"
+	// 处理次数
+	HEADER_DEAD_LETTER_ATTEMPTS = "X-Dead-Letter-Attempts"
+	// 进入死信的时间, RFC3339格式
+	HEADER_DEAD_LETTER_TIME = "X-Dead-Letter-Time"
+)
+
+var (
+	// ErrRejected 事件被拒绝, 重新投递也无法处理, 不会重试, 有死信主题时发送到死信主题, 否则由提供方丢弃
+	ErrRejected = errors.New("bus: event rejected")
+)
+
+// Reject 标记事件被拒绝, 比如版本不被支持或者无法解码
+func Reject(err error) error {
+	return fmt.Errorf("%w, %w", ErrRejected, err)
+}
+
+// SubscribeOption 订阅选项
+type SubscribeOption func(*SubscribeOptions)
+
+// WithRetry 处理失败后最多重试maxRetries次, 第一次重试前等待backoff, 之后每次翻倍, 最大不超过maxBackoff
+func WithRetry(maxRetries int, backoff, maxBackoff time.Duration) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.MaxRetries = maxRetries
+		o.Backoff = backoff
+		o.MaxBackoff = maxBackoff
+	}
+}
+
+// WithDeadLetter 重试耗尽后把事件发送到死信主题, 失败信息保存在Event.Header中
+func WithDeadLetter(subject string) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.DeadLetterSubject = subject
+	}
+}
+
+func NewSubscribeOptions(opts ...SubscribeOption) *SubscribeOptions {
+	o := &SubscribeOptions{
+		Backoff:    100 * time.Millisecond,
+		MaxBackoff: 10 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+type SubscribeOptions struct {
+	// 处理失败后的重试次数, 0表示不重试
+	MaxRetries int
+	// 第一次重试前的等待时间, 之后每次翻倍
+	Backoff time.Duration
+	// 最大的等待时间
+	MaxBackoff time.Duration
+	// 死信主题, 为空时不发送
+	DeadLetterSubject string
+}
+
+// Handle 调用处理函数, 失败时按照选项重试, 重试耗尽后发送到死信主题,
+// 返回nil表示事件已经处理或者已经进入死信主题, 提供方可以确认(ack)该消息,
+// 否则由提供方决定是否重新投递(nack)
+func (o *SubscribeOptions) Handle(ctx context.Context, p Publisher, e *Event, cb EventHandler) error {
+	var err error
+	backoff := o.Backoff
+	attempts := 0
+	for {
+		attempts++
+		if err = safeHandle(cb, e); err == nil {
+			return nil
+		}
+		if attempts > o.MaxRetries || errors.Is(err, ErrRejected) {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w, %s", err, ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, o.MaxBackoff)
+	}
+
+	if o.DeadLetterSubject == "" || p == nil {
+		return err
+	}
+	// 死信事件的Trace链接到消费Span
+	if e.ctx != nil {
+		ctx = e.ctx
+	}
+	if dlErr := p.Publish(context.WithoutCancel(ctx), o.deadLetter(e, err, attempts)); dlErr != nil {
+		return fmt.Errorf("%w, publish to dead letter %s error, %s", err, o.DeadLetterSubject, dlErr)
+	}
+	return nil
+}
+
+func (o *SubscribeOptions) deadLetter(e *Event, err error, attempts int) *Event {
+	header := make(map[string][]string, len(e.Header)+4)
+	for k, v := range e.Header {
+		header[k] = v
+	}
+	header[HEADER_DEAD_LETTER_SUBJECT] = []string{e.Subject}
+	header[HEADER_DEAD_LETTER_ERROR] = []string{err.Error()}
+	header[HEADER_DEAD_LETTER_ATTEMPTS] = []string{strconv.Itoa(attempts)}
+	header[HEADER_DEAD_LETTER_TIME] = []string{time.Now().Format(time.RFC3339)}
+	return &Event{
+		Subject: o.DeadLetterSubject,
+		Key:     e.Key,
+		Header:  header,
+		Data:    e.Data,
+	}
+}
+
+// 处理函数panic时转换为错误
+func safeHandle(cb EventHandler, e *Event) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("handle event panic, %v", r)
+		}
+	}()
+	return cb(e)
+}
@@ -11,7 +11,8 @@ const (
 )
 
 const (
-	APP_PRIORITY = 595
+	// 缓存与锁等组件依赖总线, 需要先于它们初始化
+	APP_PRIORITY = 600
 )
 
 func GetService() Service {
@@ -29,10 +30,11 @@ type Publisher interface {
 }
 
 type SubScriber interface {
-	// 主题订阅, 应用的多个实例 都会收到一份消息(广播)
-	TopicSubscribe(ctx context.Context, subject string, cb EventHandler) error
+	// 主题订阅, 应用的多个实例 都会收到一份消息(广播), ctx取消或者Subscription.Unsubscribe后取消订阅
+	TopicSubscribe(ctx context.Context, subject string, cb EventHandler, opts ...SubscribeOption) (Subscription, error)
 	// 队列订阅, 默认应用名称为队列名称, 同一个队列中 只能收到一份消息
-	QueueSubscribe(ctx context.Context, subject string, cb EventHandler) error
+	QueueSubscribe(ctx context.Context, subject string, cb EventHandler, opts ...SubscribeOption) (Subscription, error)
 }
 
-type EventHandler func(*Event)
+// EventHandler 返回错误时按照订阅选项重试或者进入死信主题, 最终失败时由提供方nack
+type EventHandler func(*Event) error
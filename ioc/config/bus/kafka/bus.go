@@ -2,6 +2,7 @@ package kafka
 
 import (
 	"context"
+	"errors"
 	"os"
 	"sync"
 
@@ -16,26 +17,44 @@ import (
 
 func init() {
 	ioc.Config().Registry(&BusServiceImpl{
-		producer: map[string]*kafka.Writer{},
-		consumer: map[string]*kafka.Reader{},
+		producer:         map[string]*kafka.Writer{},
+		DelayTopicPrefix: "mcube.delay.",
+		DelayLevels:      bus.DefaultDelayLevels(),
 	})
 }
 
+const (
+	PROVIDER = "kafka"
+)
+
 var _ bus.Service = (*BusServiceImpl)(nil)
 
 type BusServiceImpl struct {
 	ioc.ObjectImpl
-	log *zerolog.Logger
+	log       *zerolog.Logger
+	telemetry *bus.Telemetry
 
 	// group 队列模式下的 队列名称或者消费组名称，一个组里面的实例消费一个队列
 	Group string `toml:"group" json:"group" yaml:"group"  env:"GROUP"`
 	// nodename 广播模式下的节点名称，默认hostname, 每个节点独立一个 节点队列: group.nodename
 	NodeName string `toml:"node_name" json:"node_name" yaml:"node_name" env:"NODE_NAME"`
 
+	// 采集发送与消费的Prometheus指标
+	Metric bool `toml:"metric" json:"metric" yaml:"metric"  env:"METRIC"`
+
+	// 开启延迟投递, 启动延迟主题的调度器
+	Delay bool `toml:"delay" json:"delay" yaml:"delay"  env:"DELAY"`
+	// 延迟主题的前缀, 主题名称为 前缀+group+级别
+	DelayTopicPrefix string `toml:"delay_topic_prefix" json:"delay_topic_prefix" yaml:"delay_topic_prefix"  env:"DELAY_TOPIC_PREFIX"`
+	// 延迟级别, 单位秒, 每个级别一个延迟主题
+	DelayLevels []int64 `toml:"delay_levels" json:"delay_levels" yaml:"delay_levels"  env:"DELAY_LEVELS" envSeparator:","`
+
 	sync.Mutex
-	hostname string
 	producer map[string]*kafka.Writer
-	consumer map[string]*kafka.Reader
+	subs     bus.Subscriptions
+
+	stopDelay context.CancelFunc
+	delayWg   sync.WaitGroup
 }
 
 func (b *BusServiceImpl) Name() string {
@@ -47,6 +66,14 @@ func (i *BusServiceImpl) Priority() int {
 }
 
 func (b *BusServiceImpl) Init() error {
+	b.telemetry = bus.NewTelemetry(PROVIDER)
+	if b.Metric {
+		m, err := bus.DefaultMetricCollector()
+		if err != nil {
+			return err
+		}
+		b.telemetry.SetMetric(m)
+	}
 	if b.Group == "" {
 		b.Group = application.Get().GetAppName()
 	}
@@ -60,16 +87,22 @@ func (b *BusServiceImpl) Init() error {
 		}
 		b.NodeName = hostname
 	}
+
+	if b.Delay {
+		return b.startDelayScheduler()
+	}
 	return nil
 }
 
+// Close 等待处理中的消息提交后关闭消费者, 再关闭生产者
 func (b *BusServiceImpl) Close(ctx context.Context) {
-	for _, p := range b.producer {
-		p.Close()
+	if err := b.subs.Drain(ctx); err != nil {
+		b.log.Error().Msgf("drain subscriptions error, %s", err)
 	}
+	b.stopDelayScheduler()
 
-	for _, c := range b.consumer {
-		c.Close()
+	for _, p := range b.producer {
+		p.Close()
 	}
 }
 
@@ -84,19 +117,15 @@ func (b *BusServiceImpl) GetProducer(topic string) *kafka.Writer {
 	return b.producer[topic]
 }
 
-func (b *BusServiceImpl) GetConsumer(group, topic string) *kafka.Reader {
-	b.Lock()
-	defer b.Unlock()
-
-	if _, ok := b.consumer[topic]; !ok {
-		p := ioc_kafka.Get().ConsumerGroup(group, []string{topic})
-		b.consumer[topic] = p
-	}
-	return b.consumer[topic]
-}
-
 // 事件发送
 func (b *BusServiceImpl) Publish(ctx context.Context, e *bus.Event) error {
+	ctx, e, end := b.telemetry.StartPublish(ctx, e)
+	err := b.publish(ctx, e)
+	end(err)
+	return err
+}
+
+func (b *BusServiceImpl) publish(ctx context.Context, e *bus.Event) error {
 	// Convert map[string][]string to []kafka.Header
 	var headers []kafka.Header
 	for k, vs := range e.Header {
@@ -107,60 +136,111 @@ func (b *BusServiceImpl) Publish(ctx context.Context, e *bus.Event) error {
 			})
 		}
 	}
-	return b.GetProducer(e.Subject).WriteMessages(ctx, kafka.Message{
+	if err := ioc_kafka.Get().EnsureTopic(ctx, e.Subject); err != nil {
+		return err
+	}
+
+	m := kafka.Message{
 		Headers: headers,
 		Value:   e.Data,
-	})
+	}
+	// 相同键的事件发送到同一个分区, 分区选择由生产者的balancer配置决定
+	if e.Key != "" {
+		m.Key = []byte(e.Key)
+	}
+	return b.GetProducer(e.Subject).WriteMessages(ctx, m)
 }
 
-// 订阅事件
-func (b *BusServiceImpl) TopicSubscribe(ctx context.Context, subject string, cb bus.EventHandler) error {
-	for {
-		m, err := b.GetConsumer(b.hostname, subject).ReadMessage(ctx)
-		if err != nil {
-			return err
-		}
+// 订阅事件, 每个节点使用独立的消费组
+func (b *BusServiceImpl) TopicSubscribe(ctx context.Context, subject string, cb bus.EventHandler, opts ...bus.SubscribeOption) (bus.Subscription, error) {
+	return b.subscribe(ctx, bus.SanitizeQueueName(b.Group+"."+b.NodeName+"."+subject), subject, cb, opts...)
+}
 
-		// 打印日志
-		b.log.Debug().Msgf("message at topic/partition/offset %v/%v/%v: %s = %s\n", m.Topic, m.Partition, m.Offset, string(m.Key), string(m.Value))
+// 订阅队列, 同一个应用的实例使用同一个消费组
+func (b *BusServiceImpl) QueueSubscribe(ctx context.Context, subject string, cb bus.EventHandler, opts ...bus.SubscribeOption) (bus.Subscription, error) {
+	return b.subscribe(ctx, bus.SanitizeQueueName(b.Group+"."+subject), subject, cb, opts...)
+}
 
-		// 事件转换
-		// Convert []kafka.Header to map[string][]string
-		headerMap := make(map[string][]string)
-		for _, h := range m.Headers {
-			headerMap[h.Key] = append(headerMap[h.Key], string(h.Value))
-		}
+// 每个订阅使用独立的Reader, 在后台消费, 取消订阅后停止拉取, 处理中的消息提交后关闭Reader
+func (b *BusServiceImpl) subscribe(ctx context.Context, group, topic string, cb bus.EventHandler, opts ...bus.SubscribeOption) (bus.Subscription, error) {
+	if err := ioc_kafka.Get().EnsureTopic(ctx, topic); err != nil {
+		return nil, err
+	}
+	sub, err := b.subs.New(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-		cb(&bus.Event{
-			Subject: m.Topic,
-			Header:  headerMap,
-			Data:    m.Value,
-		})
+	r := newGroupReader(group, []string{topic})
+	fetchCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	if err := sub.OnUnsubscribe(func() error { cancel(); return nil }); err != nil {
+		return nil, err
+	}
+	if err := sub.OnDrained(r.close); err != nil {
+		return nil, err
 	}
+
+	o := bus.NewSubscribeOptions(opts...)
+	go func() {
+		for {
+			err := b.consume(fetchCtx, sub, r.get(), o, cb)
+			if fetchCtx.Err() != nil {
+				return
+			}
+			if !errors.Is(err, errRedeliver) {
+				b.log.Error().Msgf("consume topic %s error, %s", topic, err)
+				_ = sub.Unsubscribe()
+				return
+			}
+			// 重新打开Reader, 从最后提交的offset开始消费
+			if sleep(fetchCtx, o.MaxBackoff) != nil || !r.reopen() {
+				return
+			}
+		}
+	}()
+	return sub, nil
 }
 
-// 订阅队列
-func (b *BusServiceImpl) QueueSubscribe(ctx context.Context, subject string, cb bus.EventHandler) error {
+// 处理完成后提交offset, Kafka无法对单条消息nack, 处理失败且没有进入死信主题时不提交,
+// 返回errRedeliver, 重新打开Reader后重新消费该消息
+func (b *BusServiceImpl) consume(ctx context.Context, sub *bus.TrackedSubscription, r *kafka.Reader, o *bus.SubscribeOptions, cb bus.EventHandler) error {
 	for {
-		m, err := b.GetConsumer(bus.SanitizeQueueName(b.Group+"."+b.NodeName+"."+subject), subject).ReadMessage(ctx)
+		m, err := r.FetchMessage(ctx)
+		if err != nil {
+			return err
+		}
+		// 订阅已经取消, 不提交offset, 由消费组中的其他成员重新消费
+		if !sub.Acquire() {
+			return nil
+		}
+		err = b.handle(ctx, o, r, m, cb)
+		sub.Release()
 		if err != nil {
 			return err
 		}
+	}
+}
 
-		// 打印日志
-		b.log.Debug().Msgf("message at topic/partition/offset %v/%v/%v: %s = %s\n", m.Topic, m.Partition, m.Offset, string(m.Key), string(m.Value))
+func (b *BusServiceImpl) handle(ctx context.Context, o *bus.SubscribeOptions, r *kafka.Reader, m kafka.Message, cb bus.EventHandler) error {
+	// 打印日志
+	b.log.Debug().Msgf("message at topic/partition/offset %v/%v/%v: %s = %s\n", m.Topic, m.Partition, m.Offset, string(m.Key), string(m.Value))
 
-		// 事件转换
-		// Convert []kafka.Header to map[string][]string
-		headerMap := make(map[string][]string)
-		for _, h := range m.Headers {
-			headerMap[h.Key] = append(headerMap[h.Key], string(h.Value))
+	err := b.telemetry.Consume(ctx, event(m), func(e *bus.Event) error {
+		return o.Handle(ctx, b, e, cb)
+	})
+	if err != nil {
+		// 取消订阅导致重试中断时不提交, 由消费组中的其他成员重新消费
+		if ctx.Err() != nil {
+			return nil
 		}
-
-		cb(&bus.Event{
-			Subject: m.Topic,
-			Header:  headerMap,
-			Data:    m.Value,
-		})
+		// 被拒绝的事件重新投递也无法处理, 提交后丢弃
+		if !errors.Is(err, bus.ErrRejected) {
+			b.log.Error().Msgf("handle message at topic/partition/offset %v/%v/%v error, redeliver after %s, %s", m.Topic, m.Partition, m.Offset, o.MaxBackoff, err)
+			return errRedeliver
+		}
+		b.log.Error().Msgf("drop rejected message at topic/partition/offset %v/%v/%v, %s", m.Topic, m.Partition, m.Offset, err)
 	}
+
+	// 取消订阅时处理完成的消息仍然需要提交
+	return r.CommitMessages(context.WithoutCancel(ctx), m)
 }
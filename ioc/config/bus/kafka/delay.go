@@ -0,0 +1,152 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/infraboard/mcube/v2/ioc/config/bus"
+	ioc_kafka "github.com/infraboard/mcube/v2/ioc/config/kafka"
+	kafka "github.com/segmentio/kafka-go"
+)
+
+// PublishAt 发送到延迟主题, 由调度器在到期后转发到事件主题,
+// 每个延迟级别一个主题, 同一个主题中的消息等待相同的时间, 到期顺序与发送顺序一致,
+// 剩余的延迟时间大于当前级别时转发到下一个级别, 没有开启延迟投递时返回bus.ErrDelayNotSupported
+func (b *BusServiceImpl) PublishAt(ctx context.Context, e *bus.Event, at time.Time) error {
+	if !b.Delay {
+		return bus.ErrDelayNotSupported
+	}
+
+	ctx, e, end := b.telemetry.StartPublish(ctx, e)
+	err := b.publishAt(ctx, e, at)
+	end(err)
+	return err
+}
+
+func (b *BusServiceImpl) publishAt(ctx context.Context, e *bus.Event, at time.Time) error {
+	if !time.Now().Before(at) {
+		return b.publish(ctx, e)
+	}
+
+	d := *e
+	d.Subject = b.delayTopic(bus.DelayLevel(bus.DelayLevels(b.DelayLevels), time.Until(at)))
+	d.Header = bus.DelayHeader(e, at)
+	return b.publish(ctx, &d)
+}
+
+// 延迟主题属于应用, 避免其他应用的调度器重复转发
+func (b *BusServiceImpl) delayTopic(level time.Duration) string {
+	return bus.SanitizeQueueName(fmt.Sprintf("%s%s.%ds", b.DelayTopicPrefix, b.Group, int64(level/time.Second)))
+}
+
+// 每个延迟级别一个消费者, 应用的多个实例使用同一个消费组分配分区
+func (b *BusServiceImpl) startDelayScheduler() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	b.stopDelay = cancel
+
+	group := bus.SanitizeQueueName(b.Group + ".delay")
+	for _, level := range bus.DelayLevels(b.DelayLevels) {
+		topic := b.delayTopic(level)
+		if err := ioc_kafka.Get().EnsureTopic(ctx, topic); err != nil {
+			cancel()
+			return err
+		}
+
+		r := ioc_kafka.Get().ConsumerGroup(group, []string{topic})
+		b.delayWg.Add(1)
+		go func() {
+			defer b.delayWg.Done()
+			defer ioc_kafka.Get().CloseConsumer(r)
+			if err := b.schedule(ctx, r, level); err != nil && ctx.Err() == nil {
+				b.log.Error().Msgf("schedule delay topic %s error, %s", topic, err)
+			}
+		}()
+	}
+	return nil
+}
+
+// 停止调度, 等待中的消息没有提交, 由消费组中的其他成员或者重启后重新调度
+func (b *BusServiceImpl) stopDelayScheduler() {
+	if b.stopDelay == nil {
+		return
+	}
+	b.stopDelay()
+	b.delayWg.Wait()
+}
+
+func (b *BusServiceImpl) schedule(ctx context.Context, r *kafka.Reader, level time.Duration) error {
+	for {
+		m, err := r.FetchMessage(ctx)
+		if err != nil {
+			return err
+		}
+		// 转发失败时重试, 不跳过消息
+		for {
+			err := b.forward(ctx, m, level)
+			if err == nil {
+				break
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			b.log.Error().Msgf("forward delay message at topic/partition/offset %v/%v/%v error, %s", m.Topic, m.Partition, m.Offset, err)
+			if err := sleep(ctx, time.Second); err != nil {
+				return err
+			}
+		}
+		if err := r.CommitMessages(context.WithoutCancel(ctx), m); err != nil {
+			return err
+		}
+	}
+}
+
+// 等待到当前级别的时间或者投递时间, 到期时发送到事件主题, 否则发送到剩余延迟时间对应的级别
+func (b *BusServiceImpl) forward(ctx context.Context, m kafka.Message, level time.Duration) error {
+	e, at, err := bus.ParseDelayHeader(event(m))
+	if err != nil {
+		b.log.Error().Msgf("drop delay message at topic/partition/offset %v/%v/%v, %s", m.Topic, m.Partition, m.Offset, err)
+		return nil
+	}
+
+	wakeAt := m.Time.Add(level)
+	if at.Before(wakeAt) {
+		wakeAt = at
+	}
+	if err := sleep(ctx, time.Until(wakeAt)); err != nil {
+		return err
+	}
+
+	// 发送时已经写入了Trace上下文, 转发时保留
+	if !time.Now().Before(at) {
+		return b.publish(ctx, e)
+	}
+	return b.publishAt(ctx, e, at)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func event(m kafka.Message) *bus.Event {
+	header := make(map[string][]string)
+	for _, h := range m.Headers {
+		header[h.Key] = append(header[h.Key], string(h.Value))
+	}
+	return &bus.Event{
+		Subject: m.Topic,
+		Key:     string(m.Key),
+		Header:  header,
+		Data:    m.Value,
+	}
+}
@@ -0,0 +1,57 @@
+package kafka
+
+import (
+	"errors"
+	"sync"
+
+	ioc_kafka "github.com/infraboard/mcube/v2/ioc/config/kafka"
+	kafka "github.com/segmentio/kafka-go"
+)
+
+// 事件处理失败且没有进入死信主题, 需要重新投递
+var errRedeliver = errors.New("bus: redeliver message")
+
+func newGroupReader(group string, topics []string) *groupReader {
+	return &groupReader{
+		group:  group,
+		topics: topics,
+		r:      ioc_kafka.Get().ConsumerGroup(group, topics),
+	}
+}
+
+// 消费组的Reader, 消费组的Reader不能Seek, 通过重新打开Reader从最后提交的offset重新消费
+type groupReader struct {
+	mu     sync.Mutex
+	group  string
+	topics []string
+	r      *kafka.Reader
+	closed bool
+}
+
+func (g *groupReader) get() *kafka.Reader {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.r
+}
+
+// 关闭当前的Reader并打开新的Reader, 已经关闭时返回false
+func (g *groupReader) reopen() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return false
+	}
+	_ = ioc_kafka.Get().CloseConsumer(g.r)
+	g.r = ioc_kafka.Get().ConsumerGroup(g.group, g.topics)
+	return true
+}
+
+func (g *groupReader) close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return nil
+	}
+	g.closed = true
+	return ioc_kafka.Get().CloseConsumer(g.r)
+}
@@ -0,0 +1,320 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/infraboard/mcube/v2/ioc"
+	"github.com/infraboard/mcube/v2/ioc/config/application"
+	"github.com/infraboard/mcube/v2/ioc/config/bus"
+	"github.com/infraboard/mcube/v2/ioc/config/log"
+	"github.com/rs/zerolog"
+)
+
+func init() {
+	ioc.Config().Registry(New())
+}
+
+const (
+	PROVIDER = "memory"
+)
+
+var _ bus.Service = (*BusServiceImpl)(nil)
+
+// New 进程内的事件总线, 不依赖外部服务, 用于测试与单体部署
+func New() *BusServiceImpl {
+	nop := zerolog.Nop()
+	return &BusServiceImpl{
+		BufferSize: 1024,
+		log:        &nop,
+		telemetry:  bus.NewTelemetry(PROVIDER),
+		topics:     map[string][]*mailbox{},
+		queues:     map[string]map[string]*mailbox{},
+		timers:     map[*time.Timer]struct{}{},
+	}
+}
+
+type BusServiceImpl struct {
+	ioc.ObjectImpl
+	log       *zerolog.Logger
+	telemetry *bus.Telemetry
+
+	// group 队列模式下的 队列名称或者消费组名称，一个组里面的订阅只有一个能收到消息
+	Group string `toml:"group" json:"group" yaml:"group"  env:"GROUP"`
+	// 每个订阅的缓冲区大小, 缓冲区满时Publish阻塞直到有空间或者ctx取消, 为0时Publish等待订阅取走消息
+	BufferSize int `toml:"buffer_size" json:"buffer_size" yaml:"buffer_size"  env:"BUFFER_SIZE"`
+	// 同步模式, Publish在当前协程中依次调用所有订阅的处理函数, 处理完成后返回, 用于需要确定结果的测试
+	Sync bool `toml:"sync" json:"sync" yaml:"sync"  env:"SYNC"`
+
+	// 采集发送与消费的Prometheus指标
+	Metric bool `toml:"metric" json:"metric" yaml:"metric"  env:"METRIC"`
+
+	mu sync.Mutex
+	// subject -> 广播订阅, 每个订阅一个邮箱
+	topics map[string][]*mailbox
+	// subject -> group -> 队列订阅, 一个组的订阅共享一个邮箱
+	queues map[string]map[string]*mailbox
+	closed bool
+	subs   bus.Subscriptions
+	// 等待投递的延迟事件
+	timers map[*time.Timer]struct{}
+}
+
+// 订阅的邮箱, 多个成员从同一个邮箱中竞争消费
+type mailbox struct {
+	ch      chan *bus.Event
+	members []*member
+	// 同步模式下轮询选择成员
+	next   int
+	closed chan struct{}
+}
+
+type member struct {
+	cb   bus.EventHandler
+	stop chan struct{}
+}
+
+func (b *BusServiceImpl) Name() string {
+	return bus.APP_NAME
+}
+
+func (b *BusServiceImpl) Priority() int {
+	return bus.APP_PRIORITY
+}
+
+func (b *BusServiceImpl) Init() error {
+	b.telemetry = bus.NewTelemetry(PROVIDER)
+	if b.Metric {
+		m, err := bus.DefaultMetricCollector()
+		if err != nil {
+			return err
+		}
+		b.telemetry.SetMetric(m)
+	}
+	if b.Group == "" {
+		b.Group = application.Get().GetAppName()
+	}
+	b.log = log.Sub(b.Name())
+	return nil
+}
+
+// Close 停止接收新的事件, 并等待处理中的事件完成
+func (b *BusServiceImpl) Close(ctx context.Context) {
+	b.mu.Lock()
+	b.closed = true
+	for t := range b.timers {
+		t.Stop()
+	}
+	clear(b.timers)
+	b.mu.Unlock()
+
+	if err := b.subs.Drain(ctx); err != nil {
+		b.log.Error().Msgf("drain subscriptions error, %s", err)
+	}
+}
+
+// 事件发送
+func (b *BusServiceImpl) Publish(ctx context.Context, e *bus.Event) error {
+	ctx, e, end := b.telemetry.StartPublish(ctx, e)
+	err := b.publish(ctx, e)
+	end(err)
+	return err
+}
+
+// PublishAt 延迟事件保存在内存中, 到期后投递, Close时未到期的事件被丢弃
+func (b *BusServiceImpl) PublishAt(ctx context.Context, e *bus.Event, at time.Time) error {
+	ctx, e, end := b.telemetry.StartPublish(ctx, e)
+	defer end(nil)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return fmt.Errorf("bus closed")
+	}
+	e = clone(e)
+	ctx = context.WithoutCancel(ctx)
+	var t *time.Timer
+	t = time.AfterFunc(time.Until(at), func() {
+		b.mu.Lock()
+		delete(b.timers, t)
+		b.mu.Unlock()
+		if err := b.publish(ctx, e); err != nil {
+			b.log.Error().Msgf("publish delayed event %s error, %s", e.Subject, err)
+		}
+	})
+	b.timers[t] = struct{}{}
+	return nil
+}
+
+func (b *BusServiceImpl) publish(ctx context.Context, e *bus.Event) error {
+	type delivery struct {
+		mb *mailbox
+		cb bus.EventHandler
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return fmt.Errorf("bus closed")
+	}
+	deliveries := []delivery{}
+	for _, mb := range b.topics[e.Subject] {
+		deliveries = append(deliveries, delivery{mb: mb, cb: mb.members[0].cb})
+	}
+	for _, mb := range b.queues[e.Subject] {
+		m := mb.members[mb.next%len(mb.members)]
+		mb.next++
+		deliveries = append(deliveries, delivery{mb: mb, cb: m.cb})
+	}
+	b.mu.Unlock()
+
+	for _, d := range deliveries {
+		if b.Sync {
+			b.handle(d.cb, clone(e))
+			continue
+		}
+		select {
+		case d.mb.ch <- clone(e):
+		case <-d.mb.closed:
+			// 订阅已取消, 与Broker一致, 消息不再投递给该订阅
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// 主题订阅, 每个订阅都会收到一份消息, ctx取消后取消订阅
+func (b *BusServiceImpl) TopicSubscribe(ctx context.Context, subject string, cb bus.EventHandler, opts ...bus.SubscribeOption) (bus.Subscription, error) {
+	sub, err := b.subs.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	mb := b.newMailbox()
+	m := b.join(mb, sub.Handler(b.handler(ctx, cb, opts...)))
+	b.topics[subject] = append(b.topics[subject], mb)
+	b.mu.Unlock()
+
+	return sub, b.track(sub, m, func() {
+		b.topics[subject] = remove(b.topics[subject], mb)
+		if len(b.topics[subject]) == 0 {
+			delete(b.topics, subject)
+		}
+		close(mb.closed)
+	})
+}
+
+// 队列订阅, 使用配置的Group作为消费组
+func (b *BusServiceImpl) QueueSubscribe(ctx context.Context, subject string, cb bus.EventHandler, opts ...bus.SubscribeOption) (bus.Subscription, error) {
+	return b.GroupSubscribe(ctx, b.Group, subject, cb, opts...)
+}
+
+// GroupSubscribe 队列订阅, 同一个组中只有一个订阅能收到消息, ctx取消后取消订阅
+func (b *BusServiceImpl) GroupSubscribe(ctx context.Context, group, subject string, cb bus.EventHandler, opts ...bus.SubscribeOption) (bus.Subscription, error) {
+	sub, err := b.subs.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	groups, ok := b.queues[subject]
+	if !ok {
+		groups = map[string]*mailbox{}
+		b.queues[subject] = groups
+	}
+	mb, ok := groups[group]
+	if !ok {
+		mb = b.newMailbox()
+		groups[group] = mb
+	}
+	m := b.join(mb, sub.Handler(b.handler(ctx, cb, opts...)))
+	b.mu.Unlock()
+
+	return sub, b.track(sub, m, func() {
+		mb.members = remove(mb.members, m)
+		if len(mb.members) > 0 {
+			return
+		}
+		delete(groups, group)
+		if len(groups) == 0 {
+			delete(b.queues, subject)
+		}
+		close(mb.closed)
+	})
+}
+
+func (b *BusServiceImpl) newMailbox() *mailbox {
+	return &mailbox{
+		ch:     make(chan *bus.Event, max(b.BufferSize, 0)),
+		closed: make(chan struct{}),
+	}
+}
+
+// 加入邮箱并启动消费协程
+func (b *BusServiceImpl) join(mb *mailbox, cb bus.EventHandler) *member {
+	m := &member{cb: cb, stop: make(chan struct{})}
+	mb.members = append(mb.members, m)
+	go func() {
+		for {
+			select {
+			case <-m.stop:
+				return
+			case e := <-mb.ch:
+				b.handle(cb, e)
+			}
+		}
+	}()
+	return m
+}
+
+// 取消订阅时在锁内执行leave并停止消费协程, 邮箱中未处理的事件被丢弃, 处理中的事件由Subscription等待
+func (b *BusServiceImpl) track(sub *bus.TrackedSubscription, m *member, leave func()) error {
+	return sub.OnUnsubscribe(func() error {
+		b.mu.Lock()
+		leave()
+		b.mu.Unlock()
+		close(m.stop)
+		return nil
+	})
+}
+
+// 按照订阅选项重试与发送死信, 最终失败时没有Broker可以重新投递, 只记录日志
+func (b *BusServiceImpl) handler(ctx context.Context, cb bus.EventHandler, opts ...bus.SubscribeOption) bus.EventHandler {
+	o := bus.NewSubscribeOptions(opts...)
+	return func(e *bus.Event) error {
+		return b.telemetry.Consume(ctx, e, func(e *bus.Event) error {
+			return o.Handle(ctx, b, e, cb)
+		})
+	}
+}
+
+func (b *BusServiceImpl) handle(cb bus.EventHandler, e *bus.Event) {
+	if err := cb(e); err != nil {
+		b.log.Error().Msgf("handle event %s error, %s", e.Subject, err)
+	}
+}
+
+// 每个订阅收到独立的事件, 避免处理函数之间相互影响
+func clone(e *bus.Event) *bus.Event {
+	c := &bus.Event{Subject: e.Subject, Key: e.Key, Data: e.Data}
+	if e.Header != nil {
+		c.Header = make(map[string][]string, len(e.Header))
+		for k, v := range e.Header {
+			c.Header[k] = append([]string(nil), v...)
+		}
+	}
+	return c
+}
+
+func remove[T comparable](items []T, item T) []T {
+	for i := range items {
+		if items[i] == item {
+			return append(items[:i:i], items[i+1:]...)
+		}
+	}
+	return items
+}
@@ -0,0 +1,330 @@
+package memory_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/infraboard/mcube/v2/ioc/config/bus"
+	"github.com/infraboard/mcube/v2/ioc/config/bus/memory"
+)
+
+func TestTopicSubscribe(t *testing.T) {
+	ctx := context.Background()
+	b := memory.New()
+	b.Sync = true
+
+	var c1, c2 atomic.Int64
+	subscribed(t)(b.TopicSubscribe(ctx, "topic", func(e *bus.Event) error { c1.Add(1); return nil }))
+	subscribed(t)(b.TopicSubscribe(ctx, "topic", func(e *bus.Event) error { c2.Add(1); return nil }))
+	subscribed(t)(b.TopicSubscribe(ctx, "other", func(e *bus.Event) error { t.Error("unexpected subject"); return nil }))
+
+	for range 3 {
+		must(t, b.Publish(ctx, &bus.Event{Subject: "topic", Data: []byte("hello")}))
+	}
+	if c1.Load() != 3 || c2.Load() != 3 {
+		t.Fatalf("expect every subscription receive 3 events, got %d %d", c1.Load(), c2.Load())
+	}
+}
+
+func TestQueueSubscribe(t *testing.T) {
+	ctx := context.Background()
+	b := memory.New()
+	b.Sync = true
+	b.Group = "a"
+
+	var a1, a2, b1 atomic.Int64
+	subscribed(t)(b.QueueSubscribe(ctx, "queue", func(e *bus.Event) error { a1.Add(1); return nil }))
+	subscribed(t)(b.QueueSubscribe(ctx, "queue", func(e *bus.Event) error { a2.Add(1); return nil }))
+	subscribed(t)(b.GroupSubscribe(ctx, "b", "queue", func(e *bus.Event) error { b1.Add(1); return nil }))
+
+	for range 4 {
+		must(t, b.Publish(ctx, &bus.Event{Subject: "queue"}))
+	}
+	if a1.Load() != 2 || a2.Load() != 2 {
+		t.Fatalf("expect group a share 4 events, got %d %d", a1.Load(), a2.Load())
+	}
+	if b1.Load() != 4 {
+		t.Fatalf("expect group b receive 4 events, got %d", b1.Load())
+	}
+}
+
+func TestAsyncQueueSubscribe(t *testing.T) {
+	ctx := context.Background()
+	b := memory.New()
+	defer b.Close(ctx)
+
+	wg := &sync.WaitGroup{}
+	wg.Add(100)
+	var total atomic.Int64
+	for range 3 {
+		subscribed(t)(b.GroupSubscribe(ctx, "g", "queue", func(e *bus.Event) error {
+			total.Add(1)
+			wg.Done()
+			return nil
+		}))
+	}
+	for range 100 {
+		must(t, b.Publish(ctx, &bus.Event{Subject: "queue"}))
+	}
+	wg.Wait()
+	time.Sleep(50 * time.Millisecond)
+	if total.Load() != 100 {
+		t.Fatalf("expect each event consumed once, got %d", total.Load())
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	b := memory.New()
+	b.Sync = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var count atomic.Int64
+	subscribed(t)(b.TopicSubscribe(ctx, "topic", func(e *bus.Event) error { count.Add(1); return nil }))
+	must(t, b.Publish(context.Background(), &bus.Event{Subject: "topic"}))
+	cancel()
+
+	// 取消订阅是异步的
+	deadline := time.Now().Add(time.Second)
+	for {
+		before := count.Load()
+		must(t, b.Publish(context.Background(), &bus.Event{Subject: "topic"}))
+		if count.Load() == before {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expect unsubscribed after ctx canceled")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSubscriptionUnsubscribe(t *testing.T) {
+	ctx := context.Background()
+	b := memory.New()
+	b.Sync = true
+
+	var count atomic.Int64
+	sub := subscribed(t)(b.TopicSubscribe(ctx, "topic", func(e *bus.Event) error { count.Add(1); return nil }))
+	must(t, b.Publish(ctx, &bus.Event{Subject: "topic"}))
+	must(t, sub.Unsubscribe())
+	must(t, sub.Unsubscribe())
+	must(t, b.Publish(ctx, &bus.Event{Subject: "topic"}))
+	if count.Load() != 1 {
+		t.Fatalf("expect no event after unsubscribe, got %d", count.Load())
+	}
+}
+
+func TestSubscriptionDrain(t *testing.T) {
+	ctx := context.Background()
+	b := memory.New()
+	defer b.Close(ctx)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	var count atomic.Int64
+	sub := subscribed(t)(b.QueueSubscribe(ctx, "queue", func(e *bus.Event) error {
+		close(started)
+		<-release
+		count.Add(1)
+		return nil
+	}))
+	must(t, b.Publish(ctx, &bus.Event{Subject: "queue"}))
+	<-started
+
+	// 处理中的事件未完成时Drain阻塞直到ctx超时
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	drained := make(chan error, 1)
+	go func() { drained <- sub.Drain(timeout) }()
+	select {
+	case <-drained:
+		t.Fatal("expect drain wait for in-flight handler")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	must(t, <-drained)
+	if count.Load() != 1 {
+		t.Fatalf("expect in-flight handler completed, got %d", count.Load())
+	}
+
+	// Drain之后不再收到事件
+	must(t, b.Publish(ctx, &bus.Event{Subject: "queue"}))
+	time.Sleep(20 * time.Millisecond)
+	if count.Load() != 1 {
+		t.Fatalf("expect no event after drain, got %d", count.Load())
+	}
+}
+
+func TestCloseDrain(t *testing.T) {
+	ctx := context.Background()
+	b := memory.New()
+
+	started := make(chan struct{})
+	var done atomic.Bool
+	subscribed(t)(b.TopicSubscribe(ctx, "topic", func(e *bus.Event) error {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		done.Store(true)
+		return nil
+	}))
+	must(t, b.Publish(ctx, &bus.Event{Subject: "topic"}))
+	<-started
+
+	b.Close(ctx)
+	if !done.Load() {
+		t.Fatal("expect close wait for in-flight handler")
+	}
+	if _, err := b.TopicSubscribe(ctx, "topic", func(e *bus.Event) error { return nil }); err == nil {
+		t.Fatal("expect subscribe error after close")
+	}
+}
+
+func TestBoundedBuffer(t *testing.T) {
+	ctx := context.Background()
+	b := memory.New()
+	b.BufferSize = 1
+	defer b.Close(ctx)
+
+	release := make(chan struct{})
+	subscribed(t)(b.TopicSubscribe(ctx, "topic", func(e *bus.Event) error { <-release; return nil }))
+
+	// 第一个事件被处理函数取走, 第二个事件在缓冲区中
+	must(t, b.Publish(ctx, &bus.Event{Subject: "topic"}))
+	time.Sleep(50 * time.Millisecond)
+	must(t, b.Publish(ctx, &bus.Event{Subject: "topic"}))
+
+	timeout, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if err := b.Publish(timeout, &bus.Event{Subject: "topic"}); err != context.DeadlineExceeded {
+		t.Fatalf("expect publish blocked when buffer full, got %v", err)
+	}
+	close(release)
+}
+
+func TestEventIsolation(t *testing.T) {
+	ctx := context.Background()
+	b := memory.New()
+	b.Sync = true
+
+	subscribed(t)(b.TopicSubscribe(ctx, "topic", func(e *bus.Event) error { e.Header["k"] = []string{"changed"}; return nil }))
+	subscribed(t)(b.TopicSubscribe(ctx, "topic", func(e *bus.Event) error {
+		if e.Header["k"][0] != "v" {
+			t.Errorf("expect header not changed by other handler, got %v", e.Header)
+		}
+		return nil
+	}))
+	must(t, b.Publish(ctx, &bus.Event{Subject: "topic", Header: map[string][]string{"k": {"v"}}}))
+}
+
+func TestClose(t *testing.T) {
+	ctx := context.Background()
+	b := memory.New()
+	subscribed(t)(b.TopicSubscribe(ctx, "topic", func(e *bus.Event) error { return nil }))
+	b.Close(ctx)
+	if err := b.Publish(ctx, &bus.Event{Subject: "topic"}); err == nil {
+		t.Fatal("expect error after close")
+	}
+}
+
+func TestRetry(t *testing.T) {
+	ctx := context.Background()
+	b := memory.New()
+	b.Sync = true
+
+	var count atomic.Int64
+	subscribed(t)(b.TopicSubscribe(ctx, "topic", func(e *bus.Event) error {
+		if count.Add(1) < 3 {
+			return fmt.Errorf("failed")
+		}
+		return nil
+	}, bus.WithRetry(3, time.Millisecond, 10*time.Millisecond)))
+
+	must(t, b.Publish(ctx, &bus.Event{Subject: "topic"}))
+	if count.Load() != 3 {
+		t.Fatalf("expect succeeded after 2 retries, got %d attempts", count.Load())
+	}
+}
+
+func TestDeadLetter(t *testing.T) {
+	ctx := context.Background()
+	b := memory.New()
+	b.Sync = true
+
+	var count atomic.Int64
+	subscribed(t)(b.TopicSubscribe(ctx, "topic", func(e *bus.Event) error {
+		count.Add(1)
+		panic("boom")
+	}, bus.WithRetry(2, time.Millisecond, time.Millisecond), bus.WithDeadLetter("topic.dlq")))
+
+	var dead *bus.Event
+	subscribed(t)(b.TopicSubscribe(ctx, "topic.dlq", func(e *bus.Event) error { dead = e; return nil }))
+
+	must(t, b.Publish(ctx, &bus.Event{Subject: "topic", Header: map[string][]string{"k": {"v"}}, Data: []byte("hello")}))
+	if count.Load() != 3 {
+		t.Fatalf("expect 3 attempts, got %d", count.Load())
+	}
+	if dead == nil {
+		t.Fatal("expect event sent to dead letter")
+	}
+	if string(dead.Data) != "hello" || dead.Header["k"][0] != "v" {
+		t.Fatalf("expect original event kept, got %v", dead)
+	}
+	if dead.Header[bus.HEADER_DEAD_LETTER_SUBJECT][0] != "topic" ||
+		dead.Header[bus.HEADER_DEAD_LETTER_ATTEMPTS][0] != "3" ||
+		!strings.Contains(dead.Header[bus.HEADER_DEAD_LETTER_ERROR][0], "boom") {
+		t.Fatalf("unexpected dead letter header, %v", dead.Header)
+	}
+}
+
+func TestPublishAt(t *testing.T) {
+	ctx := context.Background()
+	b := memory.New()
+	b.Sync = true
+
+	received := make(chan *bus.Event, 1)
+	subscribed(t)(b.TopicSubscribe(ctx, "topic", func(e *bus.Event) error { received <- e; return nil }))
+
+	start := time.Now()
+	must(t, b.PublishAt(ctx, &bus.Event{Subject: "topic", Header: map[string][]string{"k": {"v"}}}, start.Add(50*time.Millisecond)))
+	select {
+	case e := <-received:
+		if time.Since(start) < 50*time.Millisecond {
+			t.Fatalf("expect delivered after 50ms, got %s", time.Since(start))
+		}
+		if e.Header["k"][0] != "v" {
+			t.Fatalf("expect header kept, got %v", e.Header)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("delayed event not delivered")
+	}
+
+	// 关闭时未到期的事件被丢弃
+	must(t, b.PublishAt(ctx, &bus.Event{Subject: "topic"}, time.Now().Add(50*time.Millisecond)))
+	b.Close(ctx)
+	select {
+	case <-received:
+		t.Fatal("expect pending delayed event dropped on close")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func subscribed(t *testing.T) func(bus.Subscription, error) bus.Subscription {
+	return func(sub bus.Subscription, err error) bus.Subscription {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sub
+	}
+}
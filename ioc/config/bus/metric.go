@@ -0,0 +1,100 @@
+package bus
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/infraboard/mcube/v2/ioc/config/application"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	defaultMetric     *MetricCollector
+	defaultMetricOnce sync.Once
+	defaultMetricErr  error
+)
+
+// DefaultMetricCollector 注册到prometheus默认Registry的指标, 多个提供方共享
+func DefaultMetricCollector() (*MetricCollector, error) {
+	defaultMetricOnce.Do(func() {
+		defaultMetric = NewMetricCollector(application.Get().GetAppName())
+		defaultMetricErr = prometheus.Register(defaultMetric)
+		are := prometheus.AlreadyRegisteredError{}
+		if errors.As(defaultMetricErr, &are) {
+			defaultMetricErr = nil
+		}
+	})
+	return defaultMetric, defaultMetricErr
+}
+
+func NewMetricCollector(appName string) *MetricCollector {
+	labels := map[string]string{"app": appName}
+	return &MetricCollector{
+		PublishedTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "bus_published_total",
+				Help:        "Total number of events published",
+				ConstLabels: labels,
+			},
+			[]string{"provider", "subject"},
+		),
+		PublishFailedTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "bus_publish_failed_total",
+				Help:        "Total number of events failed to publish",
+				ConstLabels: labels,
+			},
+			[]string{"provider", "subject"},
+		),
+		ConsumedTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "bus_consumed_total",
+				Help:        "Total number of events consumed",
+				ConstLabels: labels,
+			},
+			[]string{"provider", "subject"},
+		),
+		ConsumeFailedTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "bus_consume_failed_total",
+				Help:        "Total number of events failed to handle",
+				ConstLabels: labels,
+			},
+			[]string{"provider", "subject"},
+		),
+		HandlerDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:        "bus_handler_duration_seconds",
+				Help:        "Event handler latency in seconds, including retries",
+				ConstLabels: labels,
+				Buckets:     prometheus.DefBuckets,
+			},
+			[]string{"provider", "subject"},
+		),
+	}
+}
+
+// MetricCollector 事件总线指标, provider为提供方, subject为事件主题
+type MetricCollector struct {
+	PublishedTotal     *prometheus.CounterVec
+	PublishFailedTotal *prometheus.CounterVec
+	ConsumedTotal      *prometheus.CounterVec
+	ConsumeFailedTotal *prometheus.CounterVec
+	HandlerDuration    *prometheus.HistogramVec
+}
+
+func (c *MetricCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.PublishedTotal.Describe(ch)
+	c.PublishFailedTotal.Describe(ch)
+	c.ConsumedTotal.Describe(ch)
+	c.ConsumeFailedTotal.Describe(ch)
+	c.HandlerDuration.Describe(ch)
+}
+
+func (c *MetricCollector) Collect(ch chan<- prometheus.Metric) {
+	c.PublishedTotal.Collect(ch)
+	c.PublishFailedTotal.Collect(ch)
+	c.ConsumedTotal.Collect(ch)
+	c.ConsumeFailedTotal.Collect(ch)
+	c.HandlerDuration.Collect(ch)
+}
@@ -2,28 +2,72 @@ package nats
 
 import (
 	"context"
+	"errors"
 	"os"
+	"strings"
 
 	"github.com/infraboard/mcube/v2/ioc"
 	"github.com/infraboard/mcube/v2/ioc/config/application"
 	"github.com/infraboard/mcube/v2/ioc/config/bus"
+	"github.com/infraboard/mcube/v2/ioc/config/log"
 	ioc_nats "github.com/infraboard/mcube/v2/ioc/config/nats"
 	"github.com/nats-io/nats.go"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// JetStream消息的Reply前缀
+	JS_ACK_PREFIX = "$JS.ACK."
+)
+
+type CONSUMER_TYPE string
+
+const (
+	// 客户端批量拉取消息, 同一个Consumer的多个订阅竞争消费
+	CONSUMER_TYPE_PULL CONSUMER_TYPE = "pull"
+	// 服务端推送消息到投递主题, 队列订阅时订阅方组成队列组
+	CONSUMER_TYPE_PUSH CONSUMER_TYPE = "push"
 )
 
 func init() {
-	ioc.Config().Registry(&BusServiceImpl{})
+	ioc.Config().Registry(&BusServiceImpl{
+		ConsumerType:  CONSUMER_TYPE_PULL,
+		DeliverPolicy: "new",
+		AckWait:       30,
+		MaxDeliver:    5,
+	})
 }
 
+const (
+	PROVIDER = "nats"
+)
+
 var _ bus.Service = (*BusServiceImpl)(nil)
 
 type BusServiceImpl struct {
 	ioc.ObjectImpl
+	log       *zerolog.Logger
+	telemetry *bus.Telemetry
+	subs      bus.Subscriptions
 
 	// group 队列模式下的 队列名称或者消费组名称，一个组里面的实例消费一个队列
 	Group string `toml:"group" json:"group" yaml:"group"  env:"GROUP"`
 	// nodename 广播模式下的节点名称，默认hostname, 每个节点独立一个 节点队列: group.nodename
 	NodeName string `toml:"node_name" json:"node_name" yaml:"node_name" env:"NODE_NAME"`
+
+	// 采集发送与消费的Prometheus指标
+	Metric bool `toml:"metric" json:"metric" yaml:"metric"  env:"METRIC"`
+
+	// JetStream 使用JetStream持久化投递, 需要在nats配置中声明覆盖订阅主题的Stream
+	JetStream bool `toml:"jetstream" json:"jetstream" yaml:"jetstream"  env:"JETSTREAM"`
+	// JetStream订阅的Consumer类型: pull(默认), push
+	ConsumerType CONSUMER_TYPE `toml:"consumer_type" json:"consumer_type" yaml:"consumer_type"  env:"CONSUMER_TYPE"`
+	// 新建Consumer时开始投递的位置: new(默认), all, last
+	DeliverPolicy string `toml:"deliver_policy" json:"deliver_policy" yaml:"deliver_policy"  env:"DELIVER_POLICY"`
+	// 确认超时时间, 单位秒, 超时未确认的消息重新投递
+	AckWait int64 `toml:"ack_wait" json:"ack_wait" yaml:"ack_wait"  env:"ACK_WAIT"`
+	// 最大投递次数, 达到后不再投递, 0表示不限制
+	MaxDeliver int `toml:"max_deliver" json:"max_deliver" yaml:"max_deliver"  env:"MAX_DELIVER"`
 }
 
 func (b *BusServiceImpl) Name() string {
@@ -35,6 +79,15 @@ func (i *BusServiceImpl) Priority() int {
 }
 
 func (b *BusServiceImpl) Init() error {
+	b.telemetry = bus.NewTelemetry(PROVIDER)
+	if b.Metric {
+		m, err := bus.DefaultMetricCollector()
+		if err != nil {
+			return err
+		}
+		b.telemetry.SetMetric(m)
+	}
+	b.log = log.Sub(b.Name())
 	if b.Group == "" {
 		b.Group = application.Get().GetAppName()
 	}
@@ -48,40 +101,98 @@ func (b *BusServiceImpl) Init() error {
 	return nil
 }
 
+// Close 等待处理中的消息完成, 之后由nats关闭连接
+func (b *BusServiceImpl) Close(ctx context.Context) {
+	if err := b.subs.Drain(ctx); err != nil {
+		b.log.Error().Msgf("drain subscriptions error, %s", err)
+	}
+}
+
 // 事件发送
 func (b *BusServiceImpl) Publish(ctx context.Context, e *bus.Event) error {
+	ctx, e, end := b.telemetry.StartPublish(ctx, e)
+	err := b.publish(ctx, e)
+	end(err)
+	return err
+}
+
+func (b *BusServiceImpl) publish(ctx context.Context, e *bus.Event) error {
 	msg := nats.NewMsg(e.Subject)
 	msg.Data = e.Data
 	msg.Header = e.Header
+	if b.JetStream {
+		return b.jsPublish(ctx, msg)
+	}
 	return ioc_nats.Get().PublishMsg(msg)
 }
 
 // 订阅事件
-func (b *BusServiceImpl) TopicSubscribe(ctx context.Context, subject string, cb bus.EventHandler) error {
-	_, err := ioc_nats.Get().Subscribe(subject, func(msg *nats.Msg) {
-		cb(&bus.Event{
-			Subject: msg.Subject,
-			Header:  msg.Header,
-			Data:    msg.Data,
-		})
-	})
-	if err != nil {
-		return err
+func (b *BusServiceImpl) TopicSubscribe(ctx context.Context, subject string, cb bus.EventHandler, opts ...bus.SubscribeOption) (bus.Subscription, error) {
+	if b.JetStream {
+		return b.jsSubscribe(ctx, subject, b.Group+"."+b.NodeName+"."+subject, false, cb, opts)
 	}
-	return nil
+	return b.subscribe(ctx, cb, opts, func(h nats.MsgHandler) (*nats.Subscription, error) {
+		return ioc_nats.Get().Subscribe(subject, h)
+	})
 }
 
 // 订阅事件
-func (b *BusServiceImpl) QueueSubscribe(ctx context.Context, subject string, cb bus.EventHandler) error {
-	_, err := ioc_nats.Get().QueueSubscribe(subject, bus.SanitizeQueueName(b.Group+"."+b.NodeName+"."+subject), func(msg *nats.Msg) {
-		cb(&bus.Event{
-			Subject: msg.Subject,
-			Header:  msg.Header,
-			Data:    msg.Data,
-		})
+func (b *BusServiceImpl) QueueSubscribe(ctx context.Context, subject string, cb bus.EventHandler, opts ...bus.SubscribeOption) (bus.Subscription, error) {
+	if b.JetStream {
+		return b.jsSubscribe(ctx, subject, b.Group+"."+subject, true, cb, opts)
+	}
+	return b.subscribe(ctx, cb, opts, func(h nats.MsgHandler) (*nats.Subscription, error) {
+		return ioc_nats.Get().QueueSubscribe(subject, bus.SanitizeQueueName(b.Group+"."+b.NodeName+"."+subject), h)
 	})
+}
+
+func (b *BusServiceImpl) subscribe(ctx context.Context, cb bus.EventHandler, opts []bus.SubscribeOption,
+	fn func(nats.MsgHandler) (*nats.Subscription, error)) (bus.Subscription, error) {
+	sub, err := b.subs.New(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	ns, err := fn(b.msgHandler(ctx, sub, cb, opts...))
+	if err != nil {
+		return nil, errors.Join(err, sub.Unsubscribe())
+	}
+	// 取消订阅后客户端不再调用处理函数, 缓冲中未处理的消息被丢弃, JetStream消息会重新投递
+	if err := sub.OnUnsubscribe(ns.Unsubscribe); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// JetStream的消息显式确认, Core NATS没有确认机制, 失败时只记录日志
+func (b *BusServiceImpl) msgHandler(ctx context.Context, sub *bus.TrackedSubscription, cb bus.EventHandler, opts ...bus.SubscribeOption) nats.MsgHandler {
+	o := bus.NewSubscribeOptions(opts...)
+	return func(msg *nats.Msg) {
+		// 订阅已经取消, JetStream消息Nak后重新投递给其他订阅
+		if !sub.Acquire() {
+			if strings.HasPrefix(msg.Reply, JS_ACK_PREFIX) {
+				_ = msg.Nak()
+			}
+			return
+		}
+		defer sub.Release()
+
+		err := b.handle(ctx, o, cb, msg.Subject, msg.Header, msg.Data)
+		if strings.HasPrefix(msg.Reply, JS_ACK_PREFIX) {
+			b.ack(pushMsg{msg}, err)
+			return
+		}
+		if err != nil {
+			b.log.Error().Msgf("handle event %s error, %s", msg.Subject, err)
+		}
+	}
+}
+
+func (b *BusServiceImpl) handle(ctx context.Context, o *bus.SubscribeOptions, cb bus.EventHandler, subject string, header nats.Header, data []byte) error {
+	return b.telemetry.Consume(ctx, &bus.Event{
+		Subject: subject,
+		Header:  header,
+		Data:    data,
+	}, func(e *bus.Event) error {
+		return o.Handle(ctx, b, e, cb)
+	})
 }
@@ -0,0 +1,171 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/infraboard/mcube/v2/ioc/config/bus"
+	ioc_nats "github.com/infraboard/mcube/v2/ioc/config/nats"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	// push Consumer投递主题的前缀
+	DELIVER_SUBJECT_PREFIX = "mcube.deliver."
+)
+
+// 等待Stream的确认后返回, 有事件Id时作为Nats-Msg-Id, Stream在去重窗口内忽略重复的事件
+func (b *BusServiceImpl) jsPublish(ctx context.Context, msg *nats.Msg) error {
+	var opts []jetstream.PublishOpt
+	if id := msg.Header.Get(bus.HEADER_EVENT_ID); id != "" {
+		opts = append(opts, jetstream.WithMsgID(id))
+	}
+	_, err := ioc_nats.GetJetStream().PublishMsg(ctx, msg, opts...)
+	return err
+}
+
+// 为订阅创建或者更新持久化Consumer, 相同名称的Consumer在重启后继续之前的消费进度,
+// 主题订阅每个节点一个Consumer, 队列订阅每个组一个Consumer
+func (b *BusServiceImpl) jsSubscribe(ctx context.Context, subject, durable string, queue bool, cb bus.EventHandler, opts []bus.SubscribeOption) (bus.Subscription, error) {
+	js := ioc_nats.GetJetStream()
+	stream, err := js.StreamNameBySubject(ctx, subject)
+	if err != nil {
+		return nil, fmt.Errorf("find stream for subject %s error, %w", subject, err)
+	}
+
+	c := &ioc_nats.Consumer{
+		Stream:         stream,
+		Durable:        durableName(durable),
+		FilterSubjects: []string{subject},
+		DeliverPolicy:  b.DeliverPolicy,
+		AckWait:        b.AckWait,
+		MaxDeliver:     b.MaxDeliver,
+	}
+	if b.ConsumerType == CONSUMER_TYPE_PUSH {
+		c.DeliverSubject = DELIVER_SUBJECT_PREFIX + c.Durable
+		if queue {
+			c.DeliverGroup = c.Durable
+		}
+	}
+	conf, err := c.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	if c.IsPush() {
+		if _, err := js.CreateOrUpdatePushConsumer(ctx, stream, conf); err != nil {
+			return nil, err
+		}
+		// 直接订阅投递主题, 队列订阅时通过队列组在订阅之间分配消息
+		return b.subscribe(ctx, cb, opts, func(h nats.MsgHandler) (*nats.Subscription, error) {
+			if queue {
+				return ioc_nats.Get().QueueSubscribe(c.DeliverSubject, c.DeliverGroup, h)
+			}
+			return ioc_nats.Get().Subscribe(c.DeliverSubject, h)
+		})
+	}
+
+	consumer, err := js.CreateOrUpdateConsumer(ctx, stream, conf)
+	if err != nil {
+		return nil, err
+	}
+	return b.pull(ctx, consumer, cb, opts)
+}
+
+// 多个订阅使用同一个Consumer拉取时, 每条消息只投递给其中一个
+func (b *BusServiceImpl) pull(ctx context.Context, consumer jetstream.Consumer, cb bus.EventHandler, opts []bus.SubscribeOption) (bus.Subscription, error) {
+	sub, err := b.subs.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	o := bus.NewSubscribeOptions(opts...)
+	cc, err := consumer.Consume(func(msg jetstream.Msg) {
+		// 订阅已经取消, Nak后重新投递给其他订阅
+		if !sub.Acquire() {
+			_ = msg.Nak()
+			return
+		}
+		defer sub.Release()
+
+		err := b.handle(ctx, o, cb, msg.Subject(), msg.Headers(), msg.Data())
+		b.ack(msg, err)
+	}, jetstream.ConsumeErrHandler(func(_ jetstream.ConsumeContext, err error) {
+		b.log.Error().Msgf("consume %s error, %s", consumer.CachedInfo().Name, err)
+	}))
+	if err != nil {
+		return nil, errors.Join(err, sub.Unsubscribe())
+	}
+	// 停止拉取, 已经拉取但未处理的消息在确认超时后重新投递
+	if err := sub.OnUnsubscribe(func() error { cc.Stop(); return nil }); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// 显式确认: 处理成功后Ack, 失败后Nak重新投递, 被拒绝时Term不再投递
+func (b *BusServiceImpl) ack(msg acker, err error) {
+	switch {
+	case errors.Is(err, bus.ErrRejected):
+		b.log.Error().Msgf("event %s rejected, term, %s", msg.Subject(), err)
+		err = msg.Term()
+	case err != nil:
+		if md, mdErr := msg.Metadata(); mdErr == nil && b.MaxDeliver > 0 && md.NumDelivered >= uint64(b.MaxDeliver) {
+			b.log.Error().Msgf("handle event %s error, reached max deliver %d, %s", msg.Subject(), b.MaxDeliver, err)
+		} else {
+			b.log.Error().Msgf("handle event %s error, nak, %s", msg.Subject(), err)
+		}
+		err = msg.Nak()
+	default:
+		err = msg.Ack()
+	}
+	if err != nil {
+		b.log.Error().Msgf("ack event %s error, %s", msg.Subject(), err)
+	}
+}
+
+// jetstream.Msg与push Consumer投递的nats.Msg的确认方法
+type acker interface {
+	Subject() string
+	Metadata() (*jetstream.MsgMetadata, error)
+	Ack() error
+	Nak() error
+	Term() error
+}
+
+// push Consumer投递的消息, 确认主题与jetstream.Msg相同
+type pushMsg struct {
+	*nats.Msg
+}
+
+func (m pushMsg) Subject() string {
+	return m.Msg.Subject
+}
+
+func (m pushMsg) Metadata() (*jetstream.MsgMetadata, error) {
+	md, err := m.Msg.Metadata()
+	if err != nil {
+		return nil, err
+	}
+	return &jetstream.MsgMetadata{NumDelivered: md.NumDelivered}, nil
+}
+
+func (m pushMsg) Ack() error {
+	return m.Msg.Ack()
+}
+
+func (m pushMsg) Nak() error {
+	return m.Msg.Nak()
+}
+
+func (m pushMsg) Term() error {
+	return m.Msg.Term()
+}
+
+// Consumer名称不能包含 . * > 与空白字符
+func durableName(name string) string {
+	return strings.ReplaceAll(bus.SanitizeQueueName(name), ".", "_")
+}
@@ -0,0 +1,36 @@
+package outbox
+
+import (
+	"context"
+
+	"github.com/infraboard/mcube/v2/ioc"
+	"github.com/infraboard/mcube/v2/ioc/config/bus"
+)
+
+const (
+	AppName = "outbox"
+)
+
+const (
+	// 依赖datasource, bus与lock, 需要在它们之后初始化
+	PRIORITY = 597
+)
+
+const (
+	// 保存待发送事件的表
+	OUTBOX_TABLE = "mcube_outbox"
+)
+
+func Get() *Outbox {
+	obj := ioc.Config().Get(AppName)
+	if obj == nil {
+		return defaultConfig
+	}
+	return obj.(*Outbox)
+}
+
+// Publish 把事件写入发件箱, ctx中有事务时(datasource.WithTransactionCtx)与业务数据一起提交或者回滚,
+// 事务提交后由Relay投递到bus
+func Publish(ctx context.Context, e *bus.Event) error {
+	return Get().Publish(ctx, e)
+}
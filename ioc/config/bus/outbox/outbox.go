@@ -0,0 +1,339 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/infraboard/mcube/v2/ioc"
+	"github.com/infraboard/mcube/v2/ioc/config/bus"
+	"github.com/infraboard/mcube/v2/ioc/config/datasource"
+	"github.com/infraboard/mcube/v2/ioc/config/lock"
+	"github.com/infraboard/mcube/v2/ioc/config/log"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+func init() {
+	ioc.Config().Registry(defaultConfig)
+}
+
+var defaultConfig = New()
+
+// New 事务发件箱, 默认使用ioc中的datasource, bus与lock
+func New() *Outbox {
+	nop := zerolog.Nop()
+	return &Outbox{
+		Relay:           true,
+		AutoMigrate:     true,
+		Interval:        1000,
+		BatchSize:       100,
+		LockKey:         "mcube.outbox.relay",
+		LockTTL:         30,
+		Retention:       72,
+		CleanupInterval: 3600,
+		log:             &nop,
+	}
+}
+
+type Outbox struct {
+	ioc.ObjectImpl
+	log *zerolog.Logger
+
+	// 是否启动Relay投递事件, 多个实例通过锁保证只有一个实例在投递
+	Relay bool `json:"relay" yaml:"relay" toml:"relay" env:"RELAY"`
+	// 初始化时自动创建发件箱表
+	AutoMigrate bool `json:"auto_migrate" yaml:"auto_migrate" toml:"auto_migrate" env:"AUTO_MIGRATE"`
+	// 扫描发件箱的间隔, 单位毫秒
+	Interval int64 `json:"interval" yaml:"interval" toml:"interval" env:"INTERVAL"`
+	// 每次投递的事件数量
+	BatchSize int `json:"batch_size" yaml:"batch_size" toml:"batch_size" env:"BATCH_SIZE"`
+	// Relay锁的名称
+	LockKey string `json:"lock_key" yaml:"lock_key" toml:"lock_key" env:"LOCK_KEY"`
+	// Relay锁的过期时间, 单位秒, 持有锁的实例宕机后其他实例最多等待该时间接管
+	LockTTL int64 `json:"lock_ttl" yaml:"lock_ttl" toml:"lock_ttl" env:"LOCK_TTL"`
+	// 发送失败的次数达到该值后放弃该事件, 继续投递后面的事件, 0表示一直重试
+	MaxAttempts int `json:"max_attempts" yaml:"max_attempts" toml:"max_attempts" env:"MAX_ATTEMPTS"`
+	// 发送成功的事件保留时间, 单位小时, 0表示不清理
+	Retention int64 `json:"retention" yaml:"retention" toml:"retention" env:"RETENTION"`
+	// 清理的间隔, 单位秒
+	CleanupInterval int64 `json:"cleanup_interval" yaml:"cleanup_interval" toml:"cleanup_interval" env:"CLEANUP_INTERVAL"`
+
+	db        *gorm.DB
+	publisher bus.Publisher
+	lf        lock.LockFactory
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func (o *Outbox) Name() string {
+	return AppName
+}
+
+func (o *Outbox) Priority() int {
+	return PRIORITY
+}
+
+func (o *Outbox) Init() error {
+	o.log = log.Sub(o.Name())
+	if o.AutoMigrate {
+		if err := o.Migrate(context.Background()); err != nil {
+			return err
+		}
+	}
+	if o.Relay {
+		o.Start(context.Background())
+	}
+	return nil
+}
+
+// Close 停止Relay, 需要在bus关闭之前完成
+func (o *Outbox) Close(ctx context.Context) {
+	o.Stop(ctx)
+}
+
+// SetDB 使用指定的数据库, 而不是ioc中的datasource
+func (o *Outbox) SetDB(db *gorm.DB) *Outbox {
+	o.db = db
+	return o
+}
+
+// SetPublisher 使用指定的Publisher投递事件, 而不是ioc中的bus
+func (o *Outbox) SetPublisher(p bus.Publisher) *Outbox {
+	o.publisher = p
+	return o
+}
+
+// SetLockFactory 使用指定的锁, 而不是ioc中的lock
+func (o *Outbox) SetLockFactory(lf lock.LockFactory) *Outbox {
+	o.lf = lf
+	return o
+}
+
+// SetLogger 设置日志
+func (o *Outbox) SetLogger(l *zerolog.Logger) *Outbox {
+	o.log = l
+	return o
+}
+
+func (o *Outbox) getDB() *gorm.DB {
+	if o.db != nil {
+		return o.db
+	}
+	return datasource.DB()
+}
+
+func (o *Outbox) getPublisher() bus.Publisher {
+	if o.publisher != nil {
+		return o.publisher
+	}
+	return bus.GetService()
+}
+
+func (o *Outbox) getLockFactory() lock.LockFactory {
+	if o.lf != nil {
+		return o.lf
+	}
+	return lock.L()
+}
+
+// Migrate 创建发件箱表
+func (o *Outbox) Migrate(ctx context.Context) error {
+	return o.getDB().WithContext(ctx).AutoMigrate(&Record{})
+}
+
+// Publish 把事件写入发件箱, ctx中有事务时使用该事务
+func (o *Outbox) Publish(ctx context.Context, e *bus.Event) error {
+	r, err := NewRecord(e)
+	if err != nil {
+		return err
+	}
+	return o.dbFromCtx(ctx).Create(r).Error
+}
+
+func (o *Outbox) dbFromCtx(ctx context.Context) *gorm.DB {
+	if tx := datasource.GetTransactionFromCtx(ctx); tx != nil {
+		return tx.WithContext(ctx)
+	}
+	return o.getDB().WithContext(ctx)
+}
+
+// Start 启动Relay, ctx取消或者Stop后停止
+func (o *Outbox) Start(ctx context.Context) {
+	if o.cancel != nil {
+		return
+	}
+	ctx, o.cancel = context.WithCancel(ctx)
+	o.done = make(chan struct{})
+	go o.run(ctx, o.done)
+}
+
+// Stop 停止Relay, 等待正在投递的批次完成, 并释放锁
+func (o *Outbox) Stop(ctx context.Context) {
+	if o.cancel == nil {
+		return
+	}
+	o.cancel()
+	select {
+	case <-o.done:
+	case <-ctx.Done():
+	}
+	o.cancel = nil
+}
+
+// 获取到锁的实例定时投递与清理, 锁丢失后重新竞争
+func (o *Outbox) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	interval := time.Duration(max(o.Interval, 1)) * time.Millisecond
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var (
+		w           *lock.Watchdog
+		lastCleanup time.Time
+	)
+	defer func() {
+		if w != nil {
+			if err := w.UnLock(context.Background()); err != nil {
+				o.log.Warn().Msgf("release outbox relay lock error, %s", err)
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if w == nil {
+			w = o.acquire(ctx)
+			if w == nil {
+				continue
+			}
+			o.log.Info().Msgf("outbox relay started")
+		}
+
+		select {
+		case <-w.Lost():
+			o.log.Warn().Msgf("outbox relay lock lost")
+			w = nil
+			continue
+		default:
+		}
+
+		if _, err := o.RelayOnce(w.Context()); err != nil {
+			o.log.Error().Msgf("relay outbox error, %s", err)
+		}
+		if o.Retention > 0 && time.Since(lastCleanup) >= time.Duration(o.CleanupInterval)*time.Second {
+			lastCleanup = time.Now()
+			if _, err := o.Cleanup(w.Context()); err != nil {
+				o.log.Error().Msgf("cleanup outbox error, %s", err)
+			}
+		}
+	}
+}
+
+func (o *Outbox) acquire(ctx context.Context) *lock.Watchdog {
+	ttl := time.Duration(max(o.LockTTL, 1)) * time.Second
+	w := lock.NewWatchdog(o.getLockFactory().New(o.LockKey, ttl), ttl)
+	if err := w.TryLock(ctx); err != nil {
+		if !errors.Is(err, lock.ErrNotObtained) {
+			o.log.Warn().Msgf("acquire outbox relay lock error, %s", err)
+		}
+		return nil
+	}
+	return w
+}
+
+// RelayOnce 按照写入顺序投递一批未发送的事件, 返回发送成功的数量,
+// 某个事件发送失败时停止本批次, 保证事件的顺序, 失败的事件在下一次扫描时重试,
+// 无法还原的事件与失败次数达到MaxAttempts的事件被放弃, 不阻塞后面的事件.
+// 发送成功后标记失败时事件会被重复投递, 订阅方需要做幂等处理
+func (o *Outbox) RelayOnce(ctx context.Context) (int, error) {
+	records := []*Record{}
+	err := o.getDB().WithContext(ctx).
+		Where("sent_at = ? AND failed_at = ?", 0, 0).
+		Order("id").
+		Limit(max(o.BatchSize, 1)).
+		Find(&records).Error
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, r := range records {
+		if err := ctx.Err(); err != nil {
+			return sent, err
+		}
+
+		e, err := r.Event()
+		if err != nil {
+			o.markFailed(ctx, r, err, true)
+			continue
+		}
+		if err := o.getPublisher().Publish(ctx, e); err != nil {
+			if o.markFailed(ctx, r, err, o.MaxAttempts > 0 && r.Attempts+1 >= o.MaxAttempts) {
+				continue
+			}
+			return sent, err
+		}
+
+		err = o.getDB().WithContext(ctx).Model(&Record{}).
+			Where("id = ? AND sent_at = ?", r.Id, 0).
+			Update("sent_at", time.Now().UnixMilli()).Error
+		if err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// 记录失败原因, park为true时放弃该事件, 返回是否已经放弃
+func (o *Outbox) markFailed(ctx context.Context, r *Record, cause error, park bool) bool {
+	updates := map[string]any{
+		"attempts":   gorm.Expr("attempts + ?", 1),
+		"last_error": cause.Error(),
+	}
+	if park {
+		updates["failed_at"] = time.Now().UnixMilli()
+	}
+	err := o.getDB().WithContext(ctx).Model(&Record{}).
+		Where("id = ?", r.Id).
+		Updates(updates).Error
+	if err != nil {
+		o.log.Warn().Msgf("update outbox record %d error, %s", r.Id, err)
+		return false
+	}
+	if park {
+		o.log.Error().Msgf("give up outbox record %d (%s) after %d attempts, %s", r.Id, r.Subject, r.Attempts+1, cause)
+	}
+	return park
+}
+
+// Requeue 重新投递放弃的事件, 清空失败次数, 返回更新的数量
+func (o *Outbox) Requeue(ctx context.Context, ids ...int64) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	res := o.getDB().WithContext(ctx).Model(&Record{}).
+		Where("id IN ? AND sent_at = ? AND failed_at > ?", ids, 0, 0).
+		Updates(map[string]any{
+			"attempts":  0,
+			"failed_at": 0,
+		})
+	return res.RowsAffected, res.Error
+}
+
+// Cleanup 删除超过保留时间的已发送事件, 返回删除的数量
+func (o *Outbox) Cleanup(ctx context.Context) (int64, error) {
+	before := time.Now().Add(-time.Duration(o.Retention) * time.Hour).UnixMilli()
+	res := o.getDB().WithContext(ctx).
+		Where("sent_at > ? AND sent_at < ?", 0, before).
+		Delete(&Record{})
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,268 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bluele/gcache"
+	"github.com/glebarez/sqlite"
+	"github.com/infraboard/mcube/v2/ioc/config/bus"
+	"github.com/infraboard/mcube/v2/ioc/config/bus/outbox"
+	"github.com/infraboard/mcube/v2/ioc/config/datasource"
+	"github.com/infraboard/mcube/v2/ioc/config/lock"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// 记录发送的事件, fail不为空时发送失败, reject中的主题一直发送失败
+type recorder struct {
+	mu     sync.Mutex
+	events []*bus.Event
+	fail   error
+	reject string
+}
+
+func (r *recorder) Publish(ctx context.Context, e *bus.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fail != nil {
+		return r.fail
+	}
+	if e.Subject == r.reject {
+		return errors.New("subject rejected")
+	}
+	r.events = append(r.events, e)
+	return nil
+}
+
+func (r *recorder) subjects() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	subjects := []string{}
+	for _, e := range r.events {
+		subjects = append(subjects, e.Subject)
+	}
+	return subjects
+}
+
+func newDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "outbox.db")), &gorm.Config{
+		Logger: logger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	return db
+}
+
+func newOutbox(t *testing.T, db *gorm.DB, p bus.Publisher) *outbox.Outbox {
+	o := outbox.New().SetDB(db).SetPublisher(p)
+	if err := o.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+func pending(t *testing.T, db *gorm.DB) int64 {
+	var count int64
+	if err := db.Model(&outbox.Record{}).Where("sent_at = ?", 0).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestPublishInTransaction(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	r := &recorder{}
+	o := newOutbox(t, db, r)
+
+	// 事务回滚时事件一起回滚
+	_ = db.Transaction(func(tx *gorm.DB) error {
+		if err := o.Publish(datasource.WithTransactionCtx(ctx, tx), &bus.Event{Subject: "rollback"}); err != nil {
+			t.Fatal(err)
+		}
+		return errors.New("rollback")
+	})
+	if n := pending(t, db); n != 0 {
+		t.Fatalf("expect no event after rollback, got %d", n)
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return o.Publish(datasource.WithTransactionCtx(ctx, tx), &bus.Event{
+			Subject: "commit",
+			Header:  map[string][]string{"k": {"v"}},
+			Data:    []byte("hello"),
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := o.RelayOnce(ctx)
+	if err != nil || n != 1 {
+		t.Fatalf("expect 1 event relayed, got %d %v", n, err)
+	}
+	e := r.events[0]
+	if e.Subject != "commit" || string(e.Data) != "hello" || e.Header["k"][0] != "v" {
+		t.Fatalf("unexpected event %v", e)
+	}
+	if n := pending(t, db); n != 0 {
+		t.Fatalf("expect event marked sent, got %d pending", n)
+	}
+}
+
+func TestRelayFailure(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	r := &recorder{fail: errors.New("broker down")}
+	o := newOutbox(t, db, r)
+
+	for _, subject := range []string{"a", "b", "c"} {
+		if err := o.Publish(ctx, &bus.Event{Subject: subject}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 失败时停止本批次, 记录失败原因
+	if n, err := o.RelayOnce(ctx); err == nil || n != 0 {
+		t.Fatalf("expect relay failed, got %d %v", n, err)
+	}
+	record := &outbox.Record{}
+	if err := db.Order("id").First(record).Error; err != nil {
+		t.Fatal(err)
+	}
+	if record.Attempts != 1 || record.LastError != "broker down" {
+		t.Fatalf("expect failure recorded, got %d %s", record.Attempts, record.LastError)
+	}
+
+	// 恢复后按照写入顺序投递
+	r.fail = nil
+	if n, err := o.RelayOnce(ctx); err != nil || n != 3 {
+		t.Fatalf("expect 3 events relayed, got %d %v", n, err)
+	}
+	if got := r.subjects(); len(got) != 3 || got[0] != "a" || got[1] != "b" || got[2] != "c" {
+		t.Fatalf("expect events relayed in order, got %v", got)
+	}
+}
+
+func TestRelayMaxAttempts(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	r := &recorder{reject: "poison"}
+	o := newOutbox(t, db, r)
+	o.MaxAttempts = 2
+
+	// 无法还原的事件直接放弃
+	if err := db.Create(&outbox.Record{Subject: "invalid", Header: "{"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, subject := range []string{"poison", "a"} {
+		if err := o.Publish(ctx, &bus.Event{Subject: subject}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 第一次失败时停止本批次
+	if n, err := o.RelayOnce(ctx); err == nil || n != 0 {
+		t.Fatalf("expect relay failed, got %d %v", n, err)
+	}
+	// 达到最大次数后放弃, 继续投递后面的事件
+	if n, err := o.RelayOnce(ctx); err != nil || n != 1 {
+		t.Fatalf("expect 1 event relayed, got %d %v", n, err)
+	}
+	if got := r.subjects(); len(got) != 1 || got[0] != "a" {
+		t.Fatalf("expect later event relayed, got %v", got)
+	}
+
+	failed := []*outbox.Record{}
+	if err := db.Where("failed_at > ?", 0).Order("id").Find(&failed).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) != 2 || failed[0].Subject != "invalid" || failed[1].Subject != "poison" || failed[1].Attempts != 2 {
+		t.Fatalf("unexpected failed records %v", failed)
+	}
+
+	// 修复后重新投递
+	r.reject = ""
+	if n, err := o.Requeue(ctx, failed[1].Id); err != nil || n != 1 {
+		t.Fatalf("expect 1 record requeued, got %d %v", n, err)
+	}
+	if n, err := o.RelayOnce(ctx); err != nil || n != 1 {
+		t.Fatalf("expect requeued event relayed, got %d %v", n, err)
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	o := newOutbox(t, db, &recorder{})
+	o.Retention = 1
+
+	old := time.Now().Add(-2 * time.Hour).UnixMilli()
+	records := []*outbox.Record{
+		{Subject: "old", CreatedAt: old, SentAt: old},
+		{Subject: "recent", CreatedAt: old, SentAt: time.Now().UnixMilli()},
+		{Subject: "pending", CreatedAt: old},
+	}
+	if err := db.Create(records).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := o.Cleanup(ctx)
+	if err != nil || n != 1 {
+		t.Fatalf("expect 1 record cleaned, got %d %v", n, err)
+	}
+	var left int64
+	db.Model(&outbox.Record{}).Count(&left)
+	if left != 2 {
+		t.Fatalf("expect recent and pending records kept, got %d", left)
+	}
+}
+
+func TestSingleRelay(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	r := &recorder{}
+	lf := lock.NewGoCacheLockProviderWithCache(gcache.New(10).Build())
+
+	// 两个实例共享同一个发件箱与锁, 只有一个实例投递
+	relays := []*outbox.Outbox{}
+	for range 2 {
+		o := newOutbox(t, db, r).SetLockFactory(lf)
+		o.Interval = 10
+		o.LockTTL = 1
+		relays = append(relays, o)
+	}
+	for i := range 20 {
+		if err := relays[i%2].Publish(ctx, &bus.Event{Subject: "e"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, o := range relays {
+		o.Start(ctx)
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for pending(t, db) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expect all events relayed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for _, o := range relays {
+		o.Stop(ctx)
+	}
+	if n := len(r.subjects()); n != 20 {
+		t.Fatalf("expect each event relayed once, got %d", n)
+	}
+}
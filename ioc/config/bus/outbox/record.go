@@ -0,0 +1,68 @@
+package outbox
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/infraboard/mcube/v2/ioc/config/bus"
+)
+
+// Record 发件箱中的一条事件, 发送成功后记录发送时间, 超过保留时间后被清理
+type Record struct {
+	// 自增Id, Relay按照Id的顺序投递
+	Id int64 `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	// 事件主题
+	Subject string `gorm:"column:subject;type:varchar(255);not null" json:"subject"`
+	// 消息键
+	Key string `gorm:"column:event_key;type:varchar(255)" json:"key"`
+	// 事件Header, JSON格式
+	Header string `gorm:"column:header;type:text" json:"header"`
+	// 事件数据
+	Data []byte `gorm:"column:data" json:"data"`
+	// 写入时间, unix毫秒
+	CreatedAt int64 `gorm:"column:created_at;autoCreateTime:false;not null" json:"created_at"`
+	// 发送时间, unix毫秒, 0表示未发送
+	SentAt int64 `gorm:"column:sent_at;not null;default:0;index" json:"sent_at"`
+	// 发送失败的次数
+	Attempts int `gorm:"column:attempts;not null;default:0" json:"attempts"`
+	// 最后一次发送失败的原因
+	LastError string `gorm:"column:last_error;type:text" json:"last_error"`
+	// 放弃发送的时间, unix毫秒, 0表示未放弃, 放弃的事件不再投递, 通过Requeue重新投递
+	FailedAt int64 `gorm:"column:failed_at;not null;default:0" json:"failed_at"`
+}
+
+func (Record) TableName() string {
+	return OUTBOX_TABLE
+}
+
+func NewRecord(e *bus.Event) (*Record, error) {
+	r := &Record{
+		Subject:   e.Subject,
+		Key:       e.Key,
+		Data:      e.Data,
+		CreatedAt: time.Now().UnixMilli(),
+	}
+	if len(e.Header) > 0 {
+		header, err := json.Marshal(e.Header)
+		if err != nil {
+			return nil, err
+		}
+		r.Header = string(header)
+	}
+	return r, nil
+}
+
+// Event 还原为写入时的事件
+func (r *Record) Event() (*bus.Event, error) {
+	e := &bus.Event{
+		Subject: r.Subject,
+		Key:     r.Key,
+		Data:    r.Data,
+	}
+	if r.Header != "" {
+		if err := json.Unmarshal([]byte(r.Header), &e.Header); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
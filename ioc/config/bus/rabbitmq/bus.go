@@ -2,6 +2,7 @@ package rabbitmq
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"sync"
@@ -17,24 +18,43 @@ import (
 
 func init() {
 	ioc.Config().Registry(&BusServiceImpl{
-		publishers: map[string]*rabbitmq.Publisher{},
-		consumers:  map[string]*rabbitmq.Consumer{},
+		publishers:  map[string]*rabbitmq.Publisher{},
+		consumers:   map[string]*rabbitmq.Consumer{},
+		DelayMode:   DELAY_MODE_NONE,
+		DelayLevels: bus.DefaultDelayLevels(),
 	})
 }
 
+const (
+	PROVIDER = "rabbitmq"
+)
+
 var _ bus.Service = (*BusServiceImpl)(nil)
 
 type BusServiceImpl struct {
 	ioc.ObjectImpl
-	log *zerolog.Logger
+	log       *zerolog.Logger
+	telemetry *bus.Telemetry
 
 	// group 队列模式下的 队列名称或者消费组名称，一个组里面的实例消费一个队列
 	Group string `toml:"group" json:"group" yaml:"group"  env:"GROUP"`
 	// nodename 广播模式下的节点名称，默认hostname, 每个节点独立一个 节点队列: group.nodename
 	NodeName string `toml:"node_name" json:"node_name" yaml:"node_name" env:"NODE_NAME"`
 
+	// 采集发送与消费的Prometheus指标
+	Metric bool `toml:"metric" json:"metric" yaml:"metric"  env:"METRIC"`
+	// 延迟投递的方式: none(默认), ttl, plugin, ttl模式在初始化时启动就绪队列的调度器
+	DelayMode string `toml:"delay_mode" json:"delay_mode" yaml:"delay_mode"  env:"DELAY_MODE"`
+	// TTL模式下的延迟级别, 单位秒, 每个级别一个延迟队列
+	DelayLevels []int64 `toml:"delay_levels" json:"delay_levels" yaml:"delay_levels"  env:"DELAY_LEVELS" envSeparator:","`
+
 	publishers map[string]*rabbitmq.Publisher
 	consumers  map[string]*rabbitmq.Consumer
+	subs       bus.Subscriptions
+	// 已经声明的延迟交换机与延迟队列
+	declared sync.Map
+	// TTL模式下消费就绪队列的调度器
+	delayConsumer *rabbitmq.Consumer
 
 	mu sync.Mutex
 }
@@ -48,6 +68,14 @@ func (i *BusServiceImpl) Priority() int {
 }
 
 func (b *BusServiceImpl) Init() error {
+	b.telemetry = bus.NewTelemetry(PROVIDER)
+	if b.Metric {
+		m, err := bus.DefaultMetricCollector()
+		if err != nil {
+			return err
+		}
+		b.telemetry.SetMetric(m)
+	}
 	if b.Group == "" {
 		b.Group = application.Get().GetAppName()
 	}
@@ -62,10 +90,19 @@ func (b *BusServiceImpl) Init() error {
 	}
 
 	b.log = log.Sub(b.Name())
+	if b.DelayMode == DELAY_MODE_TTL {
+		return b.startDelayScheduler()
+	}
 	return nil
 }
 
+// Close 停止延迟调度, 等待处理中的消息确认后关闭消费者, 再关闭生产者
 func (b *BusServiceImpl) Close(ctx context.Context) {
+	b.stopDelayScheduler()
+	if err := b.subs.Drain(ctx); err != nil {
+		b.log.Error().Msgf("drain subscriptions error, %s", err)
+	}
+
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
@@ -116,6 +153,22 @@ func (b *BusServiceImpl) GetConsumer(subject string) (*rabbitmq.Consumer, error)
 
 // 发布逻辑（始终发布到 Topic Exchange）
 func (b *BusServiceImpl) Publish(ctx context.Context, e *bus.Event) error {
+	ctx, e, end := b.telemetry.StartPublish(ctx, e)
+	err := b.publish(ctx, e)
+	end(err)
+	return err
+}
+
+func (b *BusServiceImpl) publish(ctx context.Context, e *bus.Event) error {
+	p, err := b.GetPublisher(e.Subject)
+	if err != nil {
+		return err
+	}
+
+	return p.Publish(ctx, b.message(e))
+}
+
+func (b *BusServiceImpl) message(e *bus.Event) *rabbitmq.Message {
 	msg := &rabbitmq.Message{
 		Exchange:   b.Group,   // 固定为 Topic Exchange
 		RoutingKey: e.Subject, // 路由键 = 事件主题
@@ -123,71 +176,102 @@ func (b *BusServiceImpl) Publish(ctx context.Context, e *bus.Event) error {
 		Headers:    make(amqp091.Table),
 	}
 
+	// amqp的Header不支持[]string, 单个值保存为string, 多个值保存为[]any
 	for k, v := range e.Header {
-		msg.Headers[k] = v
+		if len(v) == 1 {
+			msg.Headers[k] = v[0]
+			continue
+		}
+		values := make([]any, 0, len(v))
+		for _, item := range v {
+			values = append(values, item)
+		}
+		msg.Headers[k] = values
 	}
+	return msg
+}
 
-	p, err := b.GetPublisher(e.Subject)
-	if err != nil {
-		return err
-	}
+// 订阅逻辑（广播模式）
+func (b *BusServiceImpl) TopicSubscribe(ctx context.Context, subject string, cb bus.EventHandler, opts ...bus.SubscribeOption) (bus.Subscription, error) {
+	// 使用group + nodename + 绑定到 Topic Exchange
+	return b.subscribe(ctx, subject, cb, opts, rabbitmq.WithDurable(true),
+		rabbitmq.WithAutoDelete(true),
+		rabbitmq.WithQueueName(bus.SanitizeQueueName(b.Group+"."+b.NodeName+"."+subject)))
+}
 
-	return p.Publish(ctx, msg)
+// 队列逻辑（竞争消费模式）
+func (b *BusServiceImpl) QueueSubscribe(ctx context.Context, subject string, cb bus.EventHandler, opts ...bus.SubscribeOption) (bus.Subscription, error) {
+	// 使用固定队列名 group + 绑定到 Topic Exchange
+	return b.subscribe(ctx, subject, cb, opts, rabbitmq.WithDurable(true),
+		rabbitmq.WithAutoDelete(false),
+		rabbitmq.WithQueueName(bus.SanitizeQueueName(b.Group+"."+subject)))
 }
 
-// 订阅逻辑（广播模式）
-func (b *BusServiceImpl) TopicSubscribe(ctx context.Context, subject string, cb bus.EventHandler) error {
-	consumer, err := b.GetConsumer(subject)
+// 每个订阅使用独立的Consumer, 取消订阅后停止消费, 处理中的消息确认后关闭Channel, 未确认的消息由Broker重新投递
+func (b *BusServiceImpl) subscribe(ctx context.Context, subject string, cb bus.EventHandler, opts []bus.SubscribeOption, consumeOpts ...rabbitmq.ConsumeOption) (bus.Subscription, error) {
+	sub, err := b.subs.New(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// 使用group + nodename + 绑定到 Topic Exchange
-	err = consumer.TopicSubscribe(ctx, b.Group, subject, func(ctx context.Context, msg *rabbitmq.Message) error {
-		cb(&bus.Event{
-			Subject: msg.RoutingKey,
-			Header:  b.convert(msg.Headers),
-			Data:    msg.Body,
-		})
-		return nil
-	}, rabbitmq.WithDurable(true),
-		rabbitmq.WithAutoDelete(true),
-		rabbitmq.WithQueueName(bus.SanitizeQueueName(b.Group+"."+b.NodeName+"."+subject)))
+	consumer, err := rabbitmq.NewConsumer()
 	if err != nil {
-		return err
+		return nil, errors.Join(err, sub.Unsubscribe())
+	}
+	if err := sub.OnDrained(consumer.Close); err != nil {
+		return nil, err
 	}
-	return nil
-}
 
-// 队列逻辑（竞争消费模式）
-func (b *BusServiceImpl) QueueSubscribe(ctx context.Context, subject string, cb bus.EventHandler) error {
-	consumer, err := b.GetConsumer(subject)
+	consumeCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	if err := sub.OnUnsubscribe(func() error { cancel(); return nil }); err != nil {
+		return nil, err
+	}
+	err = consumer.TopicSubscribe(consumeCtx, b.Group, subject, b.msgHandler(ctx, sub, cb, opts...), consumeOpts...)
 	if err != nil {
-		return err
+		return nil, errors.Join(err, sub.Unsubscribe())
 	}
-	// 使用固定队列名 group + 绑定到 Topic Exchange
-	err = consumer.TopicSubscribe(ctx, b.Group, subject, func(ctx context.Context, msg *rabbitmq.Message) error {
-		cb(&bus.Event{
+	return sub, nil
+}
+
+// 处理失败并且没有进入死信队列时返回错误, Consumer会Nack并重新入队, 被拒绝的消息直接确认
+func (b *BusServiceImpl) msgHandler(ctx context.Context, sub *bus.TrackedSubscription, cb bus.EventHandler, opts ...bus.SubscribeOption) rabbitmq.CallBackHandler {
+	o := bus.NewSubscribeOptions(opts...)
+	return func(_ context.Context, msg *rabbitmq.Message) error {
+		if !sub.Acquire() {
+			return bus.ErrSubscriptionClosed
+		}
+		defer sub.Release()
+
+		err := b.telemetry.Consume(ctx, &bus.Event{
 			Subject: msg.RoutingKey,
 			Header:  b.convert(msg.Headers),
 			Data:    msg.Body,
+		}, func(e *bus.Event) error {
+			return o.Handle(ctx, b, e, cb)
 		})
-		return nil
-	}, rabbitmq.WithDurable(true),
-		rabbitmq.WithAutoDelete(false),
-		rabbitmq.WithQueueName(bus.SanitizeQueueName(b.Group+"."+subject)))
-	if err != nil {
+		// 被拒绝的消息重新入队也无法处理, 记录日志后确认
+		if errors.Is(err, bus.ErrRejected) {
+			b.log.Error().Msgf("event %s rejected, %s", msg.RoutingKey, err)
+			return nil
+		}
 		return err
 	}
-	return nil
 }
 
 func (b *BusServiceImpl) convert(table amqp091.Table) map[string][]string {
 	headers := make(map[string][]string)
 	for k, v := range table {
-		if str, ok := v.(string); ok {
-			headers[k] = []string{str}
-		} else {
+		if brokerHeaders[k] {
+			continue
+		}
+		switch value := v.(type) {
+		case string:
+			headers[k] = []string{value}
+		case []any:
+			for _, item := range value {
+				headers[k] = append(headers[k], fmt.Sprintf("%v", item))
+			}
+		default:
 			headers[k] = []string{fmt.Sprintf("%v", v)}
 		}
 	}
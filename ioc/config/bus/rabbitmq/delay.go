@@ -0,0 +1,208 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/infraboard/mcube/v2/ioc/config/bus"
+	"github.com/infraboard/mcube/v2/ioc/config/rabbitmq"
+	"github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	// 使用rabbitmq_delayed_message_exchange插件, 延迟时间精确到毫秒
+	DELAY_MODE_PLUGIN = "plugin"
+	// 使用消息TTL与死信队列, 不需要插件, 延迟时间精确到秒, 每个延迟级别一个队列
+	DELAY_MODE_TTL = "ttl"
+	// 不使用RabbitMQ的延迟投递(默认), bus.PublishAt使用ioc/config/bus/delay
+	DELAY_MODE_NONE = "none"
+)
+
+const (
+	// 插件模式下的延迟时间, 单位毫秒
+	HEADER_DELAY = "x-delay"
+)
+
+// RabbitMQ投递时添加的Header, 转换为事件时忽略
+var brokerHeaders = map[string]bool{
+	HEADER_DELAY:             true,
+	"x-death":                true,
+	"x-first-death-exchange": true,
+	"x-first-death-queue":    true,
+	"x-first-death-reason":   true,
+	"x-last-death-exchange":  true,
+	"x-last-death-queue":     true,
+	"x-last-death-reason":    true,
+}
+
+// PublishAt 延迟投递, 到期后投递到Topic Exchange, 与Publish的路由相同,
+// TTL模式下发送到不超过延迟时间的最大级别的延迟队列, 过期后进入就绪队列,
+// 由调度器投递到Topic Exchange, 剩余的延迟时间大于0时转发到下一个级别, 没有开启延迟投递时返回bus.ErrDelayNotSupported
+func (b *BusServiceImpl) PublishAt(ctx context.Context, e *bus.Event, at time.Time) error {
+	if b.DelayMode == "" || b.DelayMode == DELAY_MODE_NONE {
+		return bus.ErrDelayNotSupported
+	}
+
+	ctx, e, end := b.telemetry.StartPublish(ctx, e)
+	err := b.publishAt(ctx, e, at)
+	end(err)
+	return err
+}
+
+func (b *BusServiceImpl) publishAt(ctx context.Context, e *bus.Event, at time.Time) error {
+	delay := time.Until(at)
+	if delay <= 0 {
+		return b.publish(ctx, e)
+	}
+
+	var msg *rabbitmq.Message
+	switch b.DelayMode {
+	case DELAY_MODE_PLUGIN:
+		exchange, err := b.declareDelayedExchange()
+		if err != nil {
+			return err
+		}
+		msg = b.message(e)
+		msg.Exchange = exchange
+		msg.Headers[HEADER_DELAY] = delay.Milliseconds()
+	case DELAY_MODE_TTL:
+		queue, err := b.declareDelayQueue(bus.DelayLevel(bus.DelayLevels(b.DelayLevels), delay))
+		if err != nil {
+			return err
+		}
+		d := *e
+		d.Header = bus.DelayHeader(e, at)
+		// 通过默认交换机直接发送到延迟队列
+		msg = b.message(&d)
+		msg.Exchange = ""
+		msg.RoutingKey = queue
+	default:
+		return fmt.Errorf("unknown delay mode %s", b.DelayMode)
+	}
+
+	p, err := b.GetPublisher(e.Subject)
+	if err != nil {
+		return err
+	}
+	return p.Publish(ctx, msg)
+}
+
+// 插件模式: x-delayed-message交换机绑定到Topic Exchange, 到期的消息按照路由键转发
+func (b *BusServiceImpl) declareDelayedExchange() (string, error) {
+	exchange := b.Group + ".delayed"
+	if _, ok := b.declared.Load(exchange); ok {
+		return exchange, nil
+	}
+
+	err := b.declare(func(ch *amqp091.Channel) error {
+		if err := b.declareTopicExchange(ch); err != nil {
+			return err
+		}
+		err := ch.ExchangeDeclare(exchange, "x-delayed-message", true, false, false, false, amqp091.Table{
+			"x-delayed-type": rabbitmq.EXCHANGE_TYPE_TOPIC.String(),
+		})
+		if err != nil {
+			return fmt.Errorf("declare delayed exchange %s error, rabbitmq_delayed_message_exchange plugin required, %w", exchange, err)
+		}
+		return ch.ExchangeBind(b.Group, "#", exchange, false, nil)
+	})
+	if err != nil {
+		return "", err
+	}
+	b.declared.Store(exchange, true)
+	return exchange, nil
+}
+
+func (b *BusServiceImpl) delayQueue(level time.Duration) string {
+	return bus.SanitizeQueueName(fmt.Sprintf("%s.delay.%ds", b.Group, int64(level/time.Second)))
+}
+
+// 延迟队列过期的消息进入就绪队列, 由调度器投递或者转发到下一个级别
+func (b *BusServiceImpl) readyQueue() string {
+	return bus.SanitizeQueueName(b.Group + ".delay.ready")
+}
+
+// TTL模式: 每个延迟级别一个队列, 同一个队列中的消息TTL相同, 过期顺序与发送顺序一致,
+// 过期后通过默认交换机进入就绪队列, 队列的数量固定为延迟级别的数量
+func (b *BusServiceImpl) declareDelayQueue(level time.Duration) (string, error) {
+	queue := b.delayQueue(level)
+	if _, ok := b.declared.Load(queue); ok {
+		return queue, nil
+	}
+
+	err := b.declare(func(ch *amqp091.Channel) error {
+		_, err := ch.QueueDeclare(queue, true, false, false, false, amqp091.Table{
+			"x-message-ttl":             level.Milliseconds(),
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": b.readyQueue(),
+		})
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	b.declared.Store(queue, true)
+	return queue, nil
+}
+
+// 应用的多个实例竞争消费同一个就绪队列
+func (b *BusServiceImpl) startDelayScheduler() error {
+	consumer, err := rabbitmq.NewConsumer()
+	if err != nil {
+		return err
+	}
+	if err := consumer.DirectSubscribe(context.Background(), "", b.readyQueue(), b.schedule); err != nil {
+		return errors.Join(err, consumer.Close())
+	}
+	b.delayConsumer = consumer
+	return nil
+}
+
+// 停止调度, 未确认的消息由其他实例或者重启后重新调度
+func (b *BusServiceImpl) stopDelayScheduler() {
+	if b.delayConsumer == nil {
+		return
+	}
+	if err := b.delayConsumer.Close(); err != nil {
+		b.log.Error().Msgf("close delay scheduler error, %s", err)
+	}
+}
+
+// 到期时投递到Topic Exchange, 否则发送到剩余延迟时间对应的级别, 失败时返回错误, 消息重新入队
+func (b *BusServiceImpl) schedule(ctx context.Context, msg *rabbitmq.Message) error {
+	e, at, err := bus.ParseDelayHeader(&bus.Event{
+		Header: b.convert(msg.Headers),
+		Data:   msg.Body,
+	})
+	if err != nil {
+		b.log.Error().Msgf("drop delay message from %s, %s", b.readyQueue(), err)
+		return nil
+	}
+
+	// 发送时已经写入了Trace上下文, 转发时保留
+	if !time.Now().Before(at) {
+		return b.publish(ctx, e)
+	}
+	return b.publishAt(ctx, e, at)
+}
+
+// 与消费者声明的Topic Exchange参数相同
+func (b *BusServiceImpl) declareTopicExchange(ch *amqp091.Channel) error {
+	return ch.ExchangeDeclare(b.Group, rabbitmq.EXCHANGE_TYPE_TOPIC.String(), true, false, false, false, nil)
+}
+
+// 使用临时Channel声明, 声明失败时RabbitMQ会关闭Channel
+func (b *BusServiceImpl) declare(fn func(ch *amqp091.Channel) error) error {
+	conn := rabbitmq.GetConn().GetConnection()
+	if conn == nil {
+		return fmt.Errorf("no active connection")
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+	return fn(ch)
+}
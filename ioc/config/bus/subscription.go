@@ -0,0 +1,215 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrClosed 总线已经关闭, 不能再创建订阅
+	ErrClosed = errors.New("bus: closed")
+	// ErrSubscriptionClosed 订阅已经取消, 之后收到的消息不再处理, 由提供方决定是否重新投递
+	ErrSubscriptionClosed = errors.New("bus: subscription closed")
+)
+
+// Subscription 订阅句柄
+type Subscription interface {
+	// 取消订阅, 不再接收新的消息, 不等待处理中的消息
+	Unsubscribe() error
+	// 取消订阅并等待处理中的消息完成, ctx取消时不再等待
+	Drain(ctx context.Context) error
+}
+
+// Subscriptions 提供方记录创建的订阅, 总线关闭时Drain所有订阅, 零值可用
+type Subscriptions struct {
+	mu     sync.Mutex
+	items  map[*TrackedSubscription]struct{}
+	closed bool
+}
+
+// New 创建一个订阅, ctx取消后自动取消订阅
+func (s *Subscriptions) New(ctx context.Context) (*TrackedSubscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, ErrClosed
+	}
+
+	sub := &TrackedSubscription{
+		parent: s,
+		done:   make(chan struct{}),
+	}
+	if s.items == nil {
+		s.items = map[*TrackedSubscription]struct{}{}
+	}
+	s.items[sub] = struct{}{}
+
+	if ctx.Done() != nil {
+		go func() {
+			select {
+			case <-ctx.Done():
+				_ = sub.Unsubscribe()
+			case <-sub.done:
+			}
+		}()
+	}
+	return sub, nil
+}
+
+// Drain 不再允许创建订阅, 并Drain当前所有的订阅
+func (s *Subscriptions) Drain(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	items := make([]*TrackedSubscription, 0, len(s.items))
+	for sub := range s.items {
+		items = append(items, sub)
+	}
+	s.mu.Unlock()
+
+	errs := make([]error, len(items))
+	wg := sync.WaitGroup{}
+	for i, sub := range items {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = sub.Drain(ctx)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func (s *Subscriptions) remove(sub *TrackedSubscription) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.items, sub)
+}
+
+// TrackedSubscription 记录处理中的消息, 提供方通过OnUnsubscribe停止从Broker接收消息,
+// 通过OnDrained在处理中的消息完成后释放资源
+type TrackedSubscription struct {
+	parent *Subscriptions
+	wg     sync.WaitGroup
+
+	mu          sync.Mutex
+	stopped     bool
+	released    bool
+	done        chan struct{}
+	unsubscribe func() error
+	drained     func() error
+}
+
+var _ Subscription = (*TrackedSubscription)(nil)
+
+// OnUnsubscribe 取消订阅时调用, 用于停止从Broker接收消息, 订阅已经取消时立即调用
+func (t *TrackedSubscription) OnUnsubscribe(fn func() error) error {
+	t.mu.Lock()
+	if t.stopped {
+		t.mu.Unlock()
+		return fn()
+	}
+	t.unsubscribe = fn
+	t.mu.Unlock()
+	return nil
+}
+
+// OnDrained 处理中的消息完成后调用, 用于释放资源, Unsubscribe时不等待直接调用, 已经释放时立即调用
+func (t *TrackedSubscription) OnDrained(fn func() error) error {
+	t.mu.Lock()
+	if t.released {
+		t.mu.Unlock()
+		return fn()
+	}
+	t.drained = fn
+	t.mu.Unlock()
+	return nil
+}
+
+// Acquire 开始处理一条消息, 订阅已经取消时返回false, 返回true时处理完成后需要调用Release
+func (t *TrackedSubscription) Acquire() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stopped {
+		return false
+	}
+	t.wg.Add(1)
+	return true
+}
+
+// Release 消息处理完成
+func (t *TrackedSubscription) Release() {
+	t.wg.Done()
+}
+
+// Handler 包装处理函数, 订阅取消后返回ErrSubscriptionClosed
+func (t *TrackedSubscription) Handler(cb EventHandler) EventHandler {
+	return func(e *Event) error {
+		if !t.Acquire() {
+			return ErrSubscriptionClosed
+		}
+		defer t.Release()
+		return cb(e)
+	}
+}
+
+// Done 订阅取消后关闭
+func (t *TrackedSubscription) Done() <-chan struct{} {
+	return t.done
+}
+
+// 取消订阅
+func (t *TrackedSubscription) Unsubscribe() error {
+	return errors.Join(t.stop(), t.release())
+}
+
+// 取消订阅并等待处理中的消息完成
+func (t *TrackedSubscription) Drain(ctx context.Context) error {
+	err := t.stop()
+
+	wait := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(wait)
+	}()
+	select {
+	case <-wait:
+	case <-ctx.Done():
+		err = errors.Join(err, ctx.Err())
+	}
+	return errors.Join(err, t.release())
+}
+
+func (t *TrackedSubscription) stop() error {
+	t.mu.Lock()
+	if t.stopped {
+		t.mu.Unlock()
+		return nil
+	}
+	t.stopped = true
+	close(t.done)
+	fn := t.unsubscribe
+	t.mu.Unlock()
+
+	if fn != nil {
+		return fn()
+	}
+	return nil
+}
+
+func (t *TrackedSubscription) release() error {
+	t.mu.Lock()
+	if t.released {
+		t.mu.Unlock()
+		return nil
+	}
+	t.released = true
+	fn := t.drained
+	t.mu.Unlock()
+
+	t.parent.remove(t)
+	if fn != nil {
+		return fn()
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package bus
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	INSTRUMENTATION_NAME = "github.com/infraboard/mcube/v2/ioc/config/bus"
+)
+
+// NewTelemetry 提供方在发送与消费时记录Trace与指标,
+// Trace使用otel全局的TracerProvider与Propagator, 未开启trace时不记录
+func NewTelemetry(provider string) *Telemetry {
+	return &Telemetry{
+		provider: provider,
+	}
+}
+
+type Telemetry struct {
+	provider string
+	metric   *MetricCollector
+}
+
+// SetMetric 设置指标采集器, 为nil时不采集指标
+func (t *Telemetry) SetMetric(m *MetricCollector) *Telemetry {
+	t.metric = m
+	return t
+}
+
+func (t *Telemetry) tracer() trace.Tracer {
+	return otel.GetTracerProvider().Tracer(INSTRUMENTATION_NAME)
+}
+
+// StartPublish 开始发送的Span, 返回写入了Trace上下文(W3C traceparent)的事件, 发送完成后调用end
+func (t *Telemetry) StartPublish(ctx context.Context, e *Event) (context.Context, *Event, func(error)) {
+	ctx, span := t.tracer().Start(ctx, "publish "+e.Subject,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(t.attributes(e, "publish")...),
+	)
+
+	// 不修改调用方的Header
+	header := make(map[string][]string, len(e.Header)+2)
+	for k, v := range e.Header {
+		header[k] = v
+	}
+	otel.GetTextMapPropagator().Inject(ctx, EventCarrier(header))
+	c := *e
+	c.Header = header
+
+	return ctx, &c, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+
+		if t.metric != nil {
+			if err != nil {
+				t.metric.PublishFailedTotal.WithLabelValues(t.provider, e.Subject).Inc()
+			} else {
+				t.metric.PublishedTotal.WithLabelValues(t.provider, e.Subject).Inc()
+			}
+		}
+	}
+}
+
+// Consume 从Header中提取发送方的Trace上下文, 开始消费的子Span并链接到发送方的Span,
+// handle中可以通过Event.Context()获取消费Span的上下文
+func (t *Telemetry) Consume(ctx context.Context, e *Event, handle func(e *Event) error) error {
+	parent := otel.GetTextMapPropagator().Extract(ctx, EventCarrier(e.Header))
+	opts := []trace.SpanStartOption{
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(t.attributes(e, "process")...),
+	}
+	if sc := trace.SpanContextFromContext(parent); sc.IsValid() {
+		opts = append(opts, trace.WithLinks(trace.Link{SpanContext: sc}))
+	}
+	ctx, span := t.tracer().Start(parent, "process "+e.Subject, opts...)
+	defer span.End()
+
+	start := time.Now()
+	err := handle(e.WithContext(ctx))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	if t.metric != nil {
+		t.metric.HandlerDuration.WithLabelValues(t.provider, e.Subject).Observe(time.Since(start).Seconds())
+		if err != nil {
+			t.metric.ConsumeFailedTotal.WithLabelValues(t.provider, e.Subject).Inc()
+		} else {
+			t.metric.ConsumedTotal.WithLabelValues(t.provider, e.Subject).Inc()
+		}
+	}
+	return err
+}
+
+func (t *Telemetry) attributes(e *Event, operation string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		semconv.MessagingSystemKey.String(t.provider),
+		semconv.MessagingOperationName(operation),
+		semconv.MessagingDestinationName(e.Subject),
+		semconv.MessagingMessageBodySize(len(e.Data)),
+	}
+}
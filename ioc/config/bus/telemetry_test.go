@@ -0,0 +1,96 @@
+package bus_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/infraboard/mcube/v2/ioc/config/bus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestTelemetryTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	b := newBus()
+	ctx, root := provider.Tracer("test").Start(context.Background(), "root")
+
+	var handled trace.SpanContext
+	_, err := b.TopicSubscribe(context.Background(), "order", func(e *bus.Event) error {
+		handled = trace.SpanContextFromContext(e.Context())
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := map[string][]string{"X-Custom": {"1"}}
+	if err := b.Publish(ctx, &bus.Event{Subject: "order", Header: header}); err != nil {
+		t.Fatal(err)
+	}
+	root.End()
+
+	// 不修改调用方的Header
+	if _, ok := header["traceparent"]; ok {
+		t.Fatal("publish should not modify caller header")
+	}
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, s := range recorder.Ended() {
+		spans[s.Name()] = s
+	}
+	publish, process := spans["publish order"], spans["process order"]
+	if publish == nil || process == nil {
+		t.Fatalf("expect publish and process span, got %v", spans)
+	}
+	if publish.Parent().SpanID() != root.SpanContext().SpanID() || publish.SpanKind() != trace.SpanKindProducer {
+		t.Fatalf("expect producer span child of root")
+	}
+	if process.Parent().SpanID() != publish.SpanContext().SpanID() || process.SpanKind() != trace.SpanKindConsumer {
+		t.Fatalf("expect consumer span child of producer span")
+	}
+	if len(process.Links()) != 1 || process.Links()[0].SpanContext.SpanID() != publish.SpanContext().SpanID() {
+		t.Fatalf("expect consumer span linked to producer span, got %v", process.Links())
+	}
+	if handled.SpanID() != process.SpanContext().SpanID() {
+		t.Fatalf("expect handler context with consumer span, got %v", handled)
+	}
+}
+
+func TestTelemetryMetric(t *testing.T) {
+	m := bus.NewMetricCollector("test")
+	tm := bus.NewTelemetry("memory").SetMetric(m)
+	ctx := context.Background()
+	e := &bus.Event{Subject: "order"}
+
+	_, _, end := tm.StartPublish(ctx, e)
+	end(nil)
+	_, _, end = tm.StartPublish(ctx, e)
+	end(errors.New("publish error"))
+
+	_ = tm.Consume(ctx, e, func(e *bus.Event) error { return nil })
+	_ = tm.Consume(ctx, e, func(e *bus.Event) error { return errors.New("handle error") })
+
+	for name, c := range map[string]float64{
+		"published":      testutil.ToFloat64(m.PublishedTotal.WithLabelValues("memory", "order")),
+		"publish failed": testutil.ToFloat64(m.PublishFailedTotal.WithLabelValues("memory", "order")),
+		"consumed":       testutil.ToFloat64(m.ConsumedTotal.WithLabelValues("memory", "order")),
+		"consume failed": testutil.ToFloat64(m.ConsumeFailedTotal.WithLabelValues("memory", "order")),
+	} {
+		if c != 1 {
+			t.Fatalf("expect %s 1, got %v", name, c)
+		}
+	}
+	if n := testutil.CollectAndCount(m, "bus_handler_duration_seconds"); n != 1 {
+		t.Fatalf("expect handler duration observed, got %d", n)
+	}
+}
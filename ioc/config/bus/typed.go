@@ -0,0 +1,263 @@
+package bus
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/infraboard/mcube/v2/ioc/config/application"
+	"go.opentelemetry.io/otel"
+)
+
+const (
+	// 类型化事件的标准Header
+	// 数据的编码
+	HEADER_CONTENT_TYPE = "Content-Type"
+	// 事件Id
+	HEADER_EVENT_ID = "X-Event-Id"
+	// 事件类型
+	HEADER_EVENT_TYPE = "X-Event-Type"
+	// 事件版本
+	HEADER_EVENT_VERSION = "X-Event-Version"
+	// 发送事件的应用
+	HEADER_EVENT_SOURCE = "X-Event-Source"
+	// 事件时间, RFC3339Nano格式
+	HEADER_EVENT_TIME = "X-Event-Time"
+)
+
+const (
+	// 默认的事件版本, 没有版本Header的事件也按照该版本处理
+	DEFAULT_EVENT_VERSION = "v1"
+)
+
+// Message 类型化的事件
+type Message[T any] struct {
+	// 事件主题
+	Subject string
+	// 消息键
+	Key string
+	// 事件Id
+	Id string
+	// 事件类型
+	Type string
+	// 事件版本
+	Version string
+	// 发送事件的应用
+	Source string
+	// 事件时间
+	Time time.Time
+	// 原始的Header
+	Header map[string][]string
+	// 解码后的数据
+	Payload T
+}
+
+// Handler 类型化事件的处理函数, ctx中包含消费Span的上下文, 提供方没有记录消费Span时为发送方的Trace上下文
+type Handler[T any] func(ctx context.Context, m *Message[T]) error
+
+// EventOption 类型化事件的选项
+type EventOption func(*EventOptions)
+
+// WithService 使用指定的总线, 默认使用ioc中的总线
+func WithService(s Service) EventOption {
+	return func(o *EventOptions) {
+		o.Service = s
+	}
+}
+
+// WithCodec 发送时使用的编码, 默认JSON, 订阅时按照事件的Content-Type解码
+func WithCodec(c Codec) EventOption {
+	return func(o *EventOptions) {
+		o.Codec = c
+	}
+}
+
+// WithEventType 事件类型, 默认为数据的Go类型名称
+func WithEventType(t string) EventOption {
+	return func(o *EventOptions) {
+		o.Type = t
+	}
+}
+
+// WithVersion 发送时的事件版本, 订阅时接收的事件版本, 默认为v1
+func WithVersion(v string) EventOption {
+	return func(o *EventOptions) {
+		o.Version = v
+	}
+}
+
+// WithAcceptVersions 订阅时额外接收的事件版本, 用于兼容旧版本的发送方
+func WithAcceptVersions(versions ...string) EventOption {
+	return func(o *EventOptions) {
+		o.AcceptVersions = append(o.AcceptVersions, versions...)
+	}
+}
+
+// WithKey 发送时的消息键, kafka中相同键的事件发送到同一个分区
+func WithKey(key string) EventOption {
+	return func(o *EventOptions) {
+		o.Key = key
+	}
+}
+
+// WithQueue 使用队列订阅, 默认为主题订阅
+func WithQueue() EventOption {
+	return func(o *EventOptions) {
+		o.Queue = true
+	}
+}
+
+// WithSubscribeOptions 订阅选项, 比如重试与死信
+func WithSubscribeOptions(opts ...SubscribeOption) EventOption {
+	return func(o *EventOptions) {
+		o.SubscribeOptions = append(o.SubscribeOptions, opts...)
+	}
+}
+
+func NewEventOptions[T any](opts ...EventOption) *EventOptions {
+	t := reflect.TypeFor[T]()
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	o := &EventOptions{
+		Codec:   JSON,
+		Type:    t.String(),
+		Version: DEFAULT_EVENT_VERSION,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+type EventOptions struct {
+	Service          Service
+	Codec            Codec
+	Type             string
+	Version          string
+	Key              string
+	AcceptVersions   []string
+	Queue            bool
+	SubscribeOptions []SubscribeOption
+}
+
+func (o *EventOptions) getService() Service {
+	if o.Service != nil {
+		return o.Service
+	}
+	return GetService()
+}
+
+func (o *EventOptions) accept(version string) bool {
+	return version == o.Version || slices.Contains(o.AcceptVersions, version)
+}
+
+// Publish 编码数据并添加标准Header后发送, ctx中的Trace上下文写入Header
+func Publish[T any](ctx context.Context, subject string, payload T, opts ...EventOption) error {
+	o := NewEventOptions[T](opts...)
+	e, err := NewEvent(ctx, subject, payload, o)
+	if err != nil {
+		return err
+	}
+	return o.getService().Publish(ctx, e)
+}
+
+// NewEvent 创建类型化事件对应的Event
+func NewEvent[T any](ctx context.Context, subject string, payload T, o *EventOptions) (*Event, error) {
+	data, err := o.Codec.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	header := map[string][]string{
+		HEADER_CONTENT_TYPE:  {o.Codec.ContentType()},
+		HEADER_EVENT_ID:      {uuid.NewString()},
+		HEADER_EVENT_TYPE:    {o.Type},
+		HEADER_EVENT_VERSION: {o.Version},
+		HEADER_EVENT_SOURCE:  {application.Get().GetAppName()},
+		HEADER_EVENT_TIME:    {time.Now().Format(time.RFC3339Nano)},
+	}
+	otel.GetTextMapPropagator().Inject(ctx, EventCarrier(header))
+	return &Event{
+		Subject: subject,
+		Key:     o.Key,
+		Header:  header,
+		Data:    data,
+	}, nil
+}
+
+// Subscribe 订阅类型化事件, 版本不被接收或者无法解码的事件会被拒绝(ErrRejected), 不会调用处理函数
+func Subscribe[T any](ctx context.Context, subject string, h Handler[T], opts ...EventOption) (Subscription, error) {
+	o := NewEventOptions[T](opts...)
+	cb := func(e *Event) error {
+		m, err := DecodeMessage[T](e, o)
+		if err != nil {
+			return err
+		}
+		return h(handlerContext(ctx, e), m)
+	}
+
+	if o.Queue {
+		return o.getService().QueueSubscribe(ctx, subject, cb, o.SubscribeOptions...)
+	}
+	return o.getService().TopicSubscribe(ctx, subject, cb, o.SubscribeOptions...)
+}
+
+// DecodeMessage 检查事件版本并解码
+func DecodeMessage[T any](e *Event, o *EventOptions) (*Message[T], error) {
+	m := &Message[T]{
+		Subject: e.Subject,
+		Key:     e.Key,
+		Id:      headerValue(e.Header, HEADER_EVENT_ID),
+		Type:    headerValue(e.Header, HEADER_EVENT_TYPE),
+		Version: headerValue(e.Header, HEADER_EVENT_VERSION),
+		Source:  headerValue(e.Header, HEADER_EVENT_SOURCE),
+		Header:  e.Header,
+	}
+	if m.Version == "" {
+		m.Version = DEFAULT_EVENT_VERSION
+	}
+	if !o.accept(m.Version) {
+		return nil, Reject(fmt.Errorf("unknown event %s version %s", m.Type, m.Version))
+	}
+	if t := headerValue(e.Header, HEADER_EVENT_TIME); t != "" {
+		m.Time, _ = time.Parse(time.RFC3339Nano, t)
+	}
+
+	codec := o.Codec
+	if contentType := headerValue(e.Header, HEADER_CONTENT_TYPE); contentType != "" {
+		codec = GetCodec(contentType)
+		if codec == nil {
+			return nil, Reject(fmt.Errorf("unknown content type %s", contentType))
+		}
+	}
+
+	// 指针类型需要先分配对象, protobuf只能解码到已分配的消息中
+	target := any(&m.Payload)
+	if t := reflect.TypeFor[T](); t.Kind() == reflect.Pointer {
+		m.Payload = reflect.New(t.Elem()).Interface().(T)
+		target = m.Payload
+	}
+	if err := codec.Unmarshal(e.Data, target); err != nil {
+		return nil, Reject(fmt.Errorf("decode event %s error, %w", m.Type, err))
+	}
+	return m, nil
+}
+
+// 提供方记录了消费Span时使用消费Span的上下文, 否则从Header中提取发送方的Trace上下文
+func handlerContext(ctx context.Context, e *Event) context.Context {
+	if e.ctx != nil {
+		return e.ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, EventCarrier(e.Header))
+}
+
+func headerValue(header map[string][]string, key string) string {
+	if v := header[key]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
@@ -0,0 +1,169 @@
+package bus_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/infraboard/mcube/v2/ioc/config/bus"
+	"github.com/infraboard/mcube/v2/ioc/config/bus/memory"
+	"github.com/infraboard/mcube/v2/pb/resource"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type OrderCreated struct {
+	OrderId string `json:"order_id" msgpack:"order_id"`
+	Amount  int64  `json:"amount" msgpack:"amount"`
+}
+
+func newBus() *memory.BusServiceImpl {
+	b := memory.New()
+	b.Sync = true
+	return b
+}
+
+func TestTypedEvent(t *testing.T) {
+	ctx := context.Background()
+	b := newBus()
+
+	for _, codec := range []bus.Codec{bus.JSON, bus.Msgpack} {
+		var got *bus.Message[OrderCreated]
+		sub, err := bus.Subscribe(ctx, "order", func(ctx context.Context, m *bus.Message[OrderCreated]) error {
+			got = m
+			return nil
+		}, bus.WithService(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = bus.Publish(ctx, "order", OrderCreated{OrderId: "o1", Amount: 100}, bus.WithService(b), bus.WithCodec(codec), bus.WithKey("o1"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got == nil || got.Payload.OrderId != "o1" || got.Payload.Amount != 100 {
+			t.Fatalf("%s: unexpected payload %v", codec.ContentType(), got)
+		}
+		if got.Id == "" || got.Key != "o1" || got.Type != "bus_test.OrderCreated" || got.Version != bus.DEFAULT_EVENT_VERSION ||
+			got.Time.IsZero() || got.Header[bus.HEADER_CONTENT_TYPE][0] != codec.ContentType() {
+			t.Fatalf("%s: unexpected standard header %v", codec.ContentType(), got.Header)
+		}
+		if err := sub.Unsubscribe(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestTypedProtobufEvent(t *testing.T) {
+	ctx := context.Background()
+	b := newBus()
+
+	var got *resource.Meta
+	_, err := bus.Subscribe(ctx, "meta", func(ctx context.Context, m *bus.Message[*resource.Meta]) error {
+		got = m.Payload
+		return nil
+	}, bus.WithService(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = bus.Publish(ctx, "meta", &resource.Meta{Id: "m1", CreateAt: 1}, bus.WithService(b), bus.WithCodec(bus.Protobuf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.Id != "m1" || got.CreateAt != 1 {
+		t.Fatalf("unexpected payload %v", got)
+	}
+
+	// 非proto.Message不能使用protobuf编码
+	if err := bus.Publish(ctx, "meta", OrderCreated{}, bus.WithService(b), bus.WithCodec(bus.Protobuf)); err == nil {
+		t.Fatal("expect protobuf codec error")
+	}
+}
+
+func TestTypedEventVersion(t *testing.T) {
+	ctx := context.Background()
+	b := newBus()
+
+	var called, attempts int
+	_, err := bus.Subscribe(ctx, "order", func(ctx context.Context, m *bus.Message[OrderCreated]) error {
+		called++
+		return nil
+	},
+		bus.WithService(b),
+		bus.WithVersion("v2"),
+		bus.WithAcceptVersions("v1"),
+		bus.WithSubscribeOptions(bus.WithRetry(3, time.Millisecond, time.Millisecond), bus.WithDeadLetter("order.dlq")),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dead *bus.Event
+	_, err = b.TopicSubscribe(ctx, "order.dlq", func(e *bus.Event) error {
+		attempts++
+		dead = e
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []string{"v1", "v2", "v3"} {
+		if err := bus.Publish(ctx, "order", OrderCreated{}, bus.WithService(b), bus.WithVersion(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if called != 2 {
+		t.Fatalf("expect v1 and v2 handled, got %d", called)
+	}
+	// 未知版本不重试, 直接进入死信
+	if attempts != 1 || dead.Header[bus.HEADER_EVENT_VERSION][0] != "v3" ||
+		dead.Header[bus.HEADER_DEAD_LETTER_ATTEMPTS][0] != "1" {
+		t.Fatalf("expect v3 rejected to dead letter, got %v", dead)
+	}
+}
+
+func TestDecodeRejected(t *testing.T) {
+	o := bus.NewEventOptions[OrderCreated]()
+	_, err := bus.DecodeMessage[OrderCreated](&bus.Event{
+		Header: map[string][]string{bus.HEADER_CONTENT_TYPE: {"application/unknown"}},
+	}, o)
+	if !errors.Is(err, bus.ErrRejected) {
+		t.Fatalf("expect unknown content type rejected, got %v", err)
+	}
+
+	_, err = bus.DecodeMessage[OrderCreated](&bus.Event{Data: []byte("{")}, o)
+	if !errors.Is(err, bus.ErrRejected) {
+		t.Fatalf("expect invalid data rejected, got %v", err)
+	}
+}
+
+func TestTypedEventTrace(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	b := newBus()
+
+	traceId, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanId, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceId,
+		SpanID:     spanId,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	var got trace.SpanContext
+	_, err := bus.Subscribe(context.Background(), "order", func(ctx context.Context, m *bus.Message[OrderCreated]) error {
+		got = trace.SpanContextFromContext(ctx)
+		return nil
+	}, bus.WithService(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Publish(ctx, "order", OrderCreated{}, bus.WithService(b)); err != nil {
+		t.Fatal(err)
+	}
+	if got.TraceID() != traceId || !got.IsRemote() {
+		t.Fatalf("expect trace context propagated, got %v", got)
+	}
+}
@@ -4,12 +4,19 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/bluele/gcache"
 	"github.com/redis/go-redis/v9"
 )
 
+// NewRedisCache 基于Redis的缓存, ttl单位秒
+func NewRedisCache(client redis.UniversalClient, ttl int64) Cache {
+	return &redisCache{redis: client, ttl: ttl}
+}
+
 type redisCache struct {
 	redis redis.UniversalClient
 	ttl   int64
@@ -20,46 +27,65 @@ func (r *redisCache) Set(
 	key string,
 	value any,
 	opts ...SetOption) error {
-	options := newOptions(r.ttl, opts...)
 	data, err := json.Marshal(value)
 	if err != nil {
 		return err
 	}
-	return r.redis.Set(ctx, key, string(data), options.GetTTL()).Err()
+	return r.SetBytes(ctx, key, data, opts...)
+}
+
+func (r *redisCache) SetBytes(
+	ctx context.Context,
+	key string,
+	data []byte,
+	opts ...SetOption) error {
+	options := newOptions(r.ttl, opts...)
+	return r.redis.Set(ctx, key, data, options.GetTTL()).Err()
 }
 
 func (r *redisCache) Get(
 	ctx context.Context,
 	key string,
 	value any) error {
-	res := r.redis.Get(ctx, key)
-	if err := res.Err(); err != nil {
-		if err == redis.Nil {
-			return ErrKeyNotFound
-		}
+	data, err := r.GetBytes(ctx, key)
+	if err != nil {
 		return err
 	}
+	return json.Unmarshal(data, value)
+}
 
-	v, err := res.Result()
+func (r *redisCache) GetBytes(
+	ctx context.Context,
+	key string) ([]byte, error) {
+	data, err := r.redis.Get(ctx, key).Bytes()
 	if err != nil {
-		return err
+		if err == redis.Nil {
+			return nil, ErrKeyNotFound
+		}
+		return nil, err
 	}
-	return json.Unmarshal([]byte(v), value)
+	return data, nil
 }
 
 func (r *redisCache) Exist(
 	ctx context.Context,
 	key string) error {
-	res := r.redis.Exists(ctx, key)
-	if err := res.Err(); err != nil {
+	n, err := r.redis.Exists(ctx, key).Result()
+	if err != nil {
 		return err
 	}
+	if n == 0 {
+		return ErrKeyNotFound
+	}
 	return nil
 }
 
 func (r *redisCache) Del(
 	ctx context.Context,
 	keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
 	return r.redis.Del(ctx, keys...).Err()
 }
 
@@ -75,24 +101,190 @@ func (r *redisCache) IncrBy(
 	return res.Val(), nil
 }
 
+func (r *redisCache) MGet(
+	ctx context.Context,
+	keys ...string) (map[string][]byte, error) {
+	result := make(map[string][]byte, len(keys))
+	if len(keys) == 0 {
+		return result, nil
+	}
+	values, err := r.redis.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range values {
+		if s, ok := v.(string); ok {
+			result[keys[i]] = []byte(s)
+		}
+	}
+	return result, nil
+}
+
+func (r *redisCache) MSet(
+	ctx context.Context,
+	values map[string]any,
+	opts ...SetOption) error {
+	options := newOptions(r.ttl, opts...)
+	pipe := r.redis.TxPipeline()
+	for key, value := range values {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		pipe.Set(ctx, key, data, options.GetTTL())
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (r *redisCache) SetNX(
+	ctx context.Context,
+	key string,
+	value any,
+	opts ...SetOption) (bool, error) {
+	options := newOptions(r.ttl, opts...)
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+	return r.redis.SetNX(ctx, key, data, options.GetTTL()).Result()
+}
+
+func (r *redisCache) TTL(
+	ctx context.Context,
+	key string) (time.Duration, error) {
+	ttl, err := r.redis.PTTL(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	// go-redis 对-1与-2不做单位转换
+	switch ttl {
+	case -2:
+		return 0, ErrKeyNotFound
+	case -1:
+		return NO_EXPIRATION, nil
+	}
+	return ttl, nil
+}
+
+func (r *redisCache) Expire(
+	ctx context.Context,
+	key string,
+	expiration int64) error {
+	var (
+		ok  bool
+		err error
+	)
+	if expiration > 0 {
+		ok, err = r.redis.Expire(ctx, key, time.Duration(expiration)*time.Second).Result()
+	} else {
+		ok, err = r.redis.Persist(ctx, key).Result()
+		// 已经是永不过期的key Persist也返回false
+		if err == nil && !ok {
+			if err := r.Exist(ctx, key); err != nil {
+				return err
+			}
+			return nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrKeyNotFound
+	}
+	return nil
+}
+
+func (r *redisCache) Keys(
+	ctx context.Context,
+	prefix string) ([]string, error) {
+	keys := []string{}
+	err := r.Scan(ctx, prefix, func(key string) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys, err
+}
+
+func (r *redisCache) Scan(
+	ctx context.Context,
+	prefix string,
+	fn func(key string) bool) error {
+	// 集群模式下需要遍历所有的主节点
+	if c, ok := r.redis.(*redis.ClusterClient); ok {
+		return c.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+			return scanKeys(ctx, client, prefix, fn)
+		})
+	}
+	return scanKeys(ctx, r.redis, prefix, fn)
+}
+
+func scanKeys(ctx context.Context, c redis.Cmdable, prefix string, fn func(key string) bool) error {
+	iter := c.Scan(ctx, 0, escapeMatchPattern(prefix)+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		if !fn(iter.Val()) {
+			return nil
+		}
+	}
+	return iter.Err()
+}
+
+// 转义SCAN MATCH的通配符
+func escapeMatchPattern(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`).Replace(s)
+}
+
+// NewGoCache 基于gcache的本地缓存, ttl单位秒
+func NewGoCache(gc gcache.Cache, ttl int64) Cache {
+	return &goCache{gc: gc, ttl: ttl}
+}
+
 type goCache struct {
 	gc   gcache.Cache
 	ttl  int64
 	lock sync.Mutex
 }
 
+// gcache中保存的数据, gcache不支持查询过期时间, 需要自己记录
+type goCacheItem struct {
+	data      []byte
+	expiredAt time.Time
+}
+
+func (i *goCacheItem) ttl() time.Duration {
+	if i.expiredAt.IsZero() {
+		return NO_EXPIRATION
+	}
+	return time.Until(i.expiredAt)
+}
+
 func (r *goCache) Set(
 	ctx context.Context,
 	key string,
 	value any,
 	opts ...SetOption) error {
-	options := newOptions(r.ttl, opts...)
 	b, err := json.Marshal(value)
 	if err != nil {
 		return err
 	}
+	return r.SetBytes(ctx, key, b, opts...)
+}
+
+func (r *goCache) SetBytes(
+	ctx context.Context,
+	key string,
+	data []byte,
+	opts ...SetOption) error {
+	options := newOptions(r.ttl, opts...)
+	return r.set(key, data, options.GetTTL())
+}
 
-	return r.gc.SetWithExpire(key, b, options.GetTTL())
+func (r *goCache) set(key string, data []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		return r.gc.Set(key, &goCacheItem{data: data})
+	}
+	return r.gc.SetWithExpire(key, &goCacheItem{data: data, expiredAt: time.Now().Add(ttl)}, ttl)
 }
 
 func (r *goCache) IncrBy(
@@ -103,43 +295,68 @@ func (r *goCache) IncrBy(
 	r.lock.Lock()
 	defer r.lock.Unlock()
 
-	var v int64
-	err := r.Get(ctx, key, &v)
-	if err != nil {
-		if err == gcache.KeyNotFoundError {
-			err := r.Set(ctx, key, value)
-			if err != nil {
-				return 0, err
-			}
-			return value, nil
+	// 与Redis保持一致, key不存在时从0开始并且永不过期, 存在时保留原有的过期时间
+	var (
+		v   int64
+		ttl time.Duration
+	)
+	item, err := r.item(key)
+	switch err {
+	case nil:
+		if err := json.Unmarshal(item.data, &v); err != nil {
+			return 0, fmt.Errorf("value is not an integer, %s", err)
 		}
+		ttl = item.ttl()
+	case ErrKeyNotFound:
+	default:
 		return 0, err
 	}
 
 	v = v + value
-	err = r.Set(ctx, key, v)
+	data, err := json.Marshal(v)
 	if err != nil {
 		return 0, err
 	}
+	if err := r.set(key, data, ttl); err != nil {
+		return 0, err
+	}
 	return v, nil
 }
 
-func (r *goCache) Get(ctx context.Context, key string, value any) error {
+func (r *goCache) item(key string) (*goCacheItem, error) {
 	data, err := r.gc.Get(key)
 	if err != nil {
 		if err == gcache.KeyNotFoundError {
-			return ErrKeyNotFound
+			return nil, ErrKeyNotFound
 		}
+		return nil, err
+	}
+	item, ok := data.(*goCacheItem)
+	if !ok {
+		return nil, fmt.Errorf("key %s is not managed by cache, type %T", key, data)
+	}
+	return item, nil
+}
+
+func (r *goCache) Get(ctx context.Context, key string, value any) error {
+	data, err := r.GetBytes(ctx, key)
+	if err != nil {
 		return err
 	}
-	return json.Unmarshal(data.([]byte), value)
+	return json.Unmarshal(data, value)
+}
+
+func (r *goCache) GetBytes(ctx context.Context, key string) ([]byte, error) {
+	item, err := r.item(key)
+	if err != nil {
+		return nil, err
+	}
+	return item.data, nil
 }
 
 func (r *goCache) Exist(ctx context.Context, key string) error {
-	r.lock.Lock()
-	defer r.lock.Unlock()
 	if !r.gc.Has(key) {
-		return fmt.Errorf("not exist")
+		return ErrKeyNotFound
 	}
 	return nil
 }
@@ -150,3 +367,98 @@ func (r *goCache) Del(ctx context.Context, keys ...string) error {
 	}
 	return nil
 }
+
+func (r *goCache) MGet(ctx context.Context, keys ...string) (map[string][]byte, error) {
+	result := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		item, err := r.item(key)
+		if err == ErrKeyNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		result[key] = item.data
+	}
+	return result, nil
+}
+
+func (r *goCache) MSet(ctx context.Context, values map[string]any, opts ...SetOption) error {
+	options := newOptions(r.ttl, opts...)
+	items := make(map[string][]byte, len(values))
+	for key, value := range values {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		items[key] = data
+	}
+	for key, data := range items {
+		if err := r.set(key, data, options.GetTTL()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *goCache) SetNX(ctx context.Context, key string, value any, opts ...SetOption) (bool, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.gc.Has(key) {
+		return false, nil
+	}
+	options := newOptions(r.ttl, opts...)
+	if err := r.set(key, data, options.GetTTL()); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *goCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	item, err := r.item(key)
+	if err != nil {
+		return 0, err
+	}
+	return item.ttl(), nil
+}
+
+func (r *goCache) Expire(ctx context.Context, key string, expiration int64) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	item, err := r.item(key)
+	if err != nil {
+		return err
+	}
+	return r.set(key, item.data, time.Duration(expiration)*time.Second)
+}
+
+func (r *goCache) Keys(ctx context.Context, prefix string) ([]string, error) {
+	keys := []string{}
+	err := r.Scan(ctx, prefix, func(key string) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys, err
+}
+
+func (r *goCache) Scan(ctx context.Context, prefix string, fn func(key string) bool) error {
+	for _, k := range r.gc.Keys(true) {
+		key, ok := k.(string)
+		if !ok || !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		// 跳过已过期以及其他模块写入的key
+		if _, err := r.item(key); err != nil {
+			continue
+		}
+		if !fn(key) {
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/infraboard/mcube/v2/ioc/config/log"
+)
+
+const (
+	// 标签版本号的key前缀
+	TAG_KEY_PREFIX = "mcube.cache.tag."
+)
+
+// Method 可以被缓存的方法
+type Method[Req, Resp any] func(ctx context.Context, req Req) (Resp, error)
+
+// 缓存的方法结果, 记录写入时标签的版本, 标签版本变化后缓存失效
+type taggedEntry struct {
+	Value json.RawMessage  `json:"value"`
+	Tags  map[string]int64 `json:"tags,omitempty"`
+}
+
+// Cacheable 缓存方法的返回结果, keyFn根据请求生成缓存的key, ttl单位秒,
+// 通过Evict或者InvalidateTags使标签下的所有缓存失效
+//
+//	i.queryBook = cache.Cacheable[*QueryBookRequest, *BookSet](func(req *QueryBookRequest) string {
+//		return fmt.Sprintf("book.list.%d.%d", req.PageNumber, req.PageSize)
+//	}, 60, "book")(i.queryBook)
+func Cacheable[Req, Resp any](keyFn func(req Req) string, ttl int64, tags ...string) func(Method[Req, Resp]) Method[Req, Resp] {
+	return func(next Method[Req, Resp]) Method[Req, Resp] {
+		return func(ctx context.Context, req Req) (Resp, error) {
+			l := log.Sub(AppName)
+			key := keyFn(req)
+			c := C()
+
+			versions, err := tagVersions(ctx, c, tags...)
+			if err != nil {
+				l.Warn().Msgf("get cache tag versions error, %s", err)
+				return next(ctx, req)
+			}
+
+			entry := &taggedEntry{}
+			err = c.Get(ctx, key, entry)
+			switch {
+			case err == nil && sameVersions(entry.Tags, versions):
+				resp, err := unmarshalTo[Resp](JSON, entry.Value)
+				if err == nil {
+					return resp, nil
+				}
+				l.Warn().Msgf("decode cached %s error, %s", key, err)
+			case err != nil && err != ErrKeyNotFound:
+				l.Warn().Msgf("get cache %s error, %s", key, err)
+			}
+
+			resp, err := next(ctx, req)
+			if err != nil {
+				return resp, err
+			}
+
+			data, err := json.Marshal(resp)
+			if err != nil {
+				l.Warn().Msgf("encode %s error, %s", key, err)
+				return resp, nil
+			}
+			entry = &taggedEntry{Value: data, Tags: versions}
+			if err := c.Set(ctx, key, entry, WithExpiration(ttl)); err != nil {
+				l.Warn().Msgf("set cache %s error, %s", key, err)
+			}
+			return resp, nil
+		}
+	}
+}
+
+// Evict 方法执行成功后使标签下的所有缓存失效
+//
+//	i.updateBook = cache.Evict[*UpdateBookRequest, *Book]("book")(i.updateBook)
+func Evict[Req, Resp any](tags ...string) func(Method[Req, Resp]) Method[Req, Resp] {
+	return func(next Method[Req, Resp]) Method[Req, Resp] {
+		return func(ctx context.Context, req Req) (Resp, error) {
+			resp, err := next(ctx, req)
+			if err != nil {
+				return resp, err
+			}
+			if err := InvalidateTags(ctx, tags...); err != nil {
+				log.Sub(AppName).Warn().Msgf("invalidate cache tags %v error, %s", tags, err)
+			}
+			return resp, nil
+		}
+	}
+}
+
+// InvalidateTags 使标签下的所有缓存失效, 标签的版本号保存在缓存中, 多个实例共享,
+// 使用新生成的版本号而不是递增, 避免标签被淘汰后重新从相同的版本号开始
+func InvalidateTags(ctx context.Context, tags ...string) error {
+	c := C()
+	for _, tag := range tags {
+		if err := c.Set(ctx, TAG_KEY_PREFIX+tag, newTagVersion(), WithExpiration(0)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 标签不存在(没有失效过或者被淘汰)时写入新的版本号, 淘汰之前写入的缓存不会因为版本号相同而重新生效
+func tagVersions(ctx context.Context, c Cache, tags ...string) (map[string]int64, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	keys := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		keys = append(keys, TAG_KEY_PREFIX+tag)
+	}
+	values, err := c.MGet(ctx, keys...)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make(map[string]int64, len(tags))
+	for _, tag := range tags {
+		key := TAG_KEY_PREFIX + tag
+		data, ok := values[key]
+		if !ok {
+			v, err := seedTagVersion(ctx, c, key)
+			if err != nil {
+				return nil, err
+			}
+			versions[tag] = v
+			continue
+		}
+
+		var v int64
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		versions[tag] = v
+	}
+	return versions, nil
+}
+
+// 多个实例同时写入时使用先写入的版本号
+func seedTagVersion(ctx context.Context, c Cache, key string) (int64, error) {
+	v := newTagVersion()
+	ok, err := c.SetNX(ctx, key, v, WithExpiration(0))
+	if err != nil || ok {
+		return v, err
+	}
+	if err := c.Get(ctx, key, &v); err != nil {
+		return 0, err
+	}
+	return v, nil
+}
+
+func newTagVersion() int64 {
+	return time.Now().UnixNano()
+}
+
+func sameVersions(cached, current map[string]int64) bool {
+	if len(cached) != len(current) {
+		return false
+	}
+	for tag, v := range current {
+		if cached[tag] != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,82 @@
+package cache_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/infraboard/mcube/v2/ioc/config/cache"
+)
+
+type queryBookRequest struct {
+	PageNumber int
+}
+
+type bookService struct {
+	calls   int
+	version int64
+
+	queryBook  cache.Method[*queryBookRequest, []*book]
+	updateBook cache.Method[*book, *book]
+}
+
+func newBookService() *bookService {
+	s := &bookService{}
+	s.queryBook = cache.Cacheable[*queryBookRequest, []*book](func(req *queryBookRequest) string {
+		return fmt.Sprintf("annotation.book.list.%d", req.PageNumber)
+	}, 60, "annotation.book")(s.query)
+	s.updateBook = cache.Evict[*book, *book]("annotation.book")(s.update)
+	return s
+}
+
+func (s *bookService) query(ctx context.Context, req *queryBookRequest) ([]*book, error) {
+	s.calls++
+	return []*book{{Id: "1", Version: s.version}}, nil
+}
+
+func (s *bookService) update(ctx context.Context, req *book) (*book, error) {
+	s.version = req.Version
+	return req, nil
+}
+
+func TestCacheable(t *testing.T) {
+	s := newBookService()
+	for range 3 {
+		set, err := s.queryBook(ctx, &queryBookRequest{PageNumber: 1})
+		if err != nil || len(set) != 1 {
+			t.Fatalf("unexpected books %v, %v", set, err)
+		}
+	}
+	if s.calls != 1 {
+		t.Fatalf("expect query called once, got %d", s.calls)
+	}
+
+	if _, err := s.updateBook(ctx, &book{Id: "1", Version: 2}); err != nil {
+		t.Fatal(err)
+	}
+	set, err := s.queryBook(ctx, &queryBookRequest{PageNumber: 1})
+	if err != nil || set[0].Version != 2 {
+		t.Fatalf("expect cache evicted, got %v, %v", set, err)
+	}
+	if s.calls != 2 {
+		t.Fatalf("expect query called twice, got %d", s.calls)
+	}
+}
+
+func TestCacheableTagEvicted(t *testing.T) {
+	s := newBookService()
+	if _, err := s.queryBook(ctx, &queryBookRequest{PageNumber: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 标签版本被淘汰后, 之前写入的缓存不能重新生效
+	if err := cache.C().Del(ctx, cache.TAG_KEY_PREFIX+"annotation.book"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.queryBook(ctx, &queryBookRequest{PageNumber: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if s.calls != 2 {
+		t.Fatalf("expect query called twice, got %d", s.calls)
+	}
+}
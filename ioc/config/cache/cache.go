@@ -1,9 +1,14 @@
 package cache
 
 import (
+	"context"
+
 	"github.com/infraboard/mcube/v2/ioc"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog"
 
+	"github.com/infraboard/mcube/v2/ioc/config/application"
+	"github.com/infraboard/mcube/v2/ioc/config/bus"
 	"github.com/infraboard/mcube/v2/ioc/config/gocache"
 	"github.com/infraboard/mcube/v2/ioc/config/log"
 	ioc_redis "github.com/infraboard/mcube/v2/ioc/config/redis"
@@ -14,8 +19,12 @@ func init() {
 }
 
 var defaultConfig = &cache{
-	PROVIDER: PROVIDER_GO_CACHE,
-	TTL:      300,
+	PROVIDER:            PROVIDER_GO_CACHE,
+	TTL:                 300,
+	L1Size:              1000,
+	L1TTL:               60,
+	InvalidationSubject: DEFAULT_INVALIDATION_SUBJECT,
+	Metric:              true,
 }
 
 // Config 配置选项
@@ -25,7 +34,17 @@ type cache struct {
 	// 单位秒, 默认5分钟
 	TTL int64 `json:"ttl" yaml:"ttl" toml:"ttl" env:"TTL"`
 
-	c Cache
+	// 多级缓存(multi_level)的本地缓存个数
+	L1Size int `json:"l1_size" yaml:"l1_size" toml:"l1_size" env:"L1_SIZE"`
+	// 多级缓存的本地缓存过期时间, 单位秒, 默认1分钟, Redis使用TTL
+	L1TTL int64 `json:"l1_ttl" yaml:"l1_ttl" toml:"l1_ttl" env:"L1_TTL"`
+	// 多级缓存通过Bus广播本地缓存失效的主题
+	InvalidationSubject string `json:"invalidation_subject" yaml:"invalidation_subject" toml:"invalidation_subject" env:"INVALIDATION_SUBJECT"`
+	// 多级缓存采集每一级的命中指标
+	Metric bool `json:"metric" yaml:"metric" toml:"metric" env:"METRIC"`
+
+	c   Cache
+	sub bus.Subscription
 	ioc.ObjectImpl
 	l *zerolog.Logger
 }
@@ -43,6 +62,8 @@ func (m *cache) Init() error {
 
 	m.l.Debug().Msgf("Cache TTL: %d Seconds", m.TTL)
 	switch m.PROVIDER {
+	case PROVIDER_MULTI_LEVEL:
+		m.c = m.newMultiLevelCache()
 	case PROVIDER_REDIS:
 		m.c = &redisCache{
 			redis: ioc_redis.Client(),
@@ -56,3 +77,37 @@ func (m *cache) Init() error {
 	}
 	return nil
 }
+
+func (m *cache) newMultiLevelCache() *MultiLevelCache {
+	c := NewMultiLevelCache(NewRedisCache(ioc_redis.Client(), m.TTL), m.L1Size, m.L1TTL).
+		SetLogger(m.l)
+
+	if m.Metric {
+		collector := NewMetricCollector(application.Get().GetAppName())
+		if err := prometheus.Register(collector); err != nil {
+			m.l.Warn().Msgf("register cache metric error, %s", err)
+		} else {
+			c.SetMetric(collector)
+		}
+	}
+
+	// 未开启Bus时, 其他实例的本地缓存只能等待L1 TTL过期
+	if b, ok := ioc.Config().Get(bus.APP_NAME).(bus.Service); ok {
+		sub, err := c.StartInvalidation(context.Background(), b, m.InvalidationSubject)
+		if err != nil {
+			m.l.Error().Msgf("subscribe cache invalidation error, %s", err)
+		}
+		m.sub = sub
+	} else {
+		m.l.Warn().Msgf("bus not enabled, l1 cache will not be invalidated across instances")
+	}
+	return c
+}
+
+func (m *cache) Close(ctx context.Context) {
+	if m.sub != nil {
+		if err := m.sub.Drain(ctx); err != nil {
+			m.l.Warn().Msgf("drain cache invalidation subscription error, %s", err)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+// cachetest 缓存实现的一致性测试, 保证不同的缓存实现有相同的行为
+package cachetest
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/infraboard/mcube/v2/ioc/config/cache"
+)
+
+// Suite 一致性测试
+//
+//	cachetest.Suite{
+//		New:     func() cache.Cache { return cache.NewGoCache(gcache.New(100).Build(), 300) },
+//		Advance: time.Sleep,
+//	}.Run(t)
+type Suite struct {
+	// 每个用例创建一个空的缓存
+	New func() cache.Cache
+	// 让时间前进d, 用于测试过期, 真实环境为time.Sleep, miniredis为FastForward
+	Advance func(d time.Duration)
+}
+
+func (s Suite) Run(t *testing.T) {
+	cases := []struct {
+		name string
+		fn   func(t *testing.T, ctx context.Context, c cache.Cache)
+	}{
+		{"GetSet", s.testGetSet},
+		{"Bytes", s.testBytes},
+		{"Exist", s.testExist},
+		{"Del", s.testDel},
+		{"IncrBy", s.testIncrBy},
+		{"MGetMSet", s.testMGetMSet},
+		{"SetNX", s.testSetNX},
+		{"TTL", s.testTTL},
+		{"Expire", s.testExpire},
+		{"Expiration", s.testExpiration},
+		{"Keys", s.testKeys},
+		{"Scan", s.testScan},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.fn(t, context.Background(), s.New())
+		})
+	}
+}
+
+func (s Suite) testGetSet(t *testing.T, ctx context.Context, c cache.Cache) {
+	var v map[string]string
+	if err := c.Get(ctx, "missing", &v); err != cache.ErrKeyNotFound {
+		t.Fatalf("expect ErrKeyNotFound, got %v", err)
+	}
+	must(t, c.Set(ctx, "k", map[string]string{"a": "b"}))
+	must(t, c.Get(ctx, "k", &v))
+	if v["a"] != "b" {
+		t.Fatalf("unexpected value %v", v)
+	}
+}
+
+func (s Suite) testBytes(t *testing.T, ctx context.Context, c cache.Cache) {
+	if _, err := c.GetBytes(ctx, "missing"); err != cache.ErrKeyNotFound {
+		t.Fatalf("expect ErrKeyNotFound, got %v", err)
+	}
+	must(t, c.SetBytes(ctx, "k", []byte{0, 1, 2}))
+	data, err := c.GetBytes(ctx, "k")
+	must(t, err)
+	if string(data) != string([]byte{0, 1, 2}) {
+		t.Fatalf("unexpected data %v", data)
+	}
+
+	// Set写入的数据为JSON
+	must(t, c.Set(ctx, "json", "v"))
+	data, err = c.GetBytes(ctx, "json")
+	must(t, err)
+	if string(data) != `"v"` {
+		t.Fatalf("unexpected data %s", data)
+	}
+}
+
+func (s Suite) testExist(t *testing.T, ctx context.Context, c cache.Cache) {
+	if err := c.Exist(ctx, "k"); err != cache.ErrKeyNotFound {
+		t.Fatalf("expect ErrKeyNotFound, got %v", err)
+	}
+	must(t, c.Set(ctx, "k", 1))
+	must(t, c.Exist(ctx, "k"))
+}
+
+func (s Suite) testDel(t *testing.T, ctx context.Context, c cache.Cache) {
+	must(t, c.Set(ctx, "k1", 1))
+	must(t, c.Set(ctx, "k2", 2))
+	must(t, c.Del(ctx, "k1", "k2", "missing"))
+	must(t, c.Del(ctx))
+	if err := c.Exist(ctx, "k1"); err != cache.ErrKeyNotFound {
+		t.Fatalf("expect ErrKeyNotFound, got %v", err)
+	}
+}
+
+func (s Suite) testIncrBy(t *testing.T, ctx context.Context, c cache.Cache) {
+	v, err := c.IncrBy(ctx, "counter", 2)
+	must(t, err)
+	if v != 2 {
+		t.Fatalf("expect 2, got %d", v)
+	}
+	v, err = c.IncrBy(ctx, "counter", -3)
+	must(t, err)
+	if v != -1 {
+		t.Fatalf("expect -1, got %d", v)
+	}
+	// 新建的计数器永不过期
+	ttl, err := c.TTL(ctx, "counter")
+	must(t, err)
+	if ttl != cache.NO_EXPIRATION {
+		t.Fatalf("expect no expiration, got %s", ttl)
+	}
+
+	// 已有的过期时间保持不变
+	must(t, c.Expire(ctx, "counter", 100))
+	_, err = c.IncrBy(ctx, "counter", 1)
+	must(t, err)
+	ttl, err = c.TTL(ctx, "counter")
+	must(t, err)
+	if ttl <= 0 || ttl > 100*time.Second {
+		t.Fatalf("expect ttl kept, got %s", ttl)
+	}
+
+	var n int64
+	must(t, c.Get(ctx, "counter", &n))
+	if n != 0 {
+		t.Fatalf("expect 0, got %d", n)
+	}
+}
+
+func (s Suite) testMGetMSet(t *testing.T, ctx context.Context, c cache.Cache) {
+	must(t, c.MSet(ctx, map[string]any{"k1": "v1", "k2": 2}, cache.WithExpiration(100)))
+	values, err := c.MGet(ctx, "k1", "k2", "missing")
+	must(t, err)
+	if len(values) != 2 || string(values["k1"]) != `"v1"` || string(values["k2"]) != "2" {
+		t.Fatalf("unexpected values %v", values)
+	}
+	ttl, err := c.TTL(ctx, "k2")
+	must(t, err)
+	if ttl <= 0 || ttl > 100*time.Second {
+		t.Fatalf("unexpected ttl %s", ttl)
+	}
+
+	values, err = c.MGet(ctx)
+	must(t, err)
+	if len(values) != 0 {
+		t.Fatalf("unexpected values %v", values)
+	}
+}
+
+func (s Suite) testSetNX(t *testing.T, ctx context.Context, c cache.Cache) {
+	ok, err := c.SetNX(ctx, "k", "v1")
+	must(t, err)
+	if !ok {
+		t.Fatal("expect set")
+	}
+	ok, err = c.SetNX(ctx, "k", "v2")
+	must(t, err)
+	if ok {
+		t.Fatal("expect not set")
+	}
+	var v string
+	must(t, c.Get(ctx, "k", &v))
+	if v != "v1" {
+		t.Fatalf("expect v1, got %s", v)
+	}
+}
+
+func (s Suite) testTTL(t *testing.T, ctx context.Context, c cache.Cache) {
+	if _, err := c.TTL(ctx, "missing"); err != cache.ErrKeyNotFound {
+		t.Fatalf("expect ErrKeyNotFound, got %v", err)
+	}
+	must(t, c.Set(ctx, "k", 1, cache.WithExpiration(100)))
+	ttl, err := c.TTL(ctx, "k")
+	must(t, err)
+	if ttl <= 90*time.Second || ttl > 100*time.Second {
+		t.Fatalf("unexpected ttl %s", ttl)
+	}
+	must(t, c.Set(ctx, "forever", 1, cache.WithExpiration(0)))
+	ttl, err = c.TTL(ctx, "forever")
+	must(t, err)
+	if ttl != cache.NO_EXPIRATION {
+		t.Fatalf("expect no expiration, got %s", ttl)
+	}
+}
+
+func (s Suite) testExpire(t *testing.T, ctx context.Context, c cache.Cache) {
+	if err := c.Expire(ctx, "missing", 10); err != cache.ErrKeyNotFound {
+		t.Fatalf("expect ErrKeyNotFound, got %v", err)
+	}
+	must(t, c.Set(ctx, "k", 1, cache.WithExpiration(0)))
+	must(t, c.Expire(ctx, "k", 10))
+	ttl, err := c.TTL(ctx, "k")
+	must(t, err)
+	if ttl <= 0 || ttl > 10*time.Second {
+		t.Fatalf("unexpected ttl %s", ttl)
+	}
+
+	must(t, c.Expire(ctx, "k", 0))
+	ttl, err = c.TTL(ctx, "k")
+	must(t, err)
+	if ttl != cache.NO_EXPIRATION {
+		t.Fatalf("expect no expiration, got %s", ttl)
+	}
+	// 重复设置永不过期
+	must(t, c.Expire(ctx, "k", 0))
+}
+
+func (s Suite) testExpiration(t *testing.T, ctx context.Context, c cache.Cache) {
+	must(t, c.Set(ctx, "k", 1, cache.WithExpiration(1)))
+	s.Advance(1100 * time.Millisecond)
+	if err := c.Exist(ctx, "k"); err != cache.ErrKeyNotFound {
+		t.Fatalf("expect expired, got %v", err)
+	}
+	keys, err := c.Keys(ctx, "k")
+	must(t, err)
+	if len(keys) != 0 {
+		t.Fatalf("expect expired keys removed, got %v", keys)
+	}
+}
+
+func (s Suite) testKeys(t *testing.T, ctx context.Context, c cache.Cache) {
+	for _, key := range []string{"ns.book.1", "ns.book.2", "ns.user.1", "ns*book"} {
+		must(t, c.Set(ctx, key, 1))
+	}
+	keys, err := c.Keys(ctx, "ns.book.")
+	must(t, err)
+	slices.Sort(keys)
+	if !slices.Equal(keys, []string{"ns.book.1", "ns.book.2"}) {
+		t.Fatalf("unexpected keys %v", keys)
+	}
+
+	// 前缀中的通配符按字面匹配
+	keys, err = c.Keys(ctx, "ns*")
+	must(t, err)
+	if !slices.Equal(keys, []string{"ns*book"}) {
+		t.Fatalf("unexpected keys %v", keys)
+	}
+}
+
+func (s Suite) testScan(t *testing.T, ctx context.Context, c cache.Cache) {
+	for _, key := range []string{"a.1", "a.2", "a.3", "b.1"} {
+		must(t, c.Set(ctx, key, 1))
+	}
+	count := 0
+	must(t, c.Scan(ctx, "a.", func(key string) bool {
+		count++
+		return count < 2
+	}))
+	if count != 2 {
+		t.Fatalf("expect scan stopped at 2, got %d", count)
+	}
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
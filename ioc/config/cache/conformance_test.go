@@ -0,0 +1,44 @@
+package cache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/bluele/gcache"
+	"github.com/infraboard/mcube/v2/ioc/config/cache"
+	"github.com/infraboard/mcube/v2/ioc/config/cache/cachetest"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestGoCacheConformance(t *testing.T) {
+	cachetest.Suite{
+		New: func() cache.Cache {
+			return cache.NewGoCache(gcache.New(100).LRU().Build(), 300)
+		},
+		Advance: time.Sleep,
+	}.Run(t)
+}
+
+func TestRedisConformance(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	cachetest.Suite{
+		New: func() cache.Cache {
+			mr.FlushAll()
+			return cache.NewRedisCache(client, 300)
+		},
+		Advance: mr.FastForward,
+	}.Run(t)
+}
+
+func TestMultiLevelConformance(t *testing.T) {
+	cachetest.Suite{
+		New: func() cache.Cache {
+			return cache.NewMultiLevelCache(cache.NewGoCache(gcache.New(100).LRU().Build(), 300), 100, 60)
+		},
+		Advance: time.Sleep,
+	}.Run(t)
+}
@@ -2,22 +2,35 @@ package cache
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"math/rand/v2"
 	"reflect"
 	"strings"
+	"time"
 
+	"github.com/infraboard/mcube/v2/exception"
 	"github.com/infraboard/mcube/v2/ioc/config/log"
 	"github.com/rs/zerolog"
+	"golang.org/x/sync/singleflight"
+)
+
+var (
+	// 同一个key并发未命中时只调用一次ObjectFinder
+	loadGroup singleflight.Group
 )
 
 type ObjectFinder func(ctx context.Context, objectId string) (any, error)
 
 func NewGetter(ctx context.Context, f ObjectFinder) *Getter {
 	return &Getter{
-		ctx: ctx,
-		f:   f,
-		ttl: Get().TTL,
-		l:   log.Sub("cache"),
+		ctx:        ctx,
+		f:          f,
+		ttl:        Get().TTL,
+		isNotFound: exception.IsNotFoundError,
+		l:          log.Sub("cache"),
 	}
 }
 
@@ -28,6 +41,32 @@ type Getter struct {
 	namespace    string
 	resourceType string
 	l            *zerolog.Logger
+
+	// 过期后继续使用旧值的时间, 单位秒
+	staleTTL int64
+	// 对象不存在时的缓存时间, 单位秒
+	notFoundTTL int64
+	isNotFound  func(error) bool
+	// 提前刷新系数, 0表示不提前刷新
+	beta float64
+}
+
+// 缓存中保存的对象, 除了对象本身还记录了逻辑过期时间与加载耗时
+type cacheEntry struct {
+	Value json.RawMessage `json:"value,omitempty"`
+	// 对象不存在时缓存的异常
+	NotFound *exception.ApiException `json:"not_found,omitempty"`
+	// 逻辑过期时间, unix毫秒
+	ExpiredAt int64 `json:"expired_at"`
+	// ObjectFinder的耗时, 单位毫秒
+	Delta int64 `json:"delta"`
+}
+
+func (e *cacheEntry) decode(value any) error {
+	if e.NotFound != nil {
+		return e.NotFound
+	}
+	return json.Unmarshal(e.Value, value)
 }
 
 func (g *Getter) GetKey(key string) string {
@@ -57,41 +96,164 @@ func (g *Getter) WithResourceType(resourceType string) *Getter {
 	return g
 }
 
+// WithStaleTTL 对象过期后的staleTTL秒内仍然返回旧值, 同时由一个协程在后台刷新
+func (g *Getter) WithStaleTTL(staleTTL int64) *Getter {
+	g.staleTTL = staleTTL
+	return g
+}
+
+// WithNotFoundTTL 缓存对象不存在的结果, 避免不存在的对象每次都穿透到ObjectFinder
+func (g *Getter) WithNotFoundTTL(ttl int64) *Getter {
+	g.notFoundTTL = ttl
+	return g
+}
+
+// WithNotFoundChecker 判断ObjectFinder返回的错误是否表示对象不存在, 默认使用exception.IsNotFoundError
+func (g *Getter) WithNotFoundChecker(fn func(error) bool) *Getter {
+	g.isNotFound = fn
+	return g
+}
+
+// WithEarlyRefresh 在过期前按概率提前刷新(XFetch), 越接近过期、加载越慢的对象越早刷新,
+// beta一般为1, 大于1时更倾向于提前刷新
+func (g *Getter) WithEarlyRefresh(beta float64) *Getter {
+	g.beta = beta
+	return g
+}
+
 func (g *Getter) Get(key string, value any) error {
-	err := C().Get(g.ctx, g.GetKey(key), value)
-	if err == nil {
-		g.l.Info().Msgf("get object %s from cache", g.GetKey(key))
-		return nil
+	if reflect.TypeOf(value).Kind() != reflect.Ptr {
+		return fmt.Errorf("value must be ptr")
 	}
 
-	if err == ErrKeyNotFound {
-		goto DO_CACHE
+	entry := &cacheEntry{}
+	err := C().Get(g.ctx, g.GetKey(key), entry)
+	if err != nil && err != ErrKeyNotFound {
+		return err
 	}
 
-	return err
+	if err == nil && entry.ExpiredAt > 0 {
+		now := time.Now()
+		switch {
+		case now.UnixMilli() < entry.ExpiredAt:
+			if g.shouldEarlyRefresh(entry, now) {
+				g.refresh(key)
+			}
+			g.l.Debug().Msgf("get object %s from cache", g.GetKey(key))
+			return entry.decode(value)
+		case g.staleTTL > 0:
+			g.refresh(key)
+			g.l.Debug().Msgf("get stale object %s from cache", g.GetKey(key))
+			return entry.decode(value)
+		}
+	}
 
-DO_CACHE:
-	v, err := g.f(g.ctx, key)
+	v, err := g.load(g.ctx, key)
 	if err != nil {
 		return err
 	}
+	return setValue(value, v)
+}
+
+// 通过ObjectFinder加载对象并写入缓存, 同一个key同时只有一个加载,
+// 加载由多个调用方共享, 不受第一个调用方ctx取消的影响, 调用方ctx取消时只停止等待
+func (g *Getter) load(ctx context.Context, key string) (any, error) {
+	cacheKey := g.GetKey(key)
+	loadCtx := context.WithoutCancel(ctx)
+	ch := loadGroup.DoChan(cacheKey, func() (any, error) {
+		start := time.Now()
+		v, err := g.f(loadCtx, key)
+		delta := time.Since(start).Milliseconds()
+		if err != nil {
+			if g.notFoundTTL > 0 && g.isNotFound != nil && g.isNotFound(err) {
+				g.set(loadCtx, cacheKey, &cacheEntry{NotFound: toNotFound(err), Delta: delta}, g.notFoundTTL)
+			}
+			return nil, err
+		}
+
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		g.set(loadCtx, cacheKey, &cacheEntry{Value: data, Delta: delta}, g.ttl)
+		return v, nil
+	})
+
+	select {
+	case r := <-ch:
+		return r.Val, r.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
 
-	if err := C().Set(g.ctx, g.GetKey(key), v, WithExpiration(g.ttl)); err != nil {
+func (g *Getter) set(ctx context.Context, cacheKey string, entry *cacheEntry, ttl int64) {
+	entry.ExpiredAt = time.Now().Add(time.Duration(ttl) * time.Second).UnixMilli()
+	// 缓存中保留的时间需要包含stale窗口
+	if err := C().Set(ctx, cacheKey, entry, WithExpiration(ttl+g.staleTTL)); err != nil {
 		g.l.Warn().Msgf("set cache error, %s", err)
 	} else {
-		g.l.Info().Msgf("set cache object %s ttl: %d second", g.GetKey(key), g.ttl)
+		g.l.Info().Msgf("set cache object %s ttl: %d second", cacheKey, ttl)
 	}
+}
 
-	//
-	if reflect.TypeOf(value).Kind() == reflect.Ptr {
-		if reflect.TypeOf(v).Kind() == reflect.Ptr {
-			reflect.ValueOf(value).Elem().Set(reflect.ValueOf(v).Elem())
-		} else {
-			reflect.ValueOf(value).Elem().Set(reflect.ValueOf(v))
+// 后台刷新, 不受调用方ctx取消的影响
+func (g *Getter) refresh(key string) {
+	ctx := context.WithoutCancel(g.ctx)
+	go func() {
+		if _, err := g.load(ctx, key); err != nil {
+			g.l.Warn().Msgf("refresh cache object %s error, %s", g.GetKey(key), err)
 		}
-	} else {
-		return fmt.Errorf("value must be ptr")
+	}()
+}
+
+// XFetch: now - delta * beta * ln(rand) >= expiry
+func (g *Getter) shouldEarlyRefresh(entry *cacheEntry, now time.Time) bool {
+	if g.beta <= 0 || entry.Delta <= 0 {
+		return false
 	}
+	gap := -float64(entry.Delta) * g.beta * math.Log(1-rand.Float64())
+	return float64(now.UnixMilli())+gap >= float64(entry.ExpiredAt)
+}
+
+func toNotFound(err error) *exception.ApiException {
+	var apiErr *exception.ApiException
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	return exception.NewNotFound("%s", err)
+}
 
+func setValue(value, v any) error {
+	if v == nil {
+		return nil
+	}
+	if reflect.TypeOf(v).Kind() == reflect.Ptr {
+		reflect.ValueOf(value).Elem().Set(reflect.ValueOf(v).Elem())
+	} else {
+		reflect.ValueOf(value).Elem().Set(reflect.ValueOf(v))
+	}
 	return nil
 }
+
+// Del 删除对象的缓存
+func (g *Getter) Del(keys ...string) error {
+	cacheKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		cacheKeys = append(cacheKeys, g.GetKey(key))
+	}
+	return C().Del(g.ctx, cacheKeys...)
+}
+
+// Purge 删除namespace与resourceType下所有对象的缓存
+func (g *Getter) Purge() error {
+	if g.namespace == "" && g.resourceType == "" {
+		return fmt.Errorf("namespace or resource type required")
+	}
+
+	keys, err := C().Keys(g.ctx, g.GetKey(""))
+	if err != nil {
+		return err
+	}
+	return C().Del(g.ctx, keys...)
+}
@@ -0,0 +1,140 @@
+package cache_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/infraboard/mcube/v2/exception"
+	"github.com/infraboard/mcube/v2/ioc/config/cache"
+)
+
+type book struct {
+	Id      string `json:"id"`
+	Version int64  `json:"version"`
+}
+
+func TestGetterSingleflight(t *testing.T) {
+	var calls int64
+	finder := func(ctx context.Context, id string) (any, error) {
+		atomic.AddInt64(&calls, 1)
+		time.Sleep(50 * time.Millisecond)
+		return &book{Id: id}, nil
+	}
+
+	wg := sync.WaitGroup{}
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b := &book{}
+			if err := cache.NewGetter(ctx, finder).WithResourceType("singleflight").Get("1", b); err != nil || b.Id != "1" {
+				t.Errorf("unexpected book %v, %v", b, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Fatalf("expect finder called once, got %d", calls)
+	}
+}
+
+func TestGetterSingleflightCancel(t *testing.T) {
+	started := make(chan struct{})
+	finder := func(ctx context.Context, id string) (any, error) {
+		close(started)
+		select {
+		case <-time.After(100 * time.Millisecond):
+			return &book{Id: id}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	// 第一个调用方取消后, 共享加载的其他调用方不受影响
+	first, cancel := context.WithCancel(ctx)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- cache.NewGetter(first, finder).WithResourceType("singleflight_cancel").Get("1", &book{})
+	}()
+	<-started
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	b := &book{}
+	if err := cache.NewGetter(ctx, finder).WithResourceType("singleflight_cancel").Get("1", b); err != nil || b.Id != "1" {
+		t.Fatalf("unexpected book %v, %v", b, err)
+	}
+	if err := <-errCh; err != context.Canceled {
+		t.Fatalf("expect first caller canceled, got %v", err)
+	}
+}
+
+func TestGetterNotFound(t *testing.T) {
+	var calls int64
+	finder := func(ctx context.Context, id string) (any, error) {
+		atomic.AddInt64(&calls, 1)
+		return nil, exception.NewNotFound("book %s not found", id)
+	}
+
+	for range 3 {
+		err := cache.NewGetter(ctx, finder).WithResourceType("not_found").WithNotFoundTTL(10).Get("1", &book{})
+		if !exception.IsNotFoundError(err) {
+			t.Fatalf("expect not found, got %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("expect finder called once, got %d", calls)
+	}
+}
+
+func TestGetterStale(t *testing.T) {
+	var version int64
+	finder := func(ctx context.Context, id string) (any, error) {
+		return &book{Id: id, Version: atomic.AddInt64(&version, 1)}, nil
+	}
+	getter := func() *cache.Getter {
+		return cache.NewGetter(ctx, finder).WithResourceType("stale").WithTTL(1).WithStaleTTL(10)
+	}
+
+	b := &book{}
+	if err := getter().Get("1", b); err != nil || b.Version != 1 {
+		t.Fatalf("unexpected book %v, %v", b, err)
+	}
+
+	// 过期后返回旧值, 后台刷新
+	time.Sleep(1100 * time.Millisecond)
+	if err := getter().Get("1", b); err != nil || b.Version != 1 {
+		t.Fatalf("expect stale book, got %v, %v", b, err)
+	}
+	for range 100 {
+		if err := getter().Get("1", b); err == nil && b.Version > 1 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("stale object not refreshed, got %v", b)
+}
+
+func TestGetterPurge(t *testing.T) {
+	finder := func(ctx context.Context, id string) (any, error) {
+		return &book{Id: id}, nil
+	}
+	getter := cache.NewGetter(ctx, finder).WithNamespace("purge").WithResourceType("book")
+	for _, id := range []string{"1", "2"} {
+		if err := getter.Get(id, &book{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := getter.Purge(); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := cache.C().Keys(ctx, "purge.")
+	if err != nil || len(keys) != 0 {
+		t.Fatalf("expect keys purged, got %v, %v", keys, err)
+	}
+}
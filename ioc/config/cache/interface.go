@@ -18,6 +18,8 @@ type PROVIDER string
 const (
 	PROVIDER_GO_CACHE = gocache.AppName
 	PROVIDER_REDIS    = redis.AppName
+	// 本地缓存 + Redis的多级缓存
+	PROVIDER_MULTI_LEVEL = "multi_level"
 )
 
 func C() Cache {
@@ -32,12 +34,34 @@ func Get() *cache {
 	return obj.(*cache)
 }
 
+// NO_EXPIRATION key没有设置过期时间时TTL的返回值
+const NO_EXPIRATION time.Duration = -1
+
 type Cache interface {
 	Set(ctx context.Context, key string, value any, options ...SetOption) error
 	IncrBy(ctx context.Context, key string, value int64) (int64, error)
 	Get(ctx context.Context, key string, value any) error
+	// key不存在时返回ErrKeyNotFound
 	Exist(ctx context.Context, key string) error
 	Del(ctx context.Context, keys ...string) error
+
+	// 直接读写序列化后的数据, Set写入的数据为JSON格式
+	SetBytes(ctx context.Context, key string, data []byte, options ...SetOption) error
+	GetBytes(ctx context.Context, key string) ([]byte, error)
+	// 批量读取, 返回存在的key与对应序列化后的数据
+	MGet(ctx context.Context, keys ...string) (map[string][]byte, error)
+	// 批量写入, 所有key使用相同的过期时间
+	MSet(ctx context.Context, values map[string]any, options ...SetOption) error
+	// key不存在时写入, 返回是否写入成功
+	SetNX(ctx context.Context, key string, value any, options ...SetOption) (bool, error)
+	// 剩余过期时间, 没有过期时间返回NO_EXPIRATION, key不存在返回ErrKeyNotFound
+	TTL(ctx context.Context, key string) (time.Duration, error)
+	// 重新设置过期时间, 单位秒, 小于等于0时永不过期, key不存在返回ErrKeyNotFound
+	Expire(ctx context.Context, key string, expiration int64) error
+	// 以prefix开头的所有key
+	Keys(ctx context.Context, prefix string) ([]string, error)
+	// 遍历以prefix开头的key, fn返回false时停止遍历
+	Scan(ctx context.Context, prefix string, fn func(key string) bool) error
 }
 
 func WithExpiration(expiration int64) SetOption {
@@ -57,7 +81,7 @@ func newOptions(defaultTTL int64, opts ...SetOption) *options {
 }
 
 type options struct {
-	// 过期时间, 单位秒
+	// 过期时间, 单位秒, 小于等于0时永不过期
 	expiration int64
 }
 
@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func NewMetricCollector(appName string) *MetricCollector {
+	labels := map[string]string{"app": appName}
+	return &MetricCollector{
+		HitTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "cache_hit_total",
+				Help:        "Total number of cache hits",
+				ConstLabels: labels,
+			},
+			[]string{"tier"},
+		),
+		MissTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "cache_miss_total",
+				Help:        "Total number of cache misses",
+				ConstLabels: labels,
+			},
+			[]string{"tier"},
+		),
+	}
+}
+
+// MetricCollector 缓存命中指标, tier为缓存层级(l1, l2)
+type MetricCollector struct {
+	HitTotal  *prometheus.CounterVec
+	MissTotal *prometheus.CounterVec
+}
+
+func (c *MetricCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.HitTotal.Describe(ch)
+	c.MissTotal.Describe(ch)
+}
+
+func (c *MetricCollector) Collect(ch chan<- prometheus.Metric) {
+	c.HitTotal.Collect(ch)
+	c.MissTotal.Collect(ch)
+}
@@ -0,0 +1,283 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/bluele/gcache"
+	"github.com/infraboard/mcube/v2/ioc/config/bus"
+	"github.com/rs/xid"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// 多级缓存的层级
+	TIER_L1 = "l1"
+	TIER_L2 = "l2"
+
+	// 默认的失效广播主题
+	DEFAULT_INVALIDATION_SUBJECT = "mcube.cache.invalidation"
+)
+
+// NewMultiLevelCache 多级缓存, 本地gcache作为L1, l2一般为Redis, 读取时先读L1, 未命中再读L2并回填L1,
+// 写入和删除时同时操作两级缓存, 并通过Bus广播让其他实例失效本地的L1
+func NewMultiLevelCache(l2 Cache, l1Size int, l1TTL int64) *MultiLevelCache {
+	hostname, _ := os.Hostname()
+	nop := zerolog.Nop()
+	return &MultiLevelCache{
+		l1:     gcache.New(l1Size).LRU().Build(),
+		l1TTL:  l1TTL,
+		l2:     l2,
+		nodeId: hostname + "-" + xid.New().String(),
+		log:    &nop,
+	}
+}
+
+type MultiLevelCache struct {
+	l1     gcache.Cache
+	l1TTL  int64
+	l2     Cache
+	nodeId string
+
+	bus     bus.Publisher
+	subject string
+	metric  *MetricCollector
+	log     *zerolog.Logger
+}
+
+// 失效广播的消息内容
+type invalidation struct {
+	// 发送广播的节点, 节点忽略自己发出的广播
+	Node string   `json:"node"`
+	Keys []string `json:"keys"`
+}
+
+func (c *MultiLevelCache) SetLogger(l *zerolog.Logger) *MultiLevelCache {
+	c.log = l
+	return c
+}
+
+// SetMetric 设置指标采集器, 统计每一级缓存的命中与未命中次数
+func (c *MultiLevelCache) SetMetric(m *MetricCollector) *MultiLevelCache {
+	c.metric = m
+	return c
+}
+
+// StartInvalidation 订阅失效广播, 并在Set/Del/IncrBy时向其他实例发送广播,
+// ctx取消或者取消返回的订阅后停止接收广播
+func (c *MultiLevelCache) StartInvalidation(ctx context.Context, b bus.Service, subject string) (bus.Subscription, error) {
+	if subject == "" {
+		subject = DEFAULT_INVALIDATION_SUBJECT
+	}
+	c.bus = b
+	c.subject = subject
+	return b.TopicSubscribe(ctx, subject, c.handleInvalidation)
+}
+
+// 无法解析的消息重新投递也不会成功, 记录日志后确认
+func (c *MultiLevelCache) handleInvalidation(e *bus.Event) error {
+	msg := &invalidation{}
+	if err := json.Unmarshal(e.Data, msg); err != nil {
+		c.log.Warn().Msgf("decode cache invalidation error, %s", err)
+		return nil
+	}
+	if msg.Node == c.nodeId {
+		return nil
+	}
+	for _, key := range msg.Keys {
+		c.l1.Remove(key)
+	}
+	c.log.Debug().Msgf("invalidate l1 keys %v from node %s", msg.Keys, msg.Node)
+	return nil
+}
+
+// 通知其他实例失效本地缓存, 广播失败时其他实例的L1会在L1 TTL后过期
+func (c *MultiLevelCache) broadcast(ctx context.Context, keys ...string) {
+	if c.bus == nil || len(keys) == 0 {
+		return
+	}
+	data, err := json.Marshal(&invalidation{Node: c.nodeId, Keys: keys})
+	if err != nil {
+		return
+	}
+	err = c.bus.Publish(ctx, &bus.Event{Subject: c.subject, Data: data})
+	if err != nil {
+		c.log.Warn().Msgf("broadcast cache invalidation error, %s", err)
+	}
+}
+
+func (c *MultiLevelCache) setL1(key string, data []byte, ttl time.Duration) {
+	l1TTL := time.Duration(c.l1TTL) * time.Second
+	if ttl > 0 && (l1TTL <= 0 || ttl < l1TTL) {
+		l1TTL = ttl
+	}
+	if l1TTL > 0 {
+		c.l1.SetWithExpire(key, data, l1TTL)
+	} else {
+		c.l1.Set(key, data)
+	}
+}
+
+func (c *MultiLevelCache) record(tier string, hit bool) {
+	if c.metric == nil {
+		return
+	}
+	if hit {
+		c.metric.HitTotal.WithLabelValues(tier).Inc()
+	} else {
+		c.metric.MissTotal.WithLabelValues(tier).Inc()
+	}
+}
+
+func (c *MultiLevelCache) Set(ctx context.Context, key string, value any, opts ...SetOption) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return c.SetBytes(ctx, key, data, opts...)
+}
+
+func (c *MultiLevelCache) SetBytes(ctx context.Context, key string, data []byte, opts ...SetOption) error {
+	if err := c.l2.SetBytes(ctx, key, data, opts...); err != nil {
+		return err
+	}
+	c.setL1(key, data, newOptions(0, opts...).GetTTL())
+	c.broadcast(ctx, key)
+	return nil
+}
+
+func (c *MultiLevelCache) IncrBy(ctx context.Context, key string, value int64) (int64, error) {
+	v, err := c.l2.IncrBy(ctx, key, value)
+	if err != nil {
+		return 0, err
+	}
+	// 计数器以L2为准, 只失效L1
+	c.l1.Remove(key)
+	c.broadcast(ctx, key)
+	return v, nil
+}
+
+func (c *MultiLevelCache) Get(ctx context.Context, key string, value any) error {
+	data, err := c.GetBytes(ctx, key)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, value)
+}
+
+func (c *MultiLevelCache) GetBytes(ctx context.Context, key string) ([]byte, error) {
+	if data, err := c.l1.Get(key); err == nil {
+		c.record(TIER_L1, true)
+		return data.([]byte), nil
+	}
+	c.record(TIER_L1, false)
+
+	data, err := c.l2.GetBytes(ctx, key)
+	if err != nil {
+		if err == ErrKeyNotFound {
+			c.record(TIER_L2, false)
+		}
+		return nil, err
+	}
+	c.record(TIER_L2, true)
+
+	// 回填L1
+	c.setL1(key, data, 0)
+	return data, nil
+}
+
+func (c *MultiLevelCache) MGet(ctx context.Context, keys ...string) (map[string][]byte, error) {
+	result := make(map[string][]byte, len(keys))
+	missed := []string{}
+	for _, key := range keys {
+		if data, err := c.l1.Get(key); err == nil {
+			c.record(TIER_L1, true)
+			result[key] = data.([]byte)
+			continue
+		}
+		c.record(TIER_L1, false)
+		missed = append(missed, key)
+	}
+	if len(missed) == 0 {
+		return result, nil
+	}
+
+	values, err := c.l2.MGet(ctx, missed...)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range missed {
+		data, ok := values[key]
+		c.record(TIER_L2, ok)
+		if ok {
+			result[key] = data
+			c.setL1(key, data, 0)
+		}
+	}
+	return result, nil
+}
+
+func (c *MultiLevelCache) MSet(ctx context.Context, values map[string]any, opts ...SetOption) error {
+	if err := c.l2.MSet(ctx, values, opts...); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		// 与L2保持一致, 只失效本地缓存, 下次读取时回填
+		c.l1.Remove(key)
+		keys = append(keys, key)
+	}
+	c.broadcast(ctx, keys...)
+	return nil
+}
+
+func (c *MultiLevelCache) SetNX(ctx context.Context, key string, value any, opts ...SetOption) (bool, error) {
+	ok, err := c.l2.SetNX(ctx, key, value, opts...)
+	if err != nil || !ok {
+		return ok, err
+	}
+	c.l1.Remove(key)
+	c.broadcast(ctx, key)
+	return true, nil
+}
+
+func (c *MultiLevelCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return c.l2.TTL(ctx, key)
+}
+
+func (c *MultiLevelCache) Expire(ctx context.Context, key string, expiration int64) error {
+	if err := c.l2.Expire(ctx, key, expiration); err != nil {
+		return err
+	}
+	c.l1.Remove(key)
+	c.broadcast(ctx, key)
+	return nil
+}
+
+func (c *MultiLevelCache) Keys(ctx context.Context, prefix string) ([]string, error) {
+	return c.l2.Keys(ctx, prefix)
+}
+
+func (c *MultiLevelCache) Scan(ctx context.Context, prefix string, fn func(key string) bool) error {
+	return c.l2.Scan(ctx, prefix, fn)
+}
+
+func (c *MultiLevelCache) Exist(ctx context.Context, key string) error {
+	if c.l1.Has(key) {
+		return nil
+	}
+	return c.l2.Exist(ctx, key)
+}
+
+func (c *MultiLevelCache) Del(ctx context.Context, keys ...string) error {
+	if err := c.l2.Del(ctx, keys...); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		c.l1.Remove(key)
+	}
+	c.broadcast(ctx, keys...)
+	return nil
+}
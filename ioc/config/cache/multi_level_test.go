@@ -0,0 +1,83 @@
+package cache_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/bluele/gcache"
+	"github.com/infraboard/mcube/v2/ioc/config/bus"
+	"github.com/infraboard/mcube/v2/ioc/config/cache"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// 进程内的广播总线, 模拟多个实例订阅同一个主题
+type memoryBus struct {
+	lock     sync.Mutex
+	handlers []bus.EventHandler
+	subs     bus.Subscriptions
+}
+
+func (b *memoryBus) Publish(ctx context.Context, e *bus.Event) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for _, h := range b.handlers {
+		h(e)
+	}
+	return nil
+}
+
+func (b *memoryBus) TopicSubscribe(ctx context.Context, subject string, cb bus.EventHandler, opts ...bus.SubscribeOption) (bus.Subscription, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.handlers = append(b.handlers, cb)
+	return b.subs.New(ctx)
+}
+
+func (b *memoryBus) QueueSubscribe(ctx context.Context, subject string, cb bus.EventHandler, opts ...bus.SubscribeOption) (bus.Subscription, error) {
+	return b.TopicSubscribe(ctx, subject, cb)
+}
+
+func TestMultiLevelCache(t *testing.T) {
+	// 两个实例共享同一个L2
+	l2 := cache.NewGoCache(gcache.New(100).Build(), 300)
+	b := &memoryBus{}
+	metric := cache.NewMetricCollector("test")
+	node1 := cache.NewMultiLevelCache(l2, 100, 60).SetMetric(metric)
+	node2 := cache.NewMultiLevelCache(l2, 100, 60)
+	if _, err := node1.StartInvalidation(ctx, b, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := node2.StartInvalidation(ctx, b, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := node1.Set(ctx, "book.1", "v1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// node2从L2读取并回填L1
+	var v string
+	if err := node2.Get(ctx, "book.1", &v); err != nil || v != "v1" {
+		t.Fatalf("expect v1, got %s, %v", v, err)
+	}
+
+	// node1更新后node2的L1被失效
+	if err := node1.Set(ctx, "book.1", "v2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := node2.Get(ctx, "book.1", &v); err != nil || v != "v2" {
+		t.Fatalf("expect v2, got %s, %v", v, err)
+	}
+
+	if err := node2.Del(ctx, "book.1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := node1.Get(ctx, "book.1", &v); err != cache.ErrKeyNotFound {
+		t.Fatalf("expect key not found, got %v", err)
+	}
+
+	if hit := testutil.ToFloat64(metric.MissTotal.WithLabelValues(cache.TIER_L2)); hit != 1 {
+		t.Fatalf("expect 1 l2 miss, got %v", hit)
+	}
+}
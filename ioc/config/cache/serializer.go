@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	JSON     Serializer = jsonSerializer{}
+	MsgPack  Serializer = msgpackSerializer{}
+	Protobuf Serializer = protobufSerializer{}
+)
+
+// Serializer TypedCache使用的序列化方式
+type Serializer interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+type jsonSerializer struct{}
+
+func (jsonSerializer) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonSerializer) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+type msgpackSerializer struct{}
+
+func (msgpackSerializer) Marshal(v any) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	if err := codec.NewEncoder(buf, new(codec.MsgpackHandle)).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (msgpackSerializer) Unmarshal(data []byte, v any) error {
+	return codec.NewDecoderBytes(data, new(codec.MsgpackHandle)).Decode(v)
+}
+
+// 对象需要实现proto.Message
+type protobufSerializer struct{}
+
+func (protobufSerializer) Marshal(v any) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("%T is not proto.Message", v)
+	}
+	return proto.Marshal(m)
+}
+
+func (protobufSerializer) Unmarshal(data []byte, v any) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("%T is not proto.Message", v)
+	}
+	return proto.Unmarshal(data, m)
+}
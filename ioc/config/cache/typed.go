@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"context"
+	"reflect"
+)
+
+// NewTypedCache 泛型缓存, 使用指定的序列化方式读写T, serializer为nil时使用JSON
+//
+//	books := cache.NewTypedCache[*Book](cache.C(), cache.Protobuf)
+//	books.Set(ctx, "book.1", book)
+//	book, err := books.Get(ctx, "book.1")
+func NewTypedCache[T any](c Cache, serializer Serializer) *TypedCache[T] {
+	if serializer == nil {
+		serializer = JSON
+	}
+	return &TypedCache[T]{c: c, s: serializer}
+}
+
+type TypedCache[T any] struct {
+	c Cache
+	s Serializer
+}
+
+// Cache 底层的缓存
+func (t *TypedCache[T]) Cache() Cache {
+	return t.c
+}
+
+func (t *TypedCache[T]) Set(ctx context.Context, key string, value T, opts ...SetOption) error {
+	data, err := t.s.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return t.c.SetBytes(ctx, key, data, opts...)
+}
+
+func (t *TypedCache[T]) Get(ctx context.Context, key string) (T, error) {
+	data, err := t.c.GetBytes(ctx, key)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return unmarshalTo[T](t.s, data)
+}
+
+// MGet 批量读取, 返回存在的key与对应的对象
+func (t *TypedCache[T]) MGet(ctx context.Context, keys ...string) (map[string]T, error) {
+	values, err := t.c.MGet(ctx, keys...)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]T, len(values))
+	for key, data := range values {
+		v, err := unmarshalTo[T](t.s, data)
+		if err != nil {
+			return nil, err
+		}
+		result[key] = v
+	}
+	return result, nil
+}
+
+// MSet 批量写入, 底层的MSet使用JSON序列化, 这里逐个写入以使用指定的序列化方式
+func (t *TypedCache[T]) MSet(ctx context.Context, values map[string]T, opts ...SetOption) error {
+	for key, value := range values {
+		if err := t.Set(ctx, key, value, opts...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *TypedCache[T]) Del(ctx context.Context, keys ...string) error {
+	return t.c.Del(ctx, keys...)
+}
+
+// T为指针时分配指向的对象, 否则解析到T的地址
+func unmarshalTo[T any](s Serializer, data []byte) (T, error) {
+	var v T
+	rt := reflect.TypeFor[T]()
+	if rt.Kind() == reflect.Ptr {
+		v = reflect.New(rt.Elem()).Interface().(T)
+		return v, s.Unmarshal(data, v)
+	}
+	return v, s.Unmarshal(data, &v)
+}
@@ -0,0 +1,45 @@
+package cache_test
+
+import (
+	"testing"
+
+	"github.com/bluele/gcache"
+	"github.com/infraboard/mcube/v2/ioc/config/cache"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestTypedCache(t *testing.T) {
+	c := cache.NewGoCache(gcache.New(100).Build(), 300)
+
+	for _, s := range []cache.Serializer{cache.JSON, cache.MsgPack} {
+		books := cache.NewTypedCache[*book](c, s)
+		if err := books.MSet(ctx, map[string]*book{"book.1": {Id: "1"}, "book.2": {Id: "2"}}); err != nil {
+			t.Fatal(err)
+		}
+		b, err := books.Get(ctx, "book.1")
+		if err != nil || b.Id != "1" {
+			t.Fatalf("unexpected book %v, %v", b, err)
+		}
+		items, err := books.MGet(ctx, "book.1", "book.2", "book.3")
+		if err != nil || len(items) != 2 || items["book.2"].Id != "2" {
+			t.Fatalf("unexpected books %v, %v", items, err)
+		}
+	}
+
+	pb := cache.NewTypedCache[*wrapperspb.StringValue](c, cache.Protobuf)
+	if err := pb.Set(ctx, "pb", wrapperspb.String("v")); err != nil {
+		t.Fatal(err)
+	}
+	v, err := pb.Get(ctx, "pb")
+	if err != nil || v.GetValue() != "v" {
+		t.Fatalf("unexpected value %v, %v", v, err)
+	}
+
+	counts := cache.NewTypedCache[int](c, nil)
+	if err := counts.Set(ctx, "count", 3); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := counts.Get(ctx, "count"); err != nil || n != 3 {
+		t.Fatalf("unexpected count %d, %v", n, err)
+	}
+}
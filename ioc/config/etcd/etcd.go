@@ -0,0 +1,72 @@
+package etcd
+
+import (
+	"context"
+	"time"
+
+	"github.com/infraboard/mcube/v2/ioc"
+	"github.com/infraboard/mcube/v2/ioc/config/log"
+	"github.com/rs/zerolog"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func init() {
+	ioc.Config().Registry(defaultConfig)
+}
+
+var defaultConfig = &Etcd{
+	Endpoints:   []string{"127.0.0.1:2379"},
+	DialTimeout: 5,
+}
+
+type Etcd struct {
+	ioc.ObjectImpl
+
+	Endpoints []string `toml:"endpoints" json:"endpoints" yaml:"endpoints" env:"ENDPOINTS" envSeparator:","`
+	Username  string   `toml:"username" json:"username" yaml:"username" env:"USERNAME"`
+	Password  string   `toml:"password" json:"password" yaml:"password" env:"PASSWORD"`
+	// 连接超时时间, 单位秒
+	DialTimeout int `toml:"dial_timeout" json:"dial_timeout" yaml:"dial_timeout" env:"DIAL_TIMEOUT"`
+
+	client *clientv3.Client
+	log    *zerolog.Logger
+}
+
+func (e *Etcd) Name() string {
+	return AppName
+}
+
+func (e *Etcd) Priority() int {
+	return 695
+}
+
+func (e *Etcd) Init() error {
+	e.log = log.Sub(e.Name())
+
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   e.Endpoints,
+		Username:    e.Username,
+		Password:    e.Password,
+		DialTimeout: time.Duration(e.DialTimeout) * time.Second,
+	})
+	if err != nil {
+		return err
+	}
+	e.client = client
+	e.log.Debug().Msgf("etcd endpoints: %v", e.Endpoints)
+	return nil
+}
+
+// Client etcd客户端, 没有初始化时为nil
+func (e *Etcd) Client() *clientv3.Client {
+	return e.client
+}
+
+func (e *Etcd) Close(ctx context.Context) {
+	if e.client == nil {
+		return
+	}
+	if err := e.client.Close(); err != nil {
+		e.log.Error().Msgf("close etcd client error, %s", err)
+	}
+}
@@ -0,0 +1,22 @@
+package etcd
+
+import (
+	"github.com/infraboard/mcube/v2/ioc"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const (
+	AppName = "etcd"
+)
+
+func Client() *clientv3.Client {
+	return Get().Client()
+}
+
+func Get() *Etcd {
+	obj := ioc.Config().Get(AppName)
+	if obj == nil {
+		return defaultConfig
+	}
+	return obj.(*Etcd)
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net"
 
+	"github.com/infraboard/mcube/v2/grpc/middleware/exception"
 	"github.com/infraboard/mcube/v2/grpc/middleware/recovery"
 	"github.com/infraboard/mcube/v2/ioc"
 	"github.com/infraboard/mcube/v2/ioc/config/log"
@@ -19,10 +20,11 @@ func init() {
 }
 
 var defaultConfig = &Grpc{
-	Host:     "127.0.0.1",
-	Port:     18080,
-	Recovery: true,
-	Trace:    true,
+	Host:      "127.0.0.1",
+	Port:      18080,
+	Recovery:  true,
+	Trace:     true,
+	Exception: true,
 }
 
 type Grpc struct {
@@ -41,6 +43,8 @@ type Grpc struct {
 	Recovery bool `json:"recovery" yaml:"recovery" toml:"recovery" env:"RECOVERY"`
 	// 开启Trace
 	Trace bool `json:"trace" yaml:"trace" toml:"trace" env:"TRACE"`
+	// 把ApiException转换为携带详细信息的google.rpc.Status
+	Exception bool `json:"exception" yaml:"exception" toml:"exception" env:"EXCEPTION"`
 
 	// 解析后的数据
 	interceptors []grpc.UnaryServerInterceptor
@@ -96,6 +100,9 @@ func (g *Grpc) Interceptors() (interceptors []grpc.UnaryServerInterceptor) {
 			recovery.NewInterceptor(recovery.NewZeroLogRecoveryHandler()).
 				UnaryServerInterceptor())
 	}
+	if g.Exception {
+		interceptors = append(interceptors, exception.NewUnaryServerInterceptor())
+	}
 
 	interceptors = append(interceptors, g.interceptors...)
 	return
@@ -113,6 +120,9 @@ func (g *Grpc) ServerOpts() []grpc.ServerOption {
 	}
 	// 补充中间件
 	opts = append(opts, grpc.ChainUnaryInterceptor(g.Interceptors()...))
+	if g.Exception {
+		opts = append(opts, grpc.ChainStreamInterceptor(exception.NewStreamServerInterceptor()))
+	}
 	return opts
 }
 
@@ -13,6 +13,7 @@ import (
 	humanize "github.com/dustin/go-humanize"
 	"github.com/emicklei/go-restful/v3"
 	"github.com/go-openapi/spec"
+	"github.com/infraboard/mcube/v2/exception"
 	"github.com/infraboard/mcube/v2/ioc"
 	"github.com/infraboard/mcube/v2/ioc/config/application"
 	"github.com/infraboard/mcube/v2/ioc/config/log"
@@ -58,6 +59,11 @@ type Http struct {
 	// header最大大小
 	MaxHeaderSize string `json:"max_header_size" yaml:"max_header_size" toml:"max_header_size" env:"MAX_HEADER_SIZE"`
 
+	// 异常响应是否总是使用RFC 7807 application/problem+json格式, 关闭时根据请求的Accept协商
+	ProblemDetails bool `json:"problem_details" yaml:"problem_details" toml:"problem_details" env:"PROBLEM_DETAILS"`
+	// Problem Details中type字段的错误码文档地址
+	ProblemTypeBaseURL string `json:"problem_type_base_url" yaml:"problem_type_base_url" toml:"problem_type_base_url" env:"PROBLEM_TYPE_BASE_URL"`
+
 	// 解析后的数据
 	maxHeaderBytes uint64
 	log            *zerolog.Logger
@@ -113,6 +119,9 @@ func (h *Http) Init() error {
 	}
 	h.maxHeaderBytes = mhz
 
+	exception.EnableProblemDetails(h.ProblemDetails)
+	exception.SetProblemTypeBaseURL(h.ProblemTypeBaseURL)
+
 	h.server = &http.Server{
 		ReadHeaderTimeout: time.Duration(h.ReadHeaderTimeoutSecond) * time.Second,
 		ReadTimeout:       time.Duration(h.ReadTimeoutSecond) * time.Second,
@@ -1,11 +1,20 @@
 package kafka
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/infraboard/mcube/v2/ioc"
+	"github.com/infraboard/mcube/v2/ioc/config/application"
 	"github.com/infraboard/mcube/v2/ioc/config/log"
+	"github.com/prometheus/client_golang/prometheus"
 	kafka "github.com/segmentio/kafka-go"
 	"github.com/segmentio/kafka-go/sasl"
 	"github.com/segmentio/kafka-go/sasl/scram"
@@ -19,6 +28,10 @@ var defaultConfig = &Kafka{
 	Brokers:        []string{"127.0.0.1:9092"},
 	ScramAlgorithm: SHA512,
 	Debug:          false,
+	ProducerConfig: ProducerConfig{
+		Balancer: BALANCER_HASH,
+	},
+	AutoCreateTopic: true,
 }
 
 type Kafka struct {
@@ -28,7 +41,37 @@ type Kafka struct {
 	Password       string         `toml:"password" json:"password" yaml:"password"  env:"PASSWORD"`
 	Debug          bool           `toml:"debug" json:"debug" yaml:"debug"  env:"DEBUG"`
 
+	// 开启TLS, 可以与SCRAM认证同时使用
+	EnableTLS bool `toml:"enable_tls" json:"enable_tls" yaml:"enable_tls"  env:"ENABLE_TLS"`
+	// 校验服务端证书的CA, 为空时使用系统CA
+	CAFile string `toml:"ca_file" json:"ca_file" yaml:"ca_file"  env:"CA_FILE"`
+	// 客户端证书, 服务端开启双向认证时需要
+	CertFile string `toml:"cert_file" json:"cert_file" yaml:"cert_file"  env:"CERT_FILE"`
+	KeyFile  string `toml:"key_file" json:"key_file" yaml:"key_file"  env:"KEY_FILE"`
+	// 跳过服务端证书校验, 仅用于测试
+	InsecureSkipVerify bool `toml:"insecure_skip_verify" json:"insecure_skip_verify" yaml:"insecure_skip_verify"  env:"INSECURE_SKIP_VERIFY"`
+
+	// 生产者配置
+	ProducerConfig ProducerConfig `toml:"producer" json:"producer" yaml:"producer"  envPrefix:"PRODUCER_"`
+
+	// 发送时Topic不存在则自动创建
+	AutoCreateTopic bool `toml:"auto_create_topic" json:"auto_create_topic" yaml:"auto_create_topic"  env:"AUTO_CREATE_TOPIC"`
+	// 自动创建Topic的分区数与副本数, 为0时由broker按照默认配置创建
+	Partitions        int `toml:"partitions" json:"partitions" yaml:"partitions"  env:"PARTITIONS"`
+	ReplicationFactor int `toml:"replication_factor" json:"replication_factor" yaml:"replication_factor"  env:"REPLICATION_FACTOR"`
+	// 启动时创建的Topic, 已经存在的Topic不会修改
+	Topics []*Topic `toml:"topics" json:"topics" yaml:"topics"`
+
+	// 采集消费组的消费延迟(lag)指标
+	Metric bool `toml:"metric" json:"metric" yaml:"metric"  env:"METRIC"`
+
 	mechanism sasl.Mechanism
+	tls       *tls.Config
+	// 已经创建的Topic
+	topics sync.Map
+	// 消费组 -> Topic -> Reader数量, 用于采集消费延迟
+	groups map[string]map[string]int
+	mu     sync.Mutex
 	ioc.ObjectImpl
 }
 
@@ -41,6 +84,10 @@ func (i *Kafka) Priority() int {
 }
 
 func (k *Kafka) Init() error {
+	if err := k.ProducerConfig.Validate(); err != nil {
+		return fmt.Errorf("producer config error, %w", err)
+	}
+
 	if k.UserName != "" {
 		mechanism, err := scram.Mechanism(k.scramAlgorithm(), k.UserName, k.Password)
 		if err != nil {
@@ -49,6 +96,29 @@ func (k *Kafka) Init() error {
 		k.mechanism = mechanism
 	}
 
+	if k.EnableTLS {
+		conf, err := k.tlsConfig()
+		if err != nil {
+			return err
+		}
+		k.tls = conf
+	}
+
+	if len(k.Topics) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := k.CreateTopics(ctx, k.Topics...); err != nil {
+			return err
+		}
+	}
+
+	if k.Metric {
+		err := prometheus.Register(NewLagCollector(k, application.Get().GetAppName()))
+		are := prometheus.AlreadyRegisteredError{}
+		if err != nil && !errors.As(err, &are) {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -61,16 +131,67 @@ func (k *Kafka) scramAlgorithm() scram.Algorithm {
 	}
 }
 
+func (k *Kafka) tlsConfig() (*tls.Config, error) {
+	conf := &tls.Config{
+		InsecureSkipVerify: k.InsecureSkipVerify,
+	}
+	if k.CAFile != "" {
+		ca, err := os.ReadFile(k.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ca file error, %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificate found in ca file %s", k.CAFile)
+		}
+		conf.RootCAs = pool
+	}
+	if k.CertFile != "" || k.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(k.CertFile, k.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate error, %w", err)
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	return conf, nil
+}
+
+// Transport 生产者与管理接口使用的连接配置
+func (k *Kafka) Transport() *kafka.Transport {
+	return &kafka.Transport{SASL: k.mechanism, TLS: k.tls}
+}
+
+// Dialer 消费者使用的连接配置
+func (k *Kafka) Dialer() *kafka.Dialer {
+	return &kafka.Dialer{
+		Timeout:       10 * time.Second,
+		DualStack:     true,
+		SASLMechanism: k.mechanism,
+		TLS:           k.tls,
+	}
+}
+
+// Client 管理接口的客户端, 比如创建Topic, 查询消费组的offset
+func (k *Kafka) Client() *kafka.Client {
+	return &kafka.Client{
+		Addr:      kafka.TCP(k.Brokers...),
+		Transport: k.Transport(),
+	}
+}
+
 func (k *Kafka) Producer(topic string) *kafka.Writer {
 	l := log.Sub(fmt.Sprintf("producer_%s", topic))
 
 	w := &kafka.Writer{
 		Addr:                   kafka.TCP(k.Brokers...),
 		Topic:                  topic,
-		Balancer:               &kafka.LeastBytes{},
-		AllowAutoTopicCreation: true,
+		AllowAutoTopicCreation: k.AutoCreateTopic,
 		ErrorLogger:            kafka.LoggerFunc(l.Error().Msgf),
-		Transport:              &kafka.Transport{SASL: k.mechanism},
+		Transport:              k.Transport(),
+	}
+	if err := k.ProducerConfig.apply(w); err != nil {
+		// 配置在Init时已经校验
+		l.Error().Msgf("apply producer config error, %s", err)
 	}
 	if k.Debug {
 		w.Logger = kafka.LoggerFunc(l.Debug().Msgf)
@@ -78,17 +199,13 @@ func (k *Kafka) Producer(topic string) *kafka.Writer {
 	return w
 }
 
+// ConsumerGroup 消费组的Reader, 不再使用时通过CloseConsumer关闭, 停止采集它的消费延迟
 func (k *Kafka) ConsumerGroup(groupId string, topics []string) *kafka.Reader {
 	l := log.Sub(fmt.Sprintf("consumer_group_%s", groupId))
-	dialer := &kafka.Dialer{
-		Timeout:       10 * time.Second,
-		DualStack:     true,
-		SASLMechanism: k.mechanism,
-	}
 
 	conf := kafka.ReaderConfig{
 		Brokers:     k.Brokers,
-		Dialer:      dialer,
+		Dialer:      k.Dialer(),
 		GroupID:     groupId,
 		GroupTopics: topics,
 		ErrorLogger: kafka.LoggerFunc(l.Error().Msgf),
@@ -98,5 +215,124 @@ func (k *Kafka) ConsumerGroup(groupId string, topics []string) *kafka.Reader {
 		conf.Logger = kafka.LoggerFunc(l.Debug().Msgf)
 	}
 
+	k.trackGroup(groupId, topics, 1)
 	return kafka.NewReader(conf)
 }
+
+// CloseConsumer 关闭ConsumerGroup创建的Reader
+func (k *Kafka) CloseConsumer(r *kafka.Reader) error {
+	conf := r.Config()
+	k.trackGroup(conf.GroupID, conf.GroupTopics, -1)
+	return r.Close()
+}
+
+func (k *Kafka) trackGroup(groupId string, topics []string, delta int) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.groups == nil {
+		k.groups = map[string]map[string]int{}
+	}
+	group, ok := k.groups[groupId]
+	if !ok {
+		group = map[string]int{}
+		k.groups[groupId] = group
+	}
+	for _, t := range topics {
+		group[t] += delta
+		if group[t] <= 0 {
+			delete(group, t)
+		}
+	}
+	if len(group) == 0 {
+		delete(k.groups, groupId)
+	}
+}
+
+// 正在消费的消费组与Topic
+func (k *Kafka) consumingGroups() map[string][]string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	groups := make(map[string][]string, len(k.groups))
+	for g, topics := range k.groups {
+		for t := range topics {
+			groups[g] = append(groups[g], t)
+		}
+	}
+	return groups
+}
+
+type BALANCER string
+
+const (
+	// 按照消息键的哈希选择分区, 相同键的消息发送到同一个分区, 没有键时轮询
+	BALANCER_HASH BALANCER = "hash"
+	// 与Java客户端相同的murmur2哈希
+	BALANCER_MURMUR2 BALANCER = "murmur2"
+	// 与librdkafka相同的crc32哈希
+	BALANCER_CRC32 BALANCER = "crc32"
+	// 轮询
+	BALANCER_ROUND_ROBIN BALANCER = "round_robin"
+	// 选择接收数据最少的分区
+	BALANCER_LEAST_BYTES BALANCER = "least_bytes"
+)
+
+// ProducerConfig 生产者配置, 为0时使用kafka-go的默认值,
+// 默认配置的分区选择为hash, 相同的消息键发送到同一个分区, 与之前的least_bytes不同.
+// kafka-go没有实现幂等与事务生产者, 因此没有对应的配置
+type ProducerConfig struct {
+	// 确认级别: none(默认, 不等待确认), one(等待leader确认), all(等待所有同步副本确认)
+	RequiredAcks string `toml:"required_acks" json:"required_acks" yaml:"required_acks"  env:"REQUIRED_ACKS"`
+	// 压缩算法: gzip, snappy, lz4, zstd, 为空时不压缩
+	Compression string `toml:"compression" json:"compression" yaml:"compression"  env:"COMPRESSION"`
+	// 每个批次的最大消息数, 默认100
+	BatchSize int `toml:"batch_size" json:"batch_size" yaml:"batch_size"  env:"BATCH_SIZE"`
+	// 每个批次的最大字节数, 默认1MB
+	BatchBytes int64 `toml:"batch_bytes" json:"batch_bytes" yaml:"batch_bytes"  env:"BATCH_BYTES"`
+	// 批次未满时的最长等待时间, 单位毫秒, 默认1000
+	BatchTimeout int `toml:"batch_timeout" json:"batch_timeout" yaml:"batch_timeout"  env:"BATCH_TIMEOUT"`
+	// 分区选择: hash(默认), murmur2, crc32, round_robin, least_bytes
+	Balancer BALANCER `toml:"balancer" json:"balancer" yaml:"balancer"  env:"BALANCER"`
+	// 发送失败的最大尝试次数, 默认10
+	MaxAttempts int `toml:"max_attempts" json:"max_attempts" yaml:"max_attempts"  env:"MAX_ATTEMPTS"`
+}
+
+func (c *ProducerConfig) apply(w *kafka.Writer) error {
+	w.BatchSize = c.BatchSize
+	w.BatchBytes = c.BatchBytes
+	w.BatchTimeout = time.Duration(c.BatchTimeout) * time.Millisecond
+	w.MaxAttempts = c.MaxAttempts
+
+	if c.RequiredAcks != "" {
+		if err := w.RequiredAcks.UnmarshalText([]byte(c.RequiredAcks)); err != nil {
+			return err
+		}
+	}
+	if c.Compression != "" {
+		if err := w.Compression.UnmarshalText([]byte(strings.ToLower(c.Compression))); err != nil {
+			return err
+		}
+	}
+
+	switch c.Balancer {
+	case BALANCER_HASH, "":
+		w.Balancer = &kafka.Hash{}
+	case BALANCER_MURMUR2:
+		w.Balancer = kafka.Murmur2Balancer{}
+	case BALANCER_CRC32:
+		w.Balancer = kafka.CRC32Balancer{}
+	case BALANCER_ROUND_ROBIN:
+		w.Balancer = &kafka.RoundRobin{}
+	case BALANCER_LEAST_BYTES:
+		w.Balancer = &kafka.LeastBytes{}
+	default:
+		return fmt.Errorf("unknown balancer %s", c.Balancer)
+	}
+	return nil
+}
+
+// Validate 校验生产者配置
+func (c *ProducerConfig) Validate() error {
+	return c.apply(&kafka.Writer{})
+}
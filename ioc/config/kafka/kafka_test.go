@@ -6,6 +6,7 @@ import (
 	"github.com/infraboard/mcube/v2/ioc"
 	"github.com/infraboard/mcube/v2/ioc/config/kafka"
 	"github.com/infraboard/mcube/v2/tools/file"
+	kafka_go "github.com/segmentio/kafka-go"
 )
 
 func TestKafkaProducer(t *testing.T) {
@@ -18,6 +19,27 @@ func TestKafkaConsumerGroup(t *testing.T) {
 	t.Log(m)
 }
 
+func TestProducerConfig(t *testing.T) {
+	w := kafka.Producer("test")
+	if _, ok := w.Balancer.(*kafka_go.Hash); !ok || w.RequiredAcks != kafka_go.RequireNone {
+		t.Fatalf("unexpected default producer config, %T %v", w.Balancer, w.RequiredAcks)
+	}
+
+	conf := &kafka.ProducerConfig{RequiredAcks: "one", Compression: "zstd", Balancer: kafka.BALANCER_MURMUR2}
+	if err := conf.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for _, conf := range []*kafka.ProducerConfig{
+		{RequiredAcks: "some"},
+		{Compression: "brotli"},
+		{Balancer: "random"},
+	} {
+		if err := conf.Validate(); err == nil {
+			t.Fatalf("expect invalid producer config %v", conf)
+		}
+	}
+}
+
 func TestDefaultConfig(t *testing.T) {
 	file.MustToToml(
 		kafka.AppName,
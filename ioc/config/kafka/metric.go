@@ -0,0 +1,111 @@
+package kafka
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/infraboard/mcube/v2/ioc/config/log"
+	"github.com/prometheus/client_golang/prometheus"
+	kafka "github.com/segmentio/kafka-go"
+)
+
+func NewLagCollector(k *Kafka, appName string) *LagCollector {
+	return &LagCollector{
+		k: k,
+		lag: prometheus.NewDesc(
+			"kafka_consumer_group_lag",
+			"Number of messages the consumer group has not committed, per partition",
+			[]string{"group", "topic", "partition"},
+			map[string]string{"app": appName},
+		),
+		timeout: 5 * time.Second,
+	}
+}
+
+// LagCollector 采集时查询正在消费的消费组已提交的offset与分区最新的offset, 两者之差为消费延迟
+type LagCollector struct {
+	k       *Kafka
+	lag     *prometheus.Desc
+	timeout time.Duration
+}
+
+func (c *LagCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.lag
+}
+
+func (c *LagCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	for group, topics := range c.k.consumingGroups() {
+		lags, err := c.k.ConsumerLag(ctx, group, topics...)
+		if err != nil {
+			log.Sub(AppName).Error().Msgf("collect consumer group %s lag error, %s", group, err)
+			continue
+		}
+		for topic, partitions := range lags {
+			for partition, lag := range partitions {
+				ch <- prometheus.MustNewConstMetric(c.lag, prometheus.GaugeValue, float64(lag),
+					group, topic, strconv.Itoa(partition))
+			}
+		}
+	}
+}
+
+// ConsumerLag 消费组在每个分区的消费延迟: Topic -> 分区 -> 延迟的消息数, 消费组还没有提交过offset的分区不返回
+func (k *Kafka) ConsumerLag(ctx context.Context, group string, topics ...string) (map[string]map[int]int64, error) {
+	client := k.Client()
+	meta, err := client.Metadata(ctx, &kafka.MetadataRequest{Topics: topics})
+	if err != nil {
+		return nil, err
+	}
+
+	partitions := map[string][]int{}
+	lastOffsets := map[string][]kafka.OffsetRequest{}
+	for _, t := range meta.Topics {
+		if t.Error != nil {
+			continue
+		}
+		for _, p := range t.Partitions {
+			partitions[t.Name] = append(partitions[t.Name], p.ID)
+			lastOffsets[t.Name] = append(lastOffsets[t.Name], kafka.LastOffsetOf(p.ID))
+		}
+	}
+	if len(partitions) == 0 {
+		return nil, nil
+	}
+
+	committed, err := client.OffsetFetch(ctx, &kafka.OffsetFetchRequest{GroupID: group, Topics: partitions})
+	if err != nil {
+		return nil, err
+	}
+	if committed.Error != nil {
+		return nil, committed.Error
+	}
+	offsets, err := client.ListOffsets(ctx, &kafka.ListOffsetsRequest{Topics: lastOffsets})
+	if err != nil {
+		return nil, err
+	}
+
+	lags := map[string]map[int]int64{}
+	for topic, ps := range committed.Topics {
+		last := map[int]int64{}
+		for _, o := range offsets.Topics[topic] {
+			if o.Error == nil {
+				last[o.Partition] = o.LastOffset
+			}
+		}
+		for _, p := range ps {
+			end, ok := last[p.Partition]
+			if p.Error != nil || p.CommittedOffset < 0 || !ok {
+				continue
+			}
+			if lags[topic] == nil {
+				lags[topic] = map[int]int64{}
+			}
+			lags[topic][p.Partition] = max(end-p.CommittedOffset, 0)
+		}
+	}
+	return lags, nil
+}
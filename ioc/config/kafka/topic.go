@@ -0,0 +1,68 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	kafka "github.com/segmentio/kafka-go"
+)
+
+// Topic 需要创建的Topic
+type Topic struct {
+	// Topic名称
+	Name string `toml:"name" json:"name" yaml:"name"`
+	// 分区数, 为0时使用全局的partitions配置
+	Partitions int `toml:"partitions" json:"partitions" yaml:"partitions"`
+	// 副本数, 为0时使用全局的replication_factor配置
+	ReplicationFactor int `toml:"replication_factor" json:"replication_factor" yaml:"replication_factor"`
+}
+
+// CreateTopics 创建Topic, 已经存在的Topic忽略
+func (k *Kafka) CreateTopics(ctx context.Context, topics ...*Topic) error {
+	req := &kafka.CreateTopicsRequest{}
+	for _, t := range topics {
+		req.Topics = append(req.Topics, kafka.TopicConfig{
+			Topic:             t.Name,
+			NumPartitions:     k.orDefault(t.Partitions, k.Partitions),
+			ReplicationFactor: k.orDefault(t.ReplicationFactor, k.ReplicationFactor),
+		})
+	}
+
+	resp, err := k.Client().CreateTopics(ctx, req)
+	if err != nil {
+		return fmt.Errorf("create topics error, %w", err)
+	}
+	var errs []error
+	for topic, err := range resp.Errors {
+		if err != nil && !errors.Is(err, kafka.TopicAlreadyExists) {
+			errs = append(errs, fmt.Errorf("create topic %s error, %w", topic, err))
+			continue
+		}
+		k.topics.Store(topic, true)
+	}
+	return errors.Join(errs...)
+}
+
+// EnsureTopic 开启自动创建并配置了分区数时, 按照配置的分区数与副本数创建Topic, 每个Topic只创建一次,
+// 没有配置分区数时由broker在第一次发送时按照默认配置创建
+func (k *Kafka) EnsureTopic(ctx context.Context, topic string) error {
+	if !k.AutoCreateTopic || k.Partitions <= 0 {
+		return nil
+	}
+	if _, ok := k.topics.Load(topic); ok {
+		return nil
+	}
+	return k.CreateTopics(ctx, &Topic{Name: topic})
+}
+
+// -1表示使用broker的默认配置
+func (k *Kafka) orDefault(v, def int) int {
+	if v > 0 {
+		return v
+	}
+	if def > 0 {
+		return def
+	}
+	return -1
+}
@@ -0,0 +1,91 @@
+package lock_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/bluele/gcache"
+	"github.com/glebarez/sqlite"
+	"github.com/infraboard/mcube/v2/ioc/config/lock"
+	"github.com/infraboard/mcube/v2/ioc/config/lock/locktest"
+	"github.com/redis/go-redis/v9"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestGoCacheLockConformance(t *testing.T) {
+	locktest.Suite{
+		Factory: lock.NewGoCacheLockProviderWithCache(gcache.New(100).LRU().Build()),
+		Advance: time.Sleep,
+	}.Run(t)
+}
+
+func TestRedisLockConformance(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	// 读写锁与信号量的脚本使用TIME命令, 需要同时调整miniredis的时间
+	now := time.Now()
+	mr.SetTime(now)
+	locktest.Suite{
+		Factory: lock.NewRedisLockProviderWithClient(client),
+		Advance: func(d time.Duration) {
+			now = now.Add(d)
+			mr.SetTime(now)
+			mr.FastForward(d)
+		},
+	}.Run(t)
+}
+
+func TestSqlLockConformance(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "lock.db")), &gorm.Config{
+		Logger: logger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlDB.Close()
+	// SQLite同时只允许一个写入
+	sqlDB.SetMaxOpenConns(1)
+
+	p := lock.NewSqlLockProviderWithDB(db)
+	if err := p.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	locktest.Suite{
+		Factory: p,
+		Advance: time.Sleep,
+	}.Run(t)
+}
+
+// 需要etcd, 通过ETCD_ENDPOINTS指定地址, 比如: ETCD_ENDPOINTS=127.0.0.1:2379
+func TestEtcdLockConformance(t *testing.T) {
+	endpoints := os.Getenv("ETCD_ENDPOINTS")
+	if endpoints == "" {
+		t.Skip("ETCD_ENDPOINTS not set")
+	}
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   strings.Split(endpoints, ","),
+		DialTimeout: 5 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	locktest.Suite{
+		Factory: lock.NewEtcdLockProviderWithClient(client),
+		Advance: time.Sleep,
+	}.Run(t)
+}
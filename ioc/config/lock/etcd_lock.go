@@ -1 +1,278 @@
 package lock
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/infraboard/mcube/v2/ioc"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+var errEtcdNotRegistered = errors.New("lock: etcd not registered, import ioc/config/etcd")
+
+func NewEtcdLockProvider() *EtcdLockProvider {
+	return &EtcdLockProvider{}
+}
+
+// NewEtcdLockProviderWithClient 使用指定的etcd客户端, 而不是ioc中的etcd
+func NewEtcdLockProviderWithClient(client *clientv3.Client) *EtcdLockProvider {
+	return &EtcdLockProvider{client: client}
+}
+
+type EtcdLockProvider struct {
+	client *clientv3.Client
+}
+
+func (e *EtcdLockProvider) getClient() *clientv3.Client {
+	if e.client != nil {
+		return e.client
+	}
+	return etcdClient()
+}
+
+// 通过ioc获取etcd客户端, 不直接引用etcd, 避免使用其他锁时也连接etcd
+func etcdClient() *clientv3.Client {
+	c, ok := ioc.Config().Get(PROVIDER_ETCD).(interface {
+		Client() *clientv3.Client
+	})
+	if !ok {
+		return nil
+	}
+	return c.Client()
+}
+
+func (e *EtcdLockProvider) New(key string, ttl time.Duration) Lock {
+	return &EtcdLock{
+		client: e.getClient(),
+		key:    key,
+		ttl:    ttl,
+		opt:    DefaultOptions(),
+	}
+}
+
+// EtcdLock 锁的key绑定到一个租约上, 租约过期后key被etcd自动删除,
+// etcd租约的最小单位为秒, ttl不足1秒时按1秒处理
+type EtcdLock struct {
+	client   *clientv3.Client
+	key      string
+	ttl      time.Duration
+	opt      *Options
+	value    string
+	tokenLen int
+	lease    clientv3.LeaseID
+	fencing  int64
+}
+
+func (l *EtcdLock) getTimeout() time.Duration {
+	if l.opt.Timeout > 0 {
+		return l.opt.Timeout
+	}
+	return l.ttl * 3
+}
+
+func (l *EtcdLock) leaseTTL() int64 {
+	return max(int64(math.Ceil(l.ttl.Seconds())), 1)
+}
+
+// 锁配置
+func (l *EtcdLock) WithOpt(opt *Options) Lock {
+	l.opt = opt
+	return l
+}
+
+// 获取锁
+func (l *EtcdLock) Lock(ctx context.Context) error {
+	value, tokenLen, err := l.opt.lockValue()
+	if err != nil {
+		return err
+	}
+
+	return obtainWithRetry(ctx, l.opt.getRetryStrategy(), l.getTimeout(), func(ctx context.Context) (bool, error) {
+		return l.obtain(ctx, value, tokenLen)
+	})
+}
+
+// TryLock
+func (l *EtcdLock) TryLock(ctx context.Context) error {
+	value, tokenLen, err := l.opt.lockValue()
+	if err != nil {
+		return err
+	}
+
+	ok, err := l.obtain(ctx, value, tokenLen)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotObtained
+	}
+	return nil
+}
+
+func (l *EtcdLock) obtain(ctx context.Context, value string, tokenLen int) (bool, error) {
+	if l.client == nil {
+		return false, errEtcdNotRegistered
+	}
+	lease, err := l.client.Grant(ctx, l.leaseTTL())
+	if err != nil {
+		return false, err
+	}
+
+	// key不存在时写入, 存在时返回当前的值用于判断是否为同一个token
+	resp, err := l.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(l.key), "=", 0)).
+		Then(clientv3.OpPut(l.key, value, clientv3.WithLease(lease.ID))).
+		Else(clientv3.OpGet(l.key)).
+		Commit()
+	if err != nil {
+		l.revoke(lease.ID)
+		return false, err
+	}
+
+	// 写入锁时的revision全局单调递增, 直接作为fencing token
+	revision := resp.Header.Revision
+	if !resp.Succeeded {
+		revision, err = l.override(ctx, resp, lease.ID, value, tokenLen)
+		if err != nil || revision == 0 {
+			l.revoke(lease.ID)
+			return false, err
+		}
+	}
+
+	l.value = value
+	l.tokenLen = tokenLen
+	l.lease = lease.ID
+	l.fencing = revision
+	return true, nil
+}
+
+// 与Redis保持一致, 相同token的锁可以重复获取, 此时使用新的租约覆盖, 返回覆盖时的revision, 未覆盖时返回0
+func (l *EtcdLock) override(ctx context.Context, resp *clientv3.TxnResponse, lease clientv3.LeaseID, value string, tokenLen int) (int64, error) {
+	kvs := resp.Responses[0].GetResponseRange().GetKvs()
+	if len(kvs) == 0 || !strings.HasPrefix(string(kvs[0].Value), value[:tokenLen]) {
+		return 0, nil
+	}
+
+	current := kvs[0]
+	resp, err := l.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(l.key), "=", current.ModRevision)).
+		Then(clientv3.OpPut(l.key, value, clientv3.WithLease(lease))).
+		Commit()
+	if err != nil || !resp.Succeeded {
+		return 0, err
+	}
+	l.revoke(clientv3.LeaseID(current.Lease))
+	return resp.Header.Revision, nil
+}
+
+// 释放锁
+func (l *EtcdLock) UnLock(ctx context.Context) error {
+	if l.value == "" {
+		return ErrLockNotHeld
+	}
+
+	resp, err := l.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.Value(l.key), "=", l.value)).
+		Then(clientv3.OpDelete(l.key)).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return ErrLockNotHeld
+	}
+
+	l.revoke(l.lease)
+	l.value = ""
+	return nil
+}
+
+// 刷新锁, ttl为0时使用创建锁时的ttl, ttl不变时续约原有的租约, 否则换绑到新的租约
+func (l *EtcdLock) Refresh(ctx context.Context, ttl time.Duration) error {
+	if l.value == "" {
+		return ErrNotObtained
+	}
+
+	if ttl <= 0 || ttl == l.ttl {
+		return l.keepAlive(ctx)
+	}
+
+	l.ttl = ttl
+	lease, err := l.client.Grant(ctx, l.leaseTTL())
+	if err != nil {
+		return err
+	}
+	resp, err := l.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.Value(l.key), "=", l.value)).
+		Then(clientv3.OpPut(l.key, l.value, clientv3.WithLease(lease.ID))).
+		Commit()
+	if err != nil {
+		l.revoke(lease.ID)
+		return err
+	}
+	if !resp.Succeeded {
+		l.revoke(lease.ID)
+		return ErrNotObtained
+	}
+
+	l.revoke(l.lease)
+	l.lease = lease.ID
+	return nil
+}
+
+func (l *EtcdLock) keepAlive(ctx context.Context) error {
+	resp, err := l.client.Txn(ctx).
+		If(
+			clientv3.Compare(clientv3.Value(l.key), "=", l.value),
+			clientv3.Compare(clientv3.LeaseValue(l.key), "=", l.lease),
+		).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return ErrNotObtained
+	}
+
+	if _, err := l.client.KeepAliveOnce(ctx, l.lease); err != nil {
+		if err == rpctypes.ErrLeaseNotFound {
+			return ErrNotObtained
+		}
+		return err
+	}
+	return nil
+}
+
+// 释放租约, 失败时租约会在ttl后自动过期
+func (l *EtcdLock) revoke(lease clientv3.LeaseID) {
+	if lease == clientv3.NoLease {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, _ = l.client.Revoke(ctx, lease)
+}
+
+// FencingToken returns the fencing token of the current acquisition, it is the etcd revision of the lock key.
+func (l *EtcdLock) FencingToken() int64 {
+	return l.fencing
+}
+
+// Key returns the etcd key used by the lock.
+func (l *EtcdLock) Key() string {
+	return l.key
+}
+
+// Token returns the token value set by the lock.
+func (l *EtcdLock) Token() string {
+	return l.value[:l.tokenLen]
+}
+
+// Metadata returns the metadata of the lock.
+func (l *EtcdLock) Metadata() string {
+	return l.value[l.tokenLen:]
+}
@@ -2,44 +2,72 @@ package lock
 
 import (
 	"context"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/bluele/gcache"
 	"github.com/infraboard/mcube/v2/ioc/config/gocache"
 )
 
+var (
+	// gcache没有原子的SetNX, 锁的读写通过该锁串行
+	goCacheLockMu sync.Mutex
+	// 每个key的fencing token计数, 保存在内存中, 不会被gcache淘汰
+	goCacheFencing = map[string]int64{}
+)
+
 func NewGoCacheLockProvider() *GoCacheLockProvider {
 	return &GoCacheLockProvider{}
 }
 
+// NewGoCacheLockProviderWithCache 使用指定的gcache, 而不是ioc中的gocache
+func NewGoCacheLockProviderWithCache(cache gcache.Cache) *GoCacheLockProvider {
+	return &GoCacheLockProvider{cache: cache}
+}
+
 type GoCacheLockProvider struct {
+	cache gcache.Cache
+}
+
+func (r *GoCacheLockProvider) getCache() gcache.Cache {
+	if r.cache != nil {
+		return r.cache
+	}
+	return gocache.C()
 }
 
 func (r *GoCacheLockProvider) New(key string, ttl time.Duration) Lock {
 	return &GoCacheLock{
 		key:   key,
 		ttl:   ttl,
-		cache: gocache.C(),
+		cache: r.getCache(),
 		opt:   DefaultOptions(),
 	}
 }
 
 type GoCacheLock struct {
-	cache gcache.Cache
-	key   string
-	value string
-	ttl   time.Duration
-	opt   *Options
+	cache   gcache.Cache
+	key     string
+	value   string
+	ttl     time.Duration
+	opt     *Options
+	fencing int64
 }
 
 // 锁配置
 func (m *GoCacheLock) WithOpt(opt *Options) Lock {
+	m.opt = opt
 	return m
 }
 
 // 获取锁
 func (m *GoCacheLock) Lock(ctx context.Context) error {
-	retry := m.opt.getRetryStrategy()
+	value, tokenLen, err := m.opt.lockValue()
+	if err != nil {
+		return err
+	}
+
 	// make sure we don't retry forever
 	if _, ok := ctx.Deadline(); !ok {
 		var cancel context.CancelFunc
@@ -47,38 +75,19 @@ func (m *GoCacheLock) Lock(ctx context.Context) error {
 		defer cancel()
 	}
 
-	var ticker *time.Ticker
-	for {
-		ok, err := m.obtain(ctx)
-		if err != nil {
-			return err
-		} else if ok {
-			return nil
-		}
-
-		backoff := retry.NextBackoff()
-		if backoff < 1 {
-			return ErrNotObtained
-		}
-
-		if ticker == nil {
-			ticker = time.NewTicker(backoff)
-			defer ticker.Stop()
-		} else {
-			ticker.Reset(backoff)
-		}
-
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-ticker.C:
-		}
-	}
+	return obtainWithRetry(ctx, m.opt.getRetryStrategy(), 0, func(ctx context.Context) (bool, error) {
+		return m.obtain(ctx, value, tokenLen)
+	})
 }
 
 // TryLock
 func (m *GoCacheLock) TryLock(ctx context.Context) error {
-	ok, err := m.obtain(ctx)
+	value, tokenLen, err := m.opt.lockValue()
+	if err != nil {
+		return err
+	}
+
+	ok, err := m.obtain(ctx, value, tokenLen)
 	if err != nil {
 		return err
 	} else if ok {
@@ -87,24 +96,62 @@ func (m *GoCacheLock) TryLock(ctx context.Context) error {
 	return ErrNotObtained
 }
 
-func (m *GoCacheLock) obtain(context.Context) (bool, error) {
-	if m.cache.Has(m.key) {
+func (m *GoCacheLock) obtain(_ context.Context, value string, tokenLen int) (bool, error) {
+	goCacheLockMu.Lock()
+	defer goCacheLockMu.Unlock()
+
+	// 与Redis保持一致, 相同token的锁可以重复获取
+	if current, ok := m.current(); ok && !strings.HasPrefix(current, value[:tokenLen]) {
 		return false, nil
 	}
 
-	if err := m.cache.SetWithExpire(m.key, m.value, m.ttl); err != nil {
+	if err := m.cache.SetWithExpire(m.key, value, m.ttl); err != nil {
 		return false, err
 	}
+	goCacheFencing[m.key]++
+	m.value = value
+	m.fencing = goCacheFencing[m.key]
 	return true, nil
 }
 
+// 当前锁的值, 锁不存在或者已过期时返回false
+func (m *GoCacheLock) current() (string, bool) {
+	v, err := m.cache.Get(m.key)
+	if err != nil {
+		return "", false
+	}
+	current, ok := v.(string)
+	return current, ok
+}
+
 // 释放锁
 func (m *GoCacheLock) UnLock(context.Context) error {
+	goCacheLockMu.Lock()
+	defer goCacheLockMu.Unlock()
+
+	if current, ok := m.current(); !ok || m.value == "" || current != m.value {
+		return ErrLockNotHeld
+	}
 	m.cache.Remove(m.key)
+	m.value = ""
 	return nil
 }
 
-// 刷新锁
+// 刷新锁, ttl为0时使用创建锁时的ttl
 func (m *GoCacheLock) Refresh(ctx context.Context, ttl time.Duration) error {
+	goCacheLockMu.Lock()
+	defer goCacheLockMu.Unlock()
+
+	if current, ok := m.current(); !ok || m.value == "" || current != m.value {
+		return ErrNotObtained
+	}
+	if ttl > 0 {
+		m.ttl = ttl
+	}
 	return m.cache.SetWithExpire(m.key, m.value, m.ttl)
 }
+
+// FencingToken returns the fencing token of the current acquisition.
+func (m *GoCacheLock) FencingToken() int64 {
+	return m.fencing
+}
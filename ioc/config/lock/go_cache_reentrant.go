@@ -0,0 +1,135 @@
+package lock
+
+import (
+	"context"
+	"time"
+
+	"github.com/bluele/gcache"
+)
+
+func (r *GoCacheLockProvider) NewReentrantLock(key, owner string, ttl time.Duration) Lock {
+	return &GoCacheReentrantLock{
+		cache: r.getCache(),
+		key:   key,
+		owner: owner,
+		ttl:   ttl,
+		opt:   DefaultOptions(),
+	}
+}
+
+type goCacheReentrantState struct {
+	owner     string
+	metadata  string
+	count     int
+	fencing   int64
+	expiredAt time.Time
+}
+
+// GoCacheReentrantLock 记录持有者与持有次数, Options中的Token不生效, 使用owner作为Token
+type GoCacheReentrantLock struct {
+	cache   gcache.Cache
+	key     string
+	owner   string
+	ttl     time.Duration
+	opt     *Options
+	fencing int64
+}
+
+// 锁配置
+func (m *GoCacheReentrantLock) WithOpt(opt *Options) Lock {
+	m.opt = opt
+	return m
+}
+
+// 获取锁
+func (m *GoCacheReentrantLock) Lock(ctx context.Context) error {
+	// make sure we don't retry forever
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, time.Now().Add(m.ttl))
+		defer cancel()
+	}
+	return obtainWithRetry(ctx, m.opt.getRetryStrategy(), 0, m.obtain)
+}
+
+// TryLock
+func (m *GoCacheReentrantLock) TryLock(ctx context.Context) error {
+	return obtainOnce(ctx, m.obtain)
+}
+
+// 当前未过期的锁状态
+func (m *GoCacheReentrantLock) state(now time.Time) (*goCacheReentrantState, bool) {
+	state, ok := goCacheState[goCacheReentrantState](m.cache, m.key)
+	if !ok {
+		return nil, false
+	}
+	if !state.expiredAt.After(now) {
+		state = &goCacheReentrantState{}
+	}
+	return state, true
+}
+
+func (m *GoCacheReentrantLock) obtain(context.Context) (bool, error) {
+	goCacheLockMu.Lock()
+	defer goCacheLockMu.Unlock()
+
+	now := time.Now()
+	state, ok := m.state(now)
+	if !ok || (state.count > 0 && state.owner != m.owner) {
+		return false, nil
+	}
+
+	if state.count == 0 {
+		goCacheFencing[m.key]++
+		state.owner = m.owner
+		state.metadata = m.opt.getMetadata()
+		state.fencing = goCacheFencing[m.key]
+	}
+	state.count++
+	state.expiredAt = now.Add(m.ttl)
+	if err := goCacheSaveState(m.cache, m.key, state, state.expiredAt, now); err != nil {
+		return false, err
+	}
+	m.fencing = state.fencing
+	return true, nil
+}
+
+// 释放锁, 持有次数减为0时删除锁
+func (m *GoCacheReentrantLock) UnLock(context.Context) error {
+	goCacheLockMu.Lock()
+	defer goCacheLockMu.Unlock()
+
+	now := time.Now()
+	state, ok := m.state(now)
+	if !ok || state.count == 0 || state.owner != m.owner {
+		return ErrLockNotHeld
+	}
+	state.count--
+	if state.count == 0 {
+		m.cache.Remove(m.key)
+		return nil
+	}
+	return goCacheSaveState(m.cache, m.key, state, state.expiredAt, now)
+}
+
+// 刷新锁, ttl为0时使用创建锁时的ttl
+func (m *GoCacheReentrantLock) Refresh(ctx context.Context, ttl time.Duration) error {
+	goCacheLockMu.Lock()
+	defer goCacheLockMu.Unlock()
+
+	now := time.Now()
+	state, ok := m.state(now)
+	if !ok || state.count == 0 || state.owner != m.owner {
+		return ErrNotObtained
+	}
+	if ttl > 0 {
+		m.ttl = ttl
+	}
+	state.expiredAt = now.Add(m.ttl)
+	return goCacheSaveState(m.cache, m.key, state, state.expiredAt, now)
+}
+
+// FencingToken 第一次获取锁时的fencing token, 重入时不变
+func (m *GoCacheReentrantLock) FencingToken() int64 {
+	return m.fencing
+}
@@ -0,0 +1,229 @@
+package lock
+
+import (
+	"context"
+	"time"
+
+	"github.com/bluele/gcache"
+)
+
+func (r *GoCacheLockProvider) NewRWLock(key string, ttl time.Duration) RWLock {
+	return &GoCacheRWLock{
+		cache: r.getCache(),
+		key:   key,
+		ttl:   ttl,
+		opt:   DefaultOptions(),
+	}
+}
+
+// 读取gcache中保存的锁状态, key不存在时返回新的状态, key被其他类型的锁占用时返回false
+func goCacheState[T any](cache gcache.Cache, key string) (*T, bool) {
+	v, err := cache.Get(key)
+	if err != nil {
+		return new(T), true
+	}
+	s, ok := v.(*T)
+	return s, ok
+}
+
+// 保存锁状态, 所有持有者都过期后删除
+func goCacheSaveState(cache gcache.Cache, key string, state any, expiredAt, now time.Time) error {
+	if !expiredAt.After(now) {
+		cache.Remove(key)
+		return nil
+	}
+	return cache.SetWithExpire(key, state, expiredAt.Sub(now))
+}
+
+type goCacheRWState struct {
+	writer          string
+	writerExpiredAt time.Time
+	readers         map[string]time.Time
+}
+
+// 清理过期的持有者, 返回最晚的过期时间
+func (s *goCacheRWState) prune(now time.Time) time.Time {
+	if !s.writerExpiredAt.After(now) {
+		s.writer = ""
+	}
+	expiredAt := s.writerExpiredAt
+	for reader, readerExpiredAt := range s.readers {
+		if !readerExpiredAt.After(now) {
+			delete(s.readers, reader)
+			continue
+		}
+		if readerExpiredAt.After(expiredAt) {
+			expiredAt = readerExpiredAt
+		}
+	}
+	return expiredAt
+}
+
+type GoCacheRWLock struct {
+	cache gcache.Cache
+	key   string
+	ttl   time.Duration
+	opt   *Options
+	read  bool
+	value string
+}
+
+// 锁配置
+func (m *GoCacheRWLock) WithOpt(opt *Options) RWLock {
+	m.opt = opt
+	return m
+}
+
+// 获取读锁
+func (m *GoCacheRWLock) RLock(ctx context.Context) error {
+	return m.acquire(ctx, true, true)
+}
+
+// 尝试获取读锁
+func (m *GoCacheRWLock) TryRLock(ctx context.Context) error {
+	return m.acquire(ctx, true, false)
+}
+
+// 释放读锁
+func (m *GoCacheRWLock) RUnLock(ctx context.Context) error {
+	return m.release(true)
+}
+
+// 获取写锁
+func (m *GoCacheRWLock) Lock(ctx context.Context) error {
+	return m.acquire(ctx, false, true)
+}
+
+// 尝试获取写锁
+func (m *GoCacheRWLock) TryLock(ctx context.Context) error {
+	return m.acquire(ctx, false, false)
+}
+
+// 释放写锁
+func (m *GoCacheRWLock) UnLock(ctx context.Context) error {
+	return m.release(false)
+}
+
+func (m *GoCacheRWLock) acquire(ctx context.Context, read, retry bool) error {
+	value, _, err := m.opt.lockValue()
+	if err != nil {
+		return err
+	}
+
+	obtain := func(context.Context) (bool, error) {
+		return m.obtain(read, value)
+	}
+	if retry {
+		// make sure we don't retry forever
+		if _, ok := ctx.Deadline(); !ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithDeadline(ctx, time.Now().Add(m.ttl))
+			defer cancel()
+		}
+		err = obtainWithRetry(ctx, m.opt.getRetryStrategy(), 0, obtain)
+	} else {
+		err = obtainOnce(ctx, obtain)
+	}
+	if err != nil {
+		return err
+	}
+	m.read, m.value = read, value
+	return nil
+}
+
+func (m *GoCacheRWLock) obtain(read bool, value string) (bool, error) {
+	goCacheLockMu.Lock()
+	defer goCacheLockMu.Unlock()
+
+	state, ok := goCacheState[goCacheRWState](m.cache, m.key)
+	if !ok {
+		return false, nil
+	}
+	now := time.Now()
+	expiredAt := state.prune(now)
+
+	holderExpiredAt := now.Add(m.ttl)
+	if read {
+		if state.writer != "" {
+			return false, nil
+		}
+		if state.readers == nil {
+			state.readers = map[string]time.Time{}
+		}
+		state.readers[value] = holderExpiredAt
+	} else {
+		if (state.writer != "" && state.writer != value) || len(state.readers) > 0 {
+			return false, nil
+		}
+		state.writer, state.writerExpiredAt = value, holderExpiredAt
+	}
+
+	if holderExpiredAt.After(expiredAt) {
+		expiredAt = holderExpiredAt
+	}
+	return true, goCacheSaveState(m.cache, m.key, state, expiredAt, now)
+}
+
+func (m *GoCacheRWLock) release(read bool) error {
+	goCacheLockMu.Lock()
+	defer goCacheLockMu.Unlock()
+
+	if m.value == "" || m.read != read {
+		return ErrLockNotHeld
+	}
+	value := m.value
+	m.value = ""
+
+	state, ok := goCacheState[goCacheRWState](m.cache, m.key)
+	if !ok {
+		return ErrLockNotHeld
+	}
+	now := time.Now()
+	state.prune(now)
+
+	if read {
+		if _, ok := state.readers[value]; !ok {
+			return ErrLockNotHeld
+		}
+		delete(state.readers, value)
+	} else {
+		if state.writer != value {
+			return ErrLockNotHeld
+		}
+		state.writer, state.writerExpiredAt = "", time.Time{}
+	}
+	return goCacheSaveState(m.cache, m.key, state, state.prune(now), now)
+}
+
+// 刷新当前持有的读锁或者写锁, ttl为0时使用创建锁时的ttl
+func (m *GoCacheRWLock) Refresh(ctx context.Context, ttl time.Duration) error {
+	goCacheLockMu.Lock()
+	defer goCacheLockMu.Unlock()
+
+	if m.value == "" {
+		return ErrNotObtained
+	}
+	state, ok := goCacheState[goCacheRWState](m.cache, m.key)
+	if !ok {
+		return ErrNotObtained
+	}
+	if ttl > 0 {
+		m.ttl = ttl
+	}
+	now := time.Now()
+	state.prune(now)
+
+	holderExpiredAt := now.Add(m.ttl)
+	if m.read {
+		if _, ok := state.readers[m.value]; !ok {
+			return ErrNotObtained
+		}
+		state.readers[m.value] = holderExpiredAt
+	} else {
+		if state.writer != m.value {
+			return ErrNotObtained
+		}
+		state.writerExpiredAt = holderExpiredAt
+	}
+	return goCacheSaveState(m.cache, m.key, state, state.prune(now), now)
+}
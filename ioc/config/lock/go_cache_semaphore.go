@@ -0,0 +1,163 @@
+package lock
+
+import (
+	"context"
+	"time"
+
+	"github.com/bluele/gcache"
+)
+
+func (r *GoCacheLockProvider) NewSemaphore(key string, n int, ttl time.Duration) Semaphore {
+	return &GoCacheSemaphore{
+		cache: r.getCache(),
+		key:   key,
+		n:     n,
+		ttl:   ttl,
+		opt:   DefaultOptions(),
+	}
+}
+
+type goCacheSemaphoreState struct {
+	holders map[string]time.Time
+}
+
+// 清理过期的持有者, 返回最晚的过期时间
+func (s *goCacheSemaphoreState) prune(now time.Time) time.Time {
+	expiredAt := time.Time{}
+	for holder, holderExpiredAt := range s.holders {
+		if !holderExpiredAt.After(now) {
+			delete(s.holders, holder)
+			continue
+		}
+		if holderExpiredAt.After(expiredAt) {
+			expiredAt = holderExpiredAt
+		}
+	}
+	return expiredAt
+}
+
+type GoCacheSemaphore struct {
+	cache gcache.Cache
+	key   string
+	n     int
+	ttl   time.Duration
+	opt   *Options
+	value string
+}
+
+// 锁配置
+func (s *GoCacheSemaphore) WithOpt(opt *Options) Semaphore {
+	s.opt = opt
+	return s
+}
+
+// 获取许可
+func (s *GoCacheSemaphore) Acquire(ctx context.Context) error {
+	return s.acquire(ctx, true)
+}
+
+// 尝试获取许可
+func (s *GoCacheSemaphore) TryAcquire(ctx context.Context) error {
+	return s.acquire(ctx, false)
+}
+
+func (s *GoCacheSemaphore) acquire(ctx context.Context, retry bool) error {
+	value, _, err := s.opt.lockValue()
+	if err != nil {
+		return err
+	}
+
+	obtain := func(context.Context) (bool, error) {
+		return s.obtain(value)
+	}
+	if retry {
+		// make sure we don't retry forever
+		if _, ok := ctx.Deadline(); !ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithDeadline(ctx, time.Now().Add(s.ttl))
+			defer cancel()
+		}
+		err = obtainWithRetry(ctx, s.opt.getRetryStrategy(), 0, obtain)
+	} else {
+		err = obtainOnce(ctx, obtain)
+	}
+	if err != nil {
+		return err
+	}
+	s.value = value
+	return nil
+}
+
+func (s *GoCacheSemaphore) obtain(value string) (bool, error) {
+	goCacheLockMu.Lock()
+	defer goCacheLockMu.Unlock()
+
+	state, ok := goCacheState[goCacheSemaphoreState](s.cache, s.key)
+	if !ok {
+		return false, nil
+	}
+	now := time.Now()
+	expiredAt := state.prune(now)
+
+	if _, ok := state.holders[value]; !ok && len(state.holders) >= s.n {
+		return false, nil
+	}
+	if state.holders == nil {
+		state.holders = map[string]time.Time{}
+	}
+	holderExpiredAt := now.Add(s.ttl)
+	state.holders[value] = holderExpiredAt
+
+	if holderExpiredAt.After(expiredAt) {
+		expiredAt = holderExpiredAt
+	}
+	return true, goCacheSaveState(s.cache, s.key, state, expiredAt, now)
+}
+
+// 释放许可
+func (s *GoCacheSemaphore) Release(ctx context.Context) error {
+	goCacheLockMu.Lock()
+	defer goCacheLockMu.Unlock()
+
+	if s.value == "" {
+		return ErrLockNotHeld
+	}
+	value := s.value
+	s.value = ""
+
+	state, ok := goCacheState[goCacheSemaphoreState](s.cache, s.key)
+	if !ok {
+		return ErrLockNotHeld
+	}
+	now := time.Now()
+	state.prune(now)
+	if _, ok := state.holders[value]; !ok {
+		return ErrLockNotHeld
+	}
+	delete(state.holders, value)
+	return goCacheSaveState(s.cache, s.key, state, state.prune(now), now)
+}
+
+// 刷新许可, ttl为0时使用创建时的ttl
+func (s *GoCacheSemaphore) Refresh(ctx context.Context, ttl time.Duration) error {
+	goCacheLockMu.Lock()
+	defer goCacheLockMu.Unlock()
+
+	if s.value == "" {
+		return ErrNotObtained
+	}
+	state, ok := goCacheState[goCacheSemaphoreState](s.cache, s.key)
+	if !ok {
+		return ErrNotObtained
+	}
+	if ttl > 0 {
+		s.ttl = ttl
+	}
+	now := time.Now()
+	state.prune(now)
+	if _, ok := state.holders[s.value]; !ok {
+		return ErrNotObtained
+	}
+	state.holders[s.value] = now.Add(s.ttl)
+	return goCacheSaveState(s.cache, s.key, state, state.prune(now), now)
+}
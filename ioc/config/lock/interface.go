@@ -32,10 +32,42 @@ func Get() *config {
 	return obj.(*config)
 }
 
+// RWL 读写锁, 只有redis与go_cache支持, 其他提供方返回nil
+func RWL() RWLockFactory {
+	f, _ := L().(RWLockFactory)
+	return f
+}
+
+// SL 信号量, 只有redis与go_cache支持, 其他提供方返回nil
+func SL() SemaphoreFactory {
+	f, _ := L().(SemaphoreFactory)
+	return f
+}
+
+// RL 可重入锁, 只有redis与go_cache支持, 其他提供方返回nil
+func RL() ReentrantLockFactory {
+	f, _ := L().(ReentrantLockFactory)
+	return f
+}
+
 type LockFactory interface {
 	New(key string, ttl time.Duration) Lock
 }
 
+type RWLockFactory interface {
+	NewRWLock(key string, ttl time.Duration) RWLock
+}
+
+type SemaphoreFactory interface {
+	// 同一个key最多n个持有者
+	NewSemaphore(key string, n int, ttl time.Duration) Semaphore
+}
+
+type ReentrantLockFactory interface {
+	// 同一个owner可以重复获取锁, 获取几次就需要释放几次
+	NewReentrantLock(key, owner string, ttl time.Duration) Lock
+}
+
 type Lock interface {
 	// 锁配置
 	WithOpt(opt *Options) Lock
@@ -47,4 +79,41 @@ type Lock interface {
 	UnLock(ctx context.Context) error
 	// 刷新锁
 	Refresh(ctx context.Context, ttl time.Duration) error
+	// 本次获取锁的fencing token, 同一个key每次获取锁都单调递增, 未获取锁时为0,
+	// 下游写入时带上该token, 拒绝比已见过的token更小的写入, 避免锁过期后旧的持有者继续写入
+	FencingToken() int64
+}
+
+// RWLock 同时允许多个读者或者一个写者, 一个RWLock对象同时只持有读锁或者写锁中的一个
+type RWLock interface {
+	// 锁配置
+	WithOpt(opt *Options) RWLock
+	// 获取读锁
+	RLock(ctx context.Context) error
+	// 尝试获取读锁
+	TryRLock(ctx context.Context) error
+	// 释放读锁
+	RUnLock(ctx context.Context) error
+	// 获取写锁
+	Lock(ctx context.Context) error
+	// 尝试获取写锁
+	TryLock(ctx context.Context) error
+	// 释放写锁
+	UnLock(ctx context.Context) error
+	// 刷新当前持有的读锁或者写锁
+	Refresh(ctx context.Context, ttl time.Duration) error
+}
+
+// Semaphore 计数信号量, 用于限制多个实例间的并发数
+type Semaphore interface {
+	// 锁配置
+	WithOpt(opt *Options) Semaphore
+	// 获取许可
+	Acquire(ctx context.Context) error
+	// 尝试获取许可
+	TryAcquire(ctx context.Context) error
+	// 释放许可
+	Release(ctx context.Context) error
+	// 刷新许可
+	Refresh(ctx context.Context, ttl time.Duration) error
 }
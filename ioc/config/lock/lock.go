@@ -1,6 +1,9 @@
 package lock
 
 import (
+	"context"
+	"time"
+
 	"github.com/infraboard/mcube/v2/ioc"
 )
 
@@ -36,6 +39,30 @@ func (c *config) Init() error {
 		c.lf = NewRedisLockProvider()
 	case PROVIDER_GO_CACHE:
 		c.lf = NewGoCacheLockProvider()
+	case PROVIDER_ETCD:
+		if etcdClient() == nil {
+			return errEtcdNotRegistered
+		}
+		c.lf = NewEtcdLockProvider()
+	case PROVIDER_SQL:
+		p := NewSqlLockProvider()
+		if err := p.Migrate(context.Background()); err != nil {
+			return err
+		}
+		c.lf = p
 	}
 	return nil
 }
+
+// NewLock 使用配置的提供方创建锁, 返回值的类型只引用标准库,
+// 不引用lock包的对象(比如mcron)通过ioc查询该方法获取锁, 避免引用lock时初始化所有锁的提供方
+func (c *config) NewLock(key string, ttl time.Duration) interface {
+	TryLock(ctx context.Context) error
+	UnLock(ctx context.Context) error
+	Refresh(ctx context.Context, ttl time.Duration) error
+} {
+	if c.lf == nil {
+		return nil
+	}
+	return c.lf.New(key, ttl)
+}
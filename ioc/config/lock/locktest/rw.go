@@ -0,0 +1,181 @@
+package locktest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/infraboard/mcube/v2/ioc/config/lock"
+)
+
+func (s Suite) rwCases(f lock.RWLockFactory) []testCase {
+	return []testCase{
+		{"RWLock", func(t *testing.T, ctx context.Context, key string) {
+			r1, r2 := f.NewRWLock(key, s.TTL), f.NewRWLock(key, s.TTL)
+			w1, w2 := f.NewRWLock(key, s.TTL), f.NewRWLock(key, s.TTL)
+
+			// 多个读者共享
+			must(t, r1.TryRLock(ctx))
+			must(t, r2.TryRLock(ctx))
+			expect(t, w1.TryLock(ctx), lock.ErrNotObtained)
+			must(t, r1.RUnLock(ctx))
+			expect(t, w1.TryLock(ctx), lock.ErrNotObtained)
+			must(t, r2.RUnLock(ctx))
+
+			// 写者独占
+			must(t, w1.TryLock(ctx))
+			expect(t, r1.TryRLock(ctx), lock.ErrNotObtained)
+			expect(t, w2.TryLock(ctx), lock.ErrNotObtained)
+			expect(t, w1.RUnLock(ctx), lock.ErrLockNotHeld)
+			must(t, w1.UnLock(ctx))
+			expect(t, w1.UnLock(ctx), lock.ErrLockNotHeld)
+
+			must(t, r1.TryRLock(ctx))
+			expect(t, r1.UnLock(ctx), lock.ErrLockNotHeld)
+			must(t, r1.RUnLock(ctx))
+			expect(t, r1.RUnLock(ctx), lock.ErrLockNotHeld)
+		}},
+		{"RWLockExpiration", func(t *testing.T, ctx context.Context, key string) {
+			r1, r2, w := f.NewRWLock(key, s.TTL), f.NewRWLock(key, s.TTL), f.NewRWLock(key, s.TTL)
+			must(t, r1.TryRLock(ctx))
+			s.Advance(s.TTL / 2)
+			must(t, r2.TryRLock(ctx))
+
+			// 每个读者单独过期
+			s.Advance(s.TTL * 3 / 4)
+			expect(t, w.TryLock(ctx), lock.ErrNotObtained)
+			expect(t, r1.Refresh(ctx, s.TTL), lock.ErrNotObtained)
+			must(t, r2.Refresh(ctx, s.TTL))
+			s.Advance(s.TTL * 3 / 4)
+			expect(t, w.TryLock(ctx), lock.ErrNotObtained)
+
+			s.Advance(s.TTL / 2)
+			must(t, w.TryLock(ctx))
+			must(t, w.Refresh(ctx, s.TTL))
+			expect(t, r2.RUnLock(ctx), lock.ErrLockNotHeld)
+			must(t, w.UnLock(ctx))
+		}},
+		{"RWLockRetry", func(t *testing.T, ctx context.Context, key string) {
+			w := f.NewRWLock(key, s.TTL)
+			must(t, w.TryLock(ctx))
+			go func() {
+				time.Sleep(200 * time.Millisecond)
+				if err := w.UnLock(context.Background()); err != nil {
+					t.Errorf("unlock error, %s", err)
+				}
+			}()
+
+			r := f.NewRWLock(key, s.TTL).WithOpt(&lock.Options{RetryStrategy: lock.LinearBackoff(50 * time.Millisecond)})
+			lockCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+			must(t, r.RLock(lockCtx))
+			must(t, r.RUnLock(ctx))
+		}},
+	}
+}
+
+func (s Suite) semaphoreCases(f lock.SemaphoreFactory) []testCase {
+	return []testCase{
+		{"Semaphore", func(t *testing.T, ctx context.Context, key string) {
+			s1, s2, s3 := f.NewSemaphore(key, 2, s.TTL), f.NewSemaphore(key, 2, s.TTL), f.NewSemaphore(key, 2, s.TTL)
+			must(t, s1.TryAcquire(ctx))
+			must(t, s2.TryAcquire(ctx))
+			expect(t, s3.TryAcquire(ctx), lock.ErrNotObtained)
+			expect(t, s3.Release(ctx), lock.ErrLockNotHeld)
+
+			must(t, s1.Release(ctx))
+			expect(t, s1.Release(ctx), lock.ErrLockNotHeld)
+			must(t, s3.TryAcquire(ctx))
+			must(t, s2.Release(ctx))
+			must(t, s3.Release(ctx))
+		}},
+		{"SemaphoreExpiration", func(t *testing.T, ctx context.Context, key string) {
+			s1, s2, s3 := f.NewSemaphore(key, 2, s.TTL), f.NewSemaphore(key, 2, s.TTL), f.NewSemaphore(key, 2, s.TTL)
+			must(t, s1.TryAcquire(ctx))
+			s.Advance(s.TTL / 2)
+			must(t, s2.TryAcquire(ctx))
+
+			// s1过期后释放一个许可
+			s.Advance(s.TTL * 3 / 4)
+			expect(t, s1.Refresh(ctx, s.TTL), lock.ErrNotObtained)
+			must(t, s2.Refresh(ctx, s.TTL))
+			must(t, s3.TryAcquire(ctx))
+			expect(t, s1.TryAcquire(ctx), lock.ErrNotObtained)
+
+			must(t, s2.Release(ctx))
+			must(t, s3.Release(ctx))
+		}},
+		{"SemaphoreRetry", func(t *testing.T, ctx context.Context, key string) {
+			s1 := f.NewSemaphore(key, 1, s.TTL)
+			must(t, s1.TryAcquire(ctx))
+			go func() {
+				time.Sleep(200 * time.Millisecond)
+				if err := s1.Release(context.Background()); err != nil {
+					t.Errorf("release error, %s", err)
+				}
+			}()
+
+			s2 := f.NewSemaphore(key, 1, s.TTL).WithOpt(&lock.Options{RetryStrategy: lock.LinearBackoff(50 * time.Millisecond)})
+			lockCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+			must(t, s2.Acquire(lockCtx))
+			must(t, s2.Release(ctx))
+		}},
+	}
+}
+
+func (s Suite) reentrantCases(f lock.ReentrantLockFactory) []testCase {
+	return []testCase{
+		{"Reentrant", func(t *testing.T, ctx context.Context, key string) {
+			a1, a2, b := f.NewReentrantLock(key, "a", s.TTL), f.NewReentrantLock(key, "a", s.TTL), f.NewReentrantLock(key, "b", s.TTL)
+			must(t, a1.TryLock(ctx))
+			must(t, a2.TryLock(ctx))
+			if a1.FencingToken() == 0 || a1.FencingToken() != a2.FencingToken() {
+				t.Fatalf("expect same fencing token when reentrant, got %d and %d", a1.FencingToken(), a2.FencingToken())
+			}
+			expect(t, b.TryLock(ctx), lock.ErrNotObtained)
+			expect(t, b.UnLock(ctx), lock.ErrLockNotHeld)
+
+			// 获取几次就需要释放几次
+			must(t, a1.UnLock(ctx))
+			expect(t, b.TryLock(ctx), lock.ErrNotObtained)
+			must(t, a2.Refresh(ctx, s.TTL))
+			must(t, a2.UnLock(ctx))
+			expect(t, a1.UnLock(ctx), lock.ErrLockNotHeld)
+
+			must(t, b.TryLock(ctx))
+			if b.FencingToken() <= a1.FencingToken() {
+				t.Fatalf("expect fencing token greater than %d, got %d", a1.FencingToken(), b.FencingToken())
+			}
+			expect(t, a1.TryLock(ctx), lock.ErrNotObtained)
+			must(t, b.UnLock(ctx))
+		}},
+		{"ReentrantExpiration", func(t *testing.T, ctx context.Context, key string) {
+			a, b := f.NewReentrantLock(key, "a", s.TTL), f.NewReentrantLock(key, "b", s.TTL)
+			must(t, a.TryLock(ctx))
+			must(t, a.TryLock(ctx))
+			s.Advance(s.TTL * 2)
+
+			must(t, b.TryLock(ctx))
+			expect(t, a.Refresh(ctx, s.TTL), lock.ErrNotObtained)
+			expect(t, a.UnLock(ctx), lock.ErrLockNotHeld)
+			must(t, b.UnLock(ctx))
+		}},
+		{"ReentrantRetry", func(t *testing.T, ctx context.Context, key string) {
+			a := f.NewReentrantLock(key, "a", s.TTL)
+			must(t, a.TryLock(ctx))
+			go func() {
+				time.Sleep(200 * time.Millisecond)
+				if err := a.UnLock(context.Background()); err != nil {
+					t.Errorf("unlock error, %s", err)
+				}
+			}()
+
+			b := f.NewReentrantLock(key, "b", s.TTL).WithOpt(&lock.Options{RetryStrategy: lock.LinearBackoff(50 * time.Millisecond)})
+			lockCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+			must(t, b.Lock(lockCtx))
+			must(t, b.UnLock(ctx))
+		}},
+	}
+}
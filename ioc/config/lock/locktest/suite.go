@@ -0,0 +1,217 @@
+// locktest 锁实现的一致性测试, 保证不同的锁实现有相同的行为
+package locktest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/infraboard/mcube/v2/ioc/config/lock"
+)
+
+// Suite 一致性测试
+//
+//	locktest.Suite{
+//		Factory: lock.NewGoCacheLockProviderWithCache(gcache.New(100).Build()),
+//		Advance: time.Sleep,
+//	}.Run(t)
+type Suite struct {
+	Factory lock.LockFactory
+	// 让时间前进d, 用于测试过期, 真实环境为time.Sleep, miniredis为FastForward
+	Advance func(d time.Duration)
+	// 锁的过期时间, 默认2秒, etcd租约的最小单位为秒
+	TTL time.Duration
+}
+
+func (s Suite) Run(t *testing.T) {
+	if s.TTL == 0 {
+		s.TTL = 2 * time.Second
+	}
+
+	cases := []testCase{
+		{"TryLock", s.testTryLock},
+		{"UnLockNotHeld", s.testUnLockNotHeld},
+		{"SameToken", s.testSameToken},
+		{"Refresh", s.testRefresh},
+		{"Expiration", s.testExpiration},
+		{"LockRetry", s.testLockRetry},
+		{"FencingToken", s.testFencingToken},
+		{"Watchdog", s.testWatchdog},
+	}
+	// 读写锁, 信号量与可重入锁只在提供方支持时测试
+	if f, ok := s.Factory.(lock.RWLockFactory); ok {
+		cases = append(cases, s.rwCases(f)...)
+	}
+	if f, ok := s.Factory.(lock.SemaphoreFactory); ok {
+		cases = append(cases, s.semaphoreCases(f)...)
+	}
+	if f, ok := s.Factory.(lock.ReentrantLockFactory); ok {
+		cases = append(cases, s.reentrantCases(f)...)
+	}
+	for i, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			key := fmt.Sprintf("locktest.%d.%d", time.Now().UnixNano(), i)
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			tc.fn(t, ctx, key)
+		})
+	}
+}
+
+type testCase struct {
+	name string
+	fn   func(t *testing.T, ctx context.Context, key string)
+}
+
+func (s Suite) testTryLock(t *testing.T, ctx context.Context, key string) {
+	l1, l2 := s.Factory.New(key, s.TTL), s.Factory.New(key, s.TTL)
+	must(t, l1.TryLock(ctx))
+	expect(t, l2.TryLock(ctx), lock.ErrNotObtained)
+	must(t, l1.UnLock(ctx))
+	must(t, l2.TryLock(ctx))
+	must(t, l2.UnLock(ctx))
+}
+
+func (s Suite) testUnLockNotHeld(t *testing.T, ctx context.Context, key string) {
+	l1, l2 := s.Factory.New(key, s.TTL), s.Factory.New(key, s.TTL)
+	expect(t, l1.UnLock(ctx), lock.ErrLockNotHeld)
+	must(t, l1.TryLock(ctx))
+	expect(t, l2.UnLock(ctx), lock.ErrLockNotHeld)
+	expect(t, l2.Refresh(ctx, s.TTL), lock.ErrNotObtained)
+	must(t, l1.UnLock(ctx))
+	expect(t, l1.UnLock(ctx), lock.ErrLockNotHeld)
+}
+
+func (s Suite) testSameToken(t *testing.T, ctx context.Context, key string) {
+	l1 := s.Factory.New(key, s.TTL).WithOpt(&lock.Options{Token: "token-a"})
+	l2 := s.Factory.New(key, s.TTL).WithOpt(&lock.Options{Token: "token-a"})
+	l3 := s.Factory.New(key, s.TTL).WithOpt(&lock.Options{Token: "token-b"})
+	must(t, l1.TryLock(ctx))
+	must(t, l2.TryLock(ctx))
+	expect(t, l3.TryLock(ctx), lock.ErrNotObtained)
+	must(t, l2.UnLock(ctx))
+	must(t, l3.TryLock(ctx))
+	must(t, l3.UnLock(ctx))
+}
+
+func (s Suite) testRefresh(t *testing.T, ctx context.Context, key string) {
+	l1, l2 := s.Factory.New(key, s.TTL), s.Factory.New(key, s.TTL)
+	expect(t, l1.Refresh(ctx, s.TTL), lock.ErrNotObtained)
+	must(t, l1.TryLock(ctx))
+
+	s.Advance(s.TTL / 2)
+	must(t, l1.Refresh(ctx, s.TTL))
+	// 刷新后原有的过期时间已经过去, 锁仍然被持有
+	s.Advance(s.TTL * 3 / 4)
+	expect(t, l2.TryLock(ctx), lock.ErrNotObtained)
+
+	// 使用新的ttl刷新
+	must(t, l1.Refresh(ctx, s.TTL*2))
+	s.Advance(s.TTL * 3 / 2)
+	expect(t, l2.TryLock(ctx), lock.ErrNotObtained)
+	must(t, l1.UnLock(ctx))
+}
+
+func (s Suite) testExpiration(t *testing.T, ctx context.Context, key string) {
+	l1, l2 := s.Factory.New(key, s.TTL), s.Factory.New(key, s.TTL)
+	must(t, l1.TryLock(ctx))
+	s.Advance(s.TTL * 2)
+
+	// 过期后其他持有者可以获取, 原持有者无法刷新和释放
+	must(t, l2.TryLock(ctx))
+	expect(t, l1.Refresh(ctx, s.TTL), lock.ErrNotObtained)
+	expect(t, l1.UnLock(ctx), lock.ErrLockNotHeld)
+	must(t, l2.UnLock(ctx))
+}
+
+func (s Suite) testLockRetry(t *testing.T, ctx context.Context, key string) {
+	l1 := s.Factory.New(key, s.TTL)
+	must(t, l1.TryLock(ctx))
+
+	noRetry := s.Factory.New(key, s.TTL).WithOpt(&lock.Options{RetryStrategy: lock.NoRetry()})
+	expect(t, noRetry.Lock(ctx), lock.ErrNotObtained)
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		if err := l1.UnLock(context.Background()); err != nil {
+			t.Errorf("unlock error, %s", err)
+		}
+	}()
+
+	l2 := s.Factory.New(key, s.TTL).WithOpt(&lock.Options{RetryStrategy: lock.LinearBackoff(50 * time.Millisecond)})
+	lockCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	must(t, l2.Lock(lockCtx))
+	must(t, l2.UnLock(ctx))
+}
+
+func (s Suite) testFencingToken(t *testing.T, ctx context.Context, key string) {
+	l1, l2 := s.Factory.New(key, s.TTL), s.Factory.New(key, s.TTL)
+	if l1.FencingToken() != 0 {
+		t.Fatalf("expect fencing token 0 before lock, got %d", l1.FencingToken())
+	}
+
+	var last int64
+	for _, l := range []lock.Lock{l1, l2, l1} {
+		must(t, l.TryLock(ctx))
+		if l.FencingToken() <= last {
+			t.Fatalf("expect fencing token greater than %d, got %d", last, l.FencingToken())
+		}
+		last = l.FencingToken()
+		must(t, l.UnLock(ctx))
+	}
+
+	// 过期后重新获取的token同样递增
+	must(t, l1.TryLock(ctx))
+	last = l1.FencingToken()
+	s.Advance(s.TTL * 2)
+	must(t, l2.TryLock(ctx))
+	if l2.FencingToken() <= last {
+		t.Fatalf("expect fencing token greater than %d, got %d", last, l2.FencingToken())
+	}
+	must(t, l2.UnLock(ctx))
+}
+
+func (s Suite) testWatchdog(t *testing.T, ctx context.Context, key string) {
+	w := lock.NewWatchdog(s.Factory.New(key, s.TTL), s.TTL)
+	must(t, w.TryLock(ctx))
+	if w.FencingToken() == 0 {
+		t.Fatal("expect fencing token")
+	}
+
+	// 超过ttl后锁仍然被持有
+	time.Sleep(s.TTL * 3 / 2)
+	expect(t, s.Factory.New(key, s.TTL).TryLock(ctx), lock.ErrNotObtained)
+	select {
+	case <-w.Lost():
+		t.Fatal("lock should not be lost")
+	default:
+	}
+	must(t, w.Context().Err())
+
+	must(t, w.UnLock(ctx))
+	if w.Context().Err() == nil {
+		t.Fatal("expect context canceled after unlock")
+	}
+	select {
+	case <-w.Lost():
+		t.Fatal("lost should not be closed after unlock")
+	default:
+	}
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func expect(t *testing.T, err, target error) {
+	t.Helper()
+	if !errors.Is(err, target) {
+		t.Fatalf("expect %v, got %v", target, err)
+	}
+}
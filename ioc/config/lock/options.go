@@ -1,6 +1,11 @@
 package lock
 
-import "time"
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"time"
+)
 
 func DefaultOptions() *Options {
 	return &Options{
@@ -50,3 +55,22 @@ func (o *Options) SetTimeout(t time.Duration) *Options {
 	o.Timeout = t
 	return o
 }
+
+// 锁的值由token与metadata组成, 未指定token时随机生成
+func (o *Options) lockValue() (value string, tokenLen int, err error) {
+	token := o.getToken()
+	if token == "" {
+		if token, err = randomToken(); err != nil {
+			return "", 0, err
+		}
+	}
+	return token + o.getMetadata(), len(token), nil
+}
+
+func randomToken() (string, error) {
+	tmp := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, tmp); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(tmp), nil
+}
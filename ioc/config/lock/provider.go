@@ -10,4 +10,8 @@ type PROVIDER string
 const (
 	PROVIDER_REDIS    = redis.AppName
 	PROVIDER_GO_CACHE = gocache.AppName
+	// 需要同时引入ioc/config/etcd
+	PROVIDER_ETCD = "etcd"
+	// 使用datasource配置的数据库, 需要同时引入ioc/config/datasource
+	PROVIDER_SQL = "datasource"
 )
@@ -0,0 +1,42 @@
+package lock
+
+import (
+	"strings"
+	"testing"
+)
+
+// 按照Redis集群规范计算slot: 有hash tag时只计算tag, CRC16(XMODEM) mod 16384
+func clusterSlot(key string) uint16 {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	var crc uint16
+	for i := 0; i < len(key); i++ {
+		crc ^= uint16(key[i]) << 8
+		for range 8 {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc % 16384
+}
+
+func TestRedisFencingKeySlot(t *testing.T) {
+	for _, key := range []string{"order", "{order}.lock", "a{b", "a}b", "a{}b", "job:{}", "mcube.cron.sync"} {
+		lockKey := redisLockKey(key)
+		fencingKey := redisFencingKey(lockKey)
+		if clusterSlot(lockKey) != clusterSlot(fencingKey) {
+			t.Fatalf("key %s: lock key %s and fencing key %s in different slots", key, lockKey, fencingKey)
+		}
+	}
+
+	// 不包含}的key保持不变, 与之前版本的锁互斥
+	if k := redisLockKey("order"); k != "order" {
+		t.Fatalf("expect lock key unchanged, got %s", k)
+	}
+}
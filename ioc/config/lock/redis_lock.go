@@ -2,14 +2,9 @@ package lock
 
 import (
 	"context"
-	"crypto/rand"
 	_ "embed"
-	"encoding/base64"
-	"errors"
-	"io"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	ioc_redis "github.com/infraboard/mcube/v2/ioc/config/redis"
@@ -28,6 +23,12 @@ var luaPTTLScript string
 //go:embed redis_lua/obtain.lua
 var luaObtainScript string
 
+const (
+	// fencing token计数key的后缀, 计数key使用锁的key作为hash tag, 比如{order}:fencing,
+	// Redis集群模式下与锁的key在同一个slot
+	REDIS_FENCING_KEY_SUFFIX = ":fencing"
+)
+
 var (
 	luaRefresh = redis.NewScript(luaRefreshScript)
 	luaRelease = redis.NewScript(luaReleaseScript)
@@ -39,13 +40,26 @@ func NewRedisLockProvider() *RedisLockProvider {
 	return &RedisLockProvider{}
 }
 
+// NewRedisLockProviderWithClient 使用指定的Redis客户端, 而不是ioc中的Redis
+func NewRedisLockProviderWithClient(client redis.Scripter) *RedisLockProvider {
+	return &RedisLockProvider{client: client}
+}
+
 type RedisLockProvider struct {
+	client redis.Scripter
+}
+
+func (r *RedisLockProvider) getClient() redis.Scripter {
+	if r.client != nil {
+		return r.client
+	}
+	return ioc_redis.Client()
 }
 
 func (r *RedisLockProvider) New(key string, ttl time.Duration) Lock {
 	return &RedisLock{
-		client: ioc_redis.Client(),
-		key:    key,
+		client: r.getClient(),
+		key:    redisLockKey(key),
 		ttl:    ttl,
 		opt:    DefaultOptions(),
 	}
@@ -59,8 +73,7 @@ type RedisLock struct {
 	opt      *Options
 	value    string
 	tokenLen int
-	tmp      []byte
-	tmpMu    sync.Mutex
+	fencing  int64
 }
 
 func (l *RedisLock) getTimeout() time.Duration {
@@ -82,77 +95,24 @@ func (l *RedisLock) WithOpt(opt *Options) Lock {
 
 // 获取锁
 func (l *RedisLock) Lock(ctx context.Context) error {
-	token := l.opt.getToken()
-
-	// Create a random token
-	if token == "" {
-		var err error
-		if token, err = l.randomToken(); err != nil {
-			return err
-		}
-	}
-
-	value := token + l.opt.getMetadata()
-	retry := l.opt.getRetryStrategy()
-	timeout := l.getTimeout()
-
-	var ticker *time.Ticker
-	for {
-		obtainCtx := ctx
-		cancel := func() {}
-		if timeout > 0 {
-			obtainCtx, cancel = context.WithTimeout(ctx, timeout)
-		}
-
-		ok, err := l.obtain(obtainCtx, l.key, value, len(token))
-		cancel()
-		if err != nil {
-			if ctxErr := ctx.Err(); ctxErr != nil {
-				return ctxErr
-			}
-			if timeout > 0 && errors.Is(err, context.DeadlineExceeded) {
-				// Per-attempt timeout should not abort retry loop.
-			} else {
-				return err
-			}
-		} else if ok {
-			return nil
-		}
-
-		backoff := retry.NextBackoff()
-		if backoff < 1 {
-			return ErrNotObtained
-		}
-
-		if ticker == nil {
-			ticker = time.NewTicker(backoff)
-			defer ticker.Stop()
-		} else {
-			ticker.Reset(backoff)
-		}
-
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-ticker.C:
-		}
+	value, tokenLen, err := l.opt.lockValue()
+	if err != nil {
+		return err
 	}
+
+	return obtainWithRetry(ctx, l.opt.getRetryStrategy(), l.getTimeout(), func(ctx context.Context) (bool, error) {
+		return l.obtain(ctx, l.key, value, tokenLen)
+	})
 }
 
 // 获取锁
 func (l *RedisLock) TryLock(ctx context.Context) error {
-	token := l.opt.getToken()
-
-	// Create a random token
-	if token == "" {
-		var err error
-		if token, err = l.randomToken(); err != nil {
-			return err
-		}
+	value, tokenLen, err := l.opt.lockValue()
+	if err != nil {
+		return err
 	}
 
-	value := token + l.opt.getMetadata()
-	ok, err := l.obtain(ctx, l.key, value, len(token))
+	ok, err := l.obtain(ctx, l.key, value, tokenLen)
 	if err != nil {
 		return err
 	}
@@ -165,28 +125,47 @@ func (l *RedisLock) TryLock(ctx context.Context) error {
 }
 
 func (c *RedisLock) obtain(ctx context.Context, key, value string, tokenLen int) (bool, error) {
-	_, err := luaObtain.Run(ctx, c.client, []string{key}, value, tokenLen, c.TTLValueString()).Result()
+	res, err := luaObtain.Run(ctx, c.client, []string{key, c.fencingKey()}, value, tokenLen, c.TTLValueString()).Result()
 	if err == redis.Nil {
 		return false, nil
 	} else if err != nil {
 		return false, err
 	}
 	c.value = value
+	c.tokenLen = tokenLen
+	c.fencing, _ = res.(int64)
 	return true, nil
 }
 
-func (c *RedisLock) randomToken() (string, error) {
-	c.tmpMu.Lock()
-	defer c.tmpMu.Unlock()
+// 保存fencing token计数的key, 该key不会过期, 作为脚本的最后一个key传入
+func (c *RedisLock) fencingKey() string {
+	return redisFencingKey(c.key)
+}
+
+// 锁的key, 包含}但没有hash tag时无法构造同一个slot的计数key, 整个key作为hash tag
+func redisLockKey(key string) string {
+	if hasHashTag(key) || !strings.Contains(key, "}") {
+		return key
+	}
+	return "{" + key + "}"
+}
 
-	if len(c.tmp) == 0 {
-		c.tmp = make([]byte, 16)
+// fencing token计数的key, 锁的key有hash tag时沿用, 否则使用整个key作为hash tag,
+// Redis集群模式下与锁的key计算出相同的slot, 避免CROSSSLOT错误
+func redisFencingKey(lockKey string) string {
+	if hasHashTag(lockKey) {
+		return lockKey + REDIS_FENCING_KEY_SUFFIX
 	}
+	return "{" + lockKey + "}" + REDIS_FENCING_KEY_SUFFIX
+}
 
-	if _, err := io.ReadFull(rand.Reader, c.tmp); err != nil {
-		return "", err
+// 第一个{与之后第一个}之间不为空时, Redis集群只使用其中的内容计算slot
+func hasHashTag(key string) bool {
+	start := strings.IndexByte(key, '{')
+	if start < 0 {
+		return false
 	}
-	return base64.RawURLEncoding.EncodeToString(c.tmp), nil
+	return strings.IndexByte(key[start+1:], '}') > 0
 }
 
 // 释放锁
@@ -209,15 +188,21 @@ func (l *RedisLock) UnLock(ctx context.Context) error {
 	return nil
 }
 
-// 刷新锁
+// 刷新锁, ttl为0时使用创建锁时的ttl
 func (l *RedisLock) Refresh(ctx context.Context, ttl time.Duration) error {
-	status, err := luaRefresh.Run(ctx, l.client, []string{l.key}, l.value, l.TTLValueString()).Result()
-	if err != nil {
-		return err
-	} else if status == int64(1) {
-		return nil
+	if ttl > 0 {
+		l.ttl = ttl
+	}
+	_, err := luaRefresh.Run(ctx, l.client, []string{l.key}, l.value, l.TTLValueString()).Result()
+	if err == redis.Nil {
+		return ErrNotObtained
 	}
-	return ErrNotObtained
+	return err
+}
+
+// FencingToken returns the fencing token of the current acquisition.
+func (l *RedisLock) FencingToken() int64 {
+	return l.fencing
 }
 
 // Key returns the redis key used by the lock.
@@ -1,10 +1,14 @@
--- obtain.lua: arguments => [value, tokenLen, ttl]
+-- obtain.lua: keys => [lockKeys..., fencingKey], arguments => [value, tokenLen, ttl]
 -- Obtain.lua try to set provided keys's with value and ttl if they do not exists.
 -- Keys can be overriden if they already exists and the correct value+tokenLen is provided. 
 
+-- The last key is the fencing token counter, the others are the lock keys.
+local fencingKey = KEYS[#KEYS]
+local lockKeys = {unpack(KEYS, 1, #KEYS - 1)}
+
 local function pexpire(ttl)
 	-- Update keys ttls.
-	for _, key in ipairs(KEYS) do
+	for _, key in ipairs(lockKeys) do
 		redis.call("pexpire", key, ttl)
 	end
 end
@@ -14,7 +18,7 @@ end
 local function canOverrideKeys() 
 	local offset = tonumber(ARGV[2])
 
-	for _, key in ipairs(KEYS) do
+	for _, key in ipairs(lockKeys) do
 		if redis.call("getrange", key, 0, offset-1) ~= string.sub(ARGV[1], 1, offset) then
 			return false
 		end
@@ -24,7 +28,7 @@ end
 
 -- Prepare mset arguments.
 local setArgs = {}
-for _, key in ipairs(KEYS) do
+for _, key in ipairs(lockKeys) do
 	table.insert(setArgs, key)
 	table.insert(setArgs, ARGV[1])
 end
@@ -37,4 +41,5 @@ if redis.call("msetnx", unpack(setArgs)) ~= 1 then
 end
 
 pexpire(ARGV[3])
-return redis.status_reply("OK")
\ No newline at end of file
+-- Increase and return the fencing token of this acquisition.
+return redis.call("incr", fencingKey)
\ No newline at end of file
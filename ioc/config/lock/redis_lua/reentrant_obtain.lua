@@ -0,0 +1,14 @@
+-- reentrant_obtain.lua: keys => [lockKey, fencingKey], arguments => [owner, ttl, metadata]
+-- reentrant_obtain.lua obtains the lock if it is free or already held by the same owner,
+-- the hold count is increased on each obtain and the fencing token is returned.
+
+local owner = redis.call("hget", KEYS[1], "owner")
+if owner and owner ~= ARGV[1] then
+	return false
+end
+
+if redis.call("hincrby", KEYS[1], "count", 1) == 1 then
+	redis.call("hset", KEYS[1], "owner", ARGV[1], "metadata", ARGV[3], "fencing", redis.call("incr", KEYS[2]))
+end
+redis.call("pexpire", KEYS[1], ARGV[2])
+return tonumber(redis.call("hget", KEYS[1], "fencing"))
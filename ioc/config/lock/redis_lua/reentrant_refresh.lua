@@ -0,0 +1,8 @@
+-- reentrant_refresh.lua: arguments => [owner, ttl]
+-- reentrant_refresh.lua refreshes the lock ttl if it is held by owner.
+
+if redis.call("hget", KEYS[1], "owner") ~= ARGV[1] then
+	return false
+end
+redis.call("pexpire", KEYS[1], ARGV[2])
+return redis.status_reply("OK")
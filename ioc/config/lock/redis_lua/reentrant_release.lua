@@ -0,0 +1,14 @@
+-- reentrant_release.lua: arguments => [owner]
+-- reentrant_release.lua decreases the hold count of owner, the lock is deleted when the count reaches zero.
+-- Returns the remaining hold count.
+
+if redis.call("hget", KEYS[1], "owner") ~= ARGV[1] then
+	return false
+end
+
+local count = redis.call("hincrby", KEYS[1], "count", -1)
+if count <= 0 then
+	redis.call("del", KEYS[1])
+	return 0
+end
+return count
@@ -0,0 +1,27 @@
+-- rw_refresh.lua: arguments => [field, value, ttl]
+-- rw_refresh.lua refreshes the writer if its value matches, or the reader if it is not expired.
+
+local time = redis.call("time")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local ttl = tonumber(ARGV[3])
+
+local v = redis.call("hget", KEYS[1], ARGV[1])
+if not v then
+	return false
+end
+if ARGV[1] == "w" then
+	if v ~= ARGV[2] then
+		return false
+	end
+	redis.call("pexpire", KEYS[1], ttl)
+	return redis.status_reply("OK")
+end
+
+if tonumber(v) <= now then
+	return false
+end
+redis.call("hset", KEYS[1], ARGV[1], now + ttl)
+if redis.call("pttl", KEYS[1]) < ttl then
+	redis.call("pexpire", KEYS[1], ttl)
+end
+return redis.status_reply("OK")
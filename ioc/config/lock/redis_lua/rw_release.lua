@@ -0,0 +1,20 @@
+-- rw_release.lua: arguments => [field, value]
+-- rw_release.lua deletes the writer if its value matches, or the reader if it is not expired.
+
+local time = redis.call("time")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local v = redis.call("hget", KEYS[1], ARGV[1])
+if not v then
+	return false
+end
+if ARGV[1] == "w" then
+	if v ~= ARGV[2] then
+		return false
+	end
+elseif tonumber(v) <= now then
+	return false
+end
+
+redis.call("hdel", KEYS[1], ARGV[1])
+return redis.status_reply("OK")
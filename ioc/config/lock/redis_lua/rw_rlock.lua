@@ -0,0 +1,26 @@
+-- rw_rlock.lua: arguments => [value, ttl]
+-- rw_rlock.lua obtains a read lock if there is no writer, readers are stored in a hash
+-- as "r:{value}" => expire time in milliseconds, the writer is stored as "w" => value.
+
+local time = redis.call("time")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+if redis.call("hexists", KEYS[1], "w") == 1 then
+	return false
+end
+
+-- Remove expired readers and find the latest expire time.
+local expireAt = now + tonumber(ARGV[2])
+local fields = redis.call("hgetall", KEYS[1])
+for i = 1, #fields, 2 do
+	local readerExpireAt = tonumber(fields[i + 1])
+	if readerExpireAt <= now then
+		redis.call("hdel", KEYS[1], fields[i])
+	elseif readerExpireAt > expireAt then
+		expireAt = readerExpireAt
+	end
+end
+
+redis.call("hset", KEYS[1], "r:" .. ARGV[1], now + tonumber(ARGV[2]))
+redis.call("pexpire", KEYS[1], expireAt - now)
+return redis.status_reply("OK")
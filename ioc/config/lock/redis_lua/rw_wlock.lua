@@ -0,0 +1,22 @@
+-- rw_wlock.lua: arguments => [value, ttl]
+-- rw_wlock.lua obtains the write lock if there is no other writer and no alive reader.
+
+local time = redis.call("time")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local fields = redis.call("hgetall", KEYS[1])
+for i = 1, #fields, 2 do
+	if fields[i] == "w" then
+		if fields[i + 1] ~= ARGV[1] then
+			return false
+		end
+	elseif tonumber(fields[i + 1]) > now then
+		return false
+	else
+		redis.call("hdel", KEYS[1], fields[i])
+	end
+end
+
+redis.call("hset", KEYS[1], "w", ARGV[1])
+redis.call("pexpire", KEYS[1], ARGV[2])
+return redis.status_reply("OK")
@@ -0,0 +1,20 @@
+-- semaphore_acquire.lua: arguments => [value, limit, ttl]
+-- semaphore_acquire.lua adds value to a sorted set scored by its expire time in milliseconds
+-- if there are less than limit alive holders.
+
+local time = redis.call("time")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local ttl = tonumber(ARGV[3])
+
+-- Remove expired holders.
+redis.call("zremrangebyscore", KEYS[1], "-inf", now)
+
+if not redis.call("zscore", KEYS[1], ARGV[1]) and redis.call("zcard", KEYS[1]) >= tonumber(ARGV[2]) then
+	return false
+end
+
+redis.call("zadd", KEYS[1], now + ttl, ARGV[1])
+if redis.call("pttl", KEYS[1]) < ttl then
+	redis.call("pexpire", KEYS[1], ttl)
+end
+return redis.status_reply("OK")
@@ -0,0 +1,17 @@
+-- semaphore_refresh.lua: arguments => [value, ttl]
+-- semaphore_refresh.lua updates the expire time of value if it is not expired.
+
+local time = redis.call("time")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local ttl = tonumber(ARGV[2])
+
+local expireAt = redis.call("zscore", KEYS[1], ARGV[1])
+if not expireAt or tonumber(expireAt) <= now then
+	return false
+end
+
+redis.call("zadd", KEYS[1], now + ttl, ARGV[1])
+if redis.call("pttl", KEYS[1]) < ttl then
+	redis.call("pexpire", KEYS[1], ttl)
+end
+return redis.status_reply("OK")
@@ -0,0 +1,15 @@
+-- semaphore_release.lua: arguments => [value]
+-- semaphore_release.lua removes value from the holders if it is not expired.
+
+local time = redis.call("time")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local expireAt = redis.call("zscore", KEYS[1], ARGV[1])
+if not expireAt then
+	return false
+end
+redis.call("zrem", KEYS[1], ARGV[1])
+if tonumber(expireAt) <= now then
+	return false
+end
+return redis.status_reply("OK")
@@ -0,0 +1,105 @@
+package lock
+
+import (
+	"context"
+	_ "embed"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+//go:embed redis_lua/reentrant_obtain.lua
+var luaReentrantObtainScript string
+
+//go:embed redis_lua/reentrant_release.lua
+var luaReentrantReleaseScript string
+
+//go:embed redis_lua/reentrant_refresh.lua
+var luaReentrantRefreshScript string
+
+var (
+	luaReentrantObtain  = redis.NewScript(luaReentrantObtainScript)
+	luaReentrantRelease = redis.NewScript(luaReentrantReleaseScript)
+	luaReentrantRefresh = redis.NewScript(luaReentrantRefreshScript)
+)
+
+func (r *RedisLockProvider) NewReentrantLock(key, owner string, ttl time.Duration) Lock {
+	return &RedisReentrantLock{
+		client: r.getClient(),
+		key:    redisLockKey(key),
+		owner:  owner,
+		ttl:    ttl,
+		opt:    DefaultOptions(),
+	}
+}
+
+// RedisReentrantLock 锁保存在一个hash中, 记录持有者与持有次数, Options中的Token不生效, 使用owner作为Token
+type RedisReentrantLock struct {
+	client  redis.Scripter
+	key     string
+	owner   string
+	ttl     time.Duration
+	opt     *Options
+	fencing int64
+}
+
+func (l *RedisReentrantLock) getTimeout() time.Duration {
+	if l.opt.Timeout > 0 {
+		return l.opt.Timeout
+	}
+	return l.ttl * 3
+}
+
+// 锁配置
+func (l *RedisReentrantLock) WithOpt(opt *Options) Lock {
+	l.opt = opt
+	return l
+}
+
+// 获取锁
+func (l *RedisReentrantLock) Lock(ctx context.Context) error {
+	return obtainWithRetry(ctx, l.opt.getRetryStrategy(), l.getTimeout(), l.obtain)
+}
+
+// TryLock
+func (l *RedisReentrantLock) TryLock(ctx context.Context) error {
+	return obtainOnce(ctx, l.obtain)
+}
+
+func (l *RedisReentrantLock) obtain(ctx context.Context) (bool, error) {
+	res, err := luaReentrantObtain.Run(ctx, l.client, []string{l.key, redisFencingKey(l.key)},
+		l.owner, formatMillis(l.ttl), l.opt.getMetadata()).Result()
+	if err == redis.Nil {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	l.fencing, _ = res.(int64)
+	return true, nil
+}
+
+// 释放锁, 持有次数减为0时删除锁
+func (l *RedisReentrantLock) UnLock(ctx context.Context) error {
+	_, err := luaReentrantRelease.Run(ctx, l.client, []string{l.key}, l.owner).Result()
+	if err == redis.Nil {
+		return ErrLockNotHeld
+	}
+	return err
+}
+
+// 刷新锁, ttl为0时使用创建锁时的ttl
+func (l *RedisReentrantLock) Refresh(ctx context.Context, ttl time.Duration) error {
+	if ttl > 0 {
+		l.ttl = ttl
+	}
+	_, err := luaReentrantRefresh.Run(ctx, l.client, []string{l.key}, l.owner, formatMillis(l.ttl)).Result()
+	if err == redis.Nil {
+		return ErrNotObtained
+	}
+	return err
+}
+
+// FencingToken 第一次获取锁时的fencing token, 重入时不变
+func (l *RedisReentrantLock) FencingToken() int64 {
+	return l.fencing
+}
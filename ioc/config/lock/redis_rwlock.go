@@ -0,0 +1,161 @@
+package lock
+
+import (
+	"context"
+	_ "embed"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+//go:embed redis_lua/rw_rlock.lua
+var luaRWRLockScript string
+
+//go:embed redis_lua/rw_wlock.lua
+var luaRWWLockScript string
+
+//go:embed redis_lua/rw_release.lua
+var luaRWReleaseScript string
+
+//go:embed redis_lua/rw_refresh.lua
+var luaRWRefreshScript string
+
+var (
+	luaRWRLock   = redis.NewScript(luaRWRLockScript)
+	luaRWWLock   = redis.NewScript(luaRWWLockScript)
+	luaRWRelease = redis.NewScript(luaRWReleaseScript)
+	luaRWRefresh = redis.NewScript(luaRWRefreshScript)
+)
+
+const (
+	// 读写锁hash中写者的字段, 读者的字段为r:{value}
+	rwWriterField       = "w"
+	rwReaderFieldPrefix = "r:"
+)
+
+func (r *RedisLockProvider) NewRWLock(key string, ttl time.Duration) RWLock {
+	return &RedisRWLock{
+		client: r.getClient(),
+		key:    key,
+		ttl:    ttl,
+		opt:    DefaultOptions(),
+	}
+}
+
+// RedisRWLock 读写锁保存在一个hash中, 每个读者单独记录过期时间, 读写锁的脚本依赖TIME命令, 需要Redis 5.0及以上版本
+type RedisRWLock struct {
+	client redis.Scripter
+	key    string
+	ttl    time.Duration
+	opt    *Options
+	// 当前持有的字段
+	field string
+	value string
+}
+
+func (l *RedisRWLock) getTimeout() time.Duration {
+	if l.opt.Timeout > 0 {
+		return l.opt.Timeout
+	}
+	return l.ttl * 3
+}
+
+// 锁配置
+func (l *RedisRWLock) WithOpt(opt *Options) RWLock {
+	l.opt = opt
+	return l
+}
+
+// 获取读锁
+func (l *RedisRWLock) RLock(ctx context.Context) error {
+	return l.acquire(ctx, luaRWRLock, true, true)
+}
+
+// 尝试获取读锁
+func (l *RedisRWLock) TryRLock(ctx context.Context) error {
+	return l.acquire(ctx, luaRWRLock, true, false)
+}
+
+// 释放读锁
+func (l *RedisRWLock) RUnLock(ctx context.Context) error {
+	return l.release(ctx, true)
+}
+
+// 获取写锁
+func (l *RedisRWLock) Lock(ctx context.Context) error {
+	return l.acquire(ctx, luaRWWLock, false, true)
+}
+
+// 尝试获取写锁
+func (l *RedisRWLock) TryLock(ctx context.Context) error {
+	return l.acquire(ctx, luaRWWLock, false, false)
+}
+
+// 释放写锁
+func (l *RedisRWLock) UnLock(ctx context.Context) error {
+	return l.release(ctx, false)
+}
+
+func (l *RedisRWLock) acquire(ctx context.Context, script *redis.Script, read, retry bool) error {
+	value, _, err := l.opt.lockValue()
+	if err != nil {
+		return err
+	}
+
+	obtain := func(ctx context.Context) (bool, error) {
+		_, err := script.Run(ctx, l.client, []string{l.key}, value, formatMillis(l.ttl)).Result()
+		if err == redis.Nil {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	if retry {
+		err = obtainWithRetry(ctx, l.opt.getRetryStrategy(), l.getTimeout(), obtain)
+	} else {
+		err = obtainOnce(ctx, obtain)
+	}
+	if err != nil {
+		return err
+	}
+
+	l.value = value
+	l.field = rwWriterField
+	if read {
+		l.field = rwReaderFieldPrefix + value
+	}
+	return nil
+}
+
+func (l *RedisRWLock) release(ctx context.Context, read bool) error {
+	if l.field == "" || read != (l.field != rwWriterField) {
+		return ErrLockNotHeld
+	}
+
+	_, err := luaRWRelease.Run(ctx, l.client, []string{l.key}, l.field, l.value).Result()
+	if err == redis.Nil {
+		err = ErrLockNotHeld
+	}
+	l.field, l.value = "", ""
+	return err
+}
+
+// 刷新当前持有的读锁或者写锁, ttl为0时使用创建锁时的ttl
+func (l *RedisRWLock) Refresh(ctx context.Context, ttl time.Duration) error {
+	if l.field == "" {
+		return ErrNotObtained
+	}
+	if ttl > 0 {
+		l.ttl = ttl
+	}
+
+	_, err := luaRWRefresh.Run(ctx, l.client, []string{l.key}, l.field, l.value, formatMillis(l.ttl)).Result()
+	if err == redis.Nil {
+		return ErrNotObtained
+	}
+	return err
+}
+
+func formatMillis(d time.Duration) string {
+	return strconv.FormatInt(d.Milliseconds(), 10)
+}
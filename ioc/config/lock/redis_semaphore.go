@@ -0,0 +1,122 @@
+package lock
+
+import (
+	"context"
+	_ "embed"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+//go:embed redis_lua/semaphore_acquire.lua
+var luaSemaphoreAcquireScript string
+
+//go:embed redis_lua/semaphore_release.lua
+var luaSemaphoreReleaseScript string
+
+//go:embed redis_lua/semaphore_refresh.lua
+var luaSemaphoreRefreshScript string
+
+var (
+	luaSemaphoreAcquire = redis.NewScript(luaSemaphoreAcquireScript)
+	luaSemaphoreRelease = redis.NewScript(luaSemaphoreReleaseScript)
+	luaSemaphoreRefresh = redis.NewScript(luaSemaphoreRefreshScript)
+)
+
+func (r *RedisLockProvider) NewSemaphore(key string, n int, ttl time.Duration) Semaphore {
+	return &RedisSemaphore{
+		client: r.getClient(),
+		key:    key,
+		n:      n,
+		ttl:    ttl,
+		opt:    DefaultOptions(),
+	}
+}
+
+// RedisSemaphore 持有者保存在一个有序集合中, score为过期时间, 脚本依赖TIME命令, 需要Redis 5.0及以上版本
+type RedisSemaphore struct {
+	client redis.Scripter
+	key    string
+	n      int
+	ttl    time.Duration
+	opt    *Options
+	value  string
+}
+
+func (s *RedisSemaphore) getTimeout() time.Duration {
+	if s.opt.Timeout > 0 {
+		return s.opt.Timeout
+	}
+	return s.ttl * 3
+}
+
+// 锁配置
+func (s *RedisSemaphore) WithOpt(opt *Options) Semaphore {
+	s.opt = opt
+	return s
+}
+
+// 获取许可
+func (s *RedisSemaphore) Acquire(ctx context.Context) error {
+	return s.acquire(ctx, true)
+}
+
+// 尝试获取许可
+func (s *RedisSemaphore) TryAcquire(ctx context.Context) error {
+	return s.acquire(ctx, false)
+}
+
+func (s *RedisSemaphore) acquire(ctx context.Context, retry bool) error {
+	value, _, err := s.opt.lockValue()
+	if err != nil {
+		return err
+	}
+
+	obtain := func(ctx context.Context) (bool, error) {
+		_, err := luaSemaphoreAcquire.Run(ctx, s.client, []string{s.key}, value, s.n, formatMillis(s.ttl)).Result()
+		if err == redis.Nil {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	if retry {
+		err = obtainWithRetry(ctx, s.opt.getRetryStrategy(), s.getTimeout(), obtain)
+	} else {
+		err = obtainOnce(ctx, obtain)
+	}
+	if err != nil {
+		return err
+	}
+	s.value = value
+	return nil
+}
+
+// 释放许可
+func (s *RedisSemaphore) Release(ctx context.Context) error {
+	if s.value == "" {
+		return ErrLockNotHeld
+	}
+
+	_, err := luaSemaphoreRelease.Run(ctx, s.client, []string{s.key}, s.value).Result()
+	if err == redis.Nil {
+		err = ErrLockNotHeld
+	}
+	s.value = ""
+	return err
+}
+
+// 刷新许可, ttl为0时使用创建时的ttl
+func (s *RedisSemaphore) Refresh(ctx context.Context, ttl time.Duration) error {
+	if s.value == "" {
+		return ErrNotObtained
+	}
+	if ttl > 0 {
+		s.ttl = ttl
+	}
+
+	_, err := luaSemaphoreRefresh.Run(ctx, s.client, []string{s.key}, s.value, formatMillis(s.ttl)).Result()
+	if err == redis.Nil {
+		return ErrNotObtained
+	}
+	return err
+}
@@ -1,6 +1,8 @@
 package lock
 
 import (
+	"context"
+	"errors"
 	"sync/atomic"
 	"time"
 )
@@ -74,3 +76,60 @@ func (r *exponentialBackoff) NextBackoff() time.Duration {
 		return d
 	}
 }
+
+// 按照重试策略不断尝试获取锁, timeout为单次尝试的超时时间
+func obtainWithRetry(ctx context.Context, retry RetryStrategy, timeout time.Duration, obtain func(ctx context.Context) (bool, error)) error {
+	var ticker *time.Ticker
+	for {
+		obtainCtx := ctx
+		cancel := func() {}
+		if timeout > 0 {
+			obtainCtx, cancel = context.WithTimeout(ctx, timeout)
+		}
+
+		ok, err := obtain(obtainCtx)
+		cancel()
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			if timeout > 0 && errors.Is(err, context.DeadlineExceeded) {
+				// Per-attempt timeout should not abort retry loop.
+			} else {
+				return err
+			}
+		} else if ok {
+			return nil
+		}
+
+		backoff := retry.NextBackoff()
+		if backoff < 1 {
+			return ErrNotObtained
+		}
+
+		if ticker == nil {
+			ticker = time.NewTicker(backoff)
+			defer ticker.Stop()
+		} else {
+			ticker.Reset(backoff)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// 只尝试一次, 未获取时返回ErrNotObtained
+func obtainOnce(ctx context.Context, obtain func(ctx context.Context) (bool, error)) error {
+	ok, err := obtain(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotObtained
+	}
+	return nil
+}
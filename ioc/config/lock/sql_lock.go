@@ -0,0 +1,271 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/infraboard/mcube/v2/ioc"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// 保存锁的表
+	SQL_LOCK_TABLE = "mcube_locks"
+)
+
+// NewSqlLockProvider 基于数据库的锁, 锁保存在mcube_locks表中,
+// MySQL/Postgres通过SELECT ... FOR UPDATE行锁保证互斥, SQLite依赖数据库文件级别的写锁
+func NewSqlLockProvider() *SqlLockProvider {
+	return &SqlLockProvider{}
+}
+
+// NewSqlLockProviderWithDB 使用指定的数据库, 而不是ioc中的datasource
+func NewSqlLockProviderWithDB(db *gorm.DB) *SqlLockProvider {
+	return &SqlLockProvider{db: db}
+}
+
+type SqlLockProvider struct {
+	db *gorm.DB
+}
+
+func (s *SqlLockProvider) getDB() *gorm.DB {
+	if s.db != nil {
+		return s.db
+	}
+	return datasourceDB()
+}
+
+// 通过ioc获取datasource的数据库, 不直接引用datasource, 避免使用其他锁时也初始化数据库连接
+func datasourceDB() *gorm.DB {
+	ds, ok := ioc.Config().Get(PROVIDER_SQL).(interface {
+		GetTransactionOrDB(context.Context) *gorm.DB
+	})
+	if !ok {
+		return nil
+	}
+	return ds.GetTransactionOrDB(context.Background())
+}
+
+// Migrate 创建锁表
+func (s *SqlLockProvider) Migrate(ctx context.Context) error {
+	if s.getDB() == nil {
+		return errors.New("lock: datasource not registered, import ioc/config/datasource")
+	}
+	return s.getDB().WithContext(ctx).AutoMigrate(&lockRecord{})
+}
+
+func (s *SqlLockProvider) New(key string, ttl time.Duration) Lock {
+	return &SqlLock{
+		db:  s.getDB(),
+		key: key,
+		ttl: ttl,
+		opt: DefaultOptions(),
+	}
+}
+
+// 锁表的一行记录, 释放或者过期的记录不会被删除, 下次获取锁时直接覆盖, 以保留fencing token计数
+type lockRecord struct {
+	// 锁的名称
+	Key string `gorm:"column:lock_key;type:varchar(255);primaryKey"`
+	// 锁的值, token + metadata
+	Value string `gorm:"column:lock_value;type:varchar(1024);not null"`
+	// 过期时间, unix毫秒
+	ExpiredAt int64 `gorm:"column:expired_at;not null;index"`
+	// 更新时间, unix纳秒, 保证每次更新都有行被修改
+	UpdatedAt int64 `gorm:"column:updated_at;autoUpdateTime:false;not null"`
+	// 每次获取锁加1
+	Fencing int64 `gorm:"column:fencing;not null;default:0"`
+}
+
+func (lockRecord) TableName() string {
+	return SQL_LOCK_TABLE
+}
+
+type SqlLock struct {
+	db       *gorm.DB
+	key      string
+	ttl      time.Duration
+	opt      *Options
+	value    string
+	tokenLen int
+	fencing  int64
+}
+
+func (l *SqlLock) getTimeout() time.Duration {
+	if l.opt.Timeout > 0 {
+		return l.opt.Timeout
+	}
+	return l.ttl * 3
+}
+
+// 锁配置
+func (l *SqlLock) WithOpt(opt *Options) Lock {
+	l.opt = opt
+	return l
+}
+
+// 获取锁
+func (l *SqlLock) Lock(ctx context.Context) error {
+	value, tokenLen, err := l.opt.lockValue()
+	if err != nil {
+		return err
+	}
+
+	return obtainWithRetry(ctx, l.opt.getRetryStrategy(), l.getTimeout(), func(ctx context.Context) (bool, error) {
+		return l.obtain(ctx, value, tokenLen)
+	})
+}
+
+// TryLock
+func (l *SqlLock) TryLock(ctx context.Context) error {
+	value, tokenLen, err := l.opt.lockValue()
+	if err != nil {
+		return err
+	}
+
+	ok, err := l.obtain(ctx, value, tokenLen)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotObtained
+	}
+	return nil
+}
+
+func (l *SqlLock) obtain(ctx context.Context, value string, tokenLen int) (bool, error) {
+	var fencing int64
+	err := l.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		record := &lockRecord{
+			Key:       l.key,
+			Value:     value,
+			ExpiredAt: now.Add(l.ttl).UnixMilli(),
+			UpdatedAt: now.UnixNano(),
+			Fencing:   1,
+		}
+
+		current := &lockRecord{}
+		query := tx
+		if l.db.Dialector.Name() != "sqlite" {
+			query = query.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate})
+		}
+		err := query.Where("lock_key = ?", l.key).Take(current).Error
+
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			// 并发插入时只有一个能成功
+			res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 1 {
+				fencing = record.Fencing
+			}
+			return nil
+		case err != nil:
+			return err
+		}
+
+		// 锁被其他token持有且未过期, 与Redis保持一致, 相同token的锁可以重复获取
+		if current.ExpiredAt > now.UnixMilli() && !strings.HasPrefix(current.Value, value[:tokenLen]) {
+			return nil
+		}
+		res := tx.Model(&lockRecord{}).
+			Where("lock_key = ? AND lock_value = ? AND expired_at = ? AND fencing = ?",
+				l.key, current.Value, current.ExpiredAt, current.Fencing).
+			Updates(map[string]any{
+				"lock_value": record.Value,
+				"expired_at": record.ExpiredAt,
+				"updated_at": record.UpdatedAt,
+				"fencing":    current.Fencing + 1,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 1 {
+			fencing = current.Fencing + 1
+		}
+		return nil
+	})
+	if err != nil || fencing == 0 {
+		return false, err
+	}
+
+	l.value = value
+	l.tokenLen = tokenLen
+	l.fencing = fencing
+	return true, nil
+}
+
+// 释放锁
+func (l *SqlLock) UnLock(ctx context.Context) error {
+	if l.value == "" {
+		return ErrLockNotHeld
+	}
+
+	// 标记为过期而不是删除, 保留fencing token计数
+	now := time.Now()
+	res := l.db.WithContext(ctx).Model(&lockRecord{}).
+		Where("lock_key = ? AND lock_value = ? AND expired_at > ?", l.key, l.value, now.UnixMilli()).
+		Updates(map[string]any{
+			"expired_at": 0,
+			"updated_at": now.UnixNano(),
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrLockNotHeld
+	}
+	l.value = ""
+	return nil
+}
+
+// 刷新锁, ttl为0时使用创建锁时的ttl
+func (l *SqlLock) Refresh(ctx context.Context, ttl time.Duration) error {
+	if l.value == "" {
+		return ErrNotObtained
+	}
+	if ttl > 0 {
+		l.ttl = ttl
+	}
+
+	now := time.Now()
+	res := l.db.WithContext(ctx).Model(&lockRecord{}).
+		Where("lock_key = ? AND lock_value = ? AND expired_at > ?", l.key, l.value, now.UnixMilli()).
+		Updates(map[string]any{
+			"expired_at": now.Add(l.ttl).UnixMilli(),
+			"updated_at": now.UnixNano(),
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotObtained
+	}
+	return nil
+}
+
+// FencingToken returns the fencing token of the current acquisition.
+func (l *SqlLock) FencingToken() int64 {
+	return l.fencing
+}
+
+// Key returns the lock key.
+func (l *SqlLock) Key() string {
+	return l.key
+}
+
+// Token returns the token value set by the lock.
+func (l *SqlLock) Token() string {
+	return l.value[:l.tokenLen]
+}
+
+// Metadata returns the metadata of the lock.
+func (l *SqlLock) Metadata() string {
+	return l.value[l.tokenLen:]
+}
@@ -0,0 +1,198 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/infraboard/mcube/v2/ioc/config/log"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// 默认在ttl的1/3时续期
+	DEFAULT_WATCHDOG_RATIO = 3
+)
+
+var (
+	// ErrLockLost is returned by context.Cause when the watchdog failed to renew the lock.
+	ErrLockLost = errors.New("lock: lock lost")
+)
+
+// LockWithWatchdog 使用默认的锁获取锁, 获取成功后自动续期, 直到UnLock或者续期失败
+//
+//	w, err := lock.LockWithWatchdog(ctx, "job", 30*time.Second)
+//	if err != nil {
+//		return err
+//	}
+//	defer w.UnLock(ctx)
+//
+//	// 锁丢失时Context被取消
+//	doSomething(w.Context(), w.FencingToken())
+func LockWithWatchdog(ctx context.Context, key string, ttl time.Duration) (*Watchdog, error) {
+	w := NewWatchdog(L().New(key, ttl), ttl)
+	if err := w.Lock(ctx); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// NewWatchdog 包装一个锁, 获取锁后每隔ttl/ratio续期一次,
+// 续期返回ErrNotObtained或者超过ttl没有续期成功时认为锁已丢失, 关闭Lost并取消Context
+func NewWatchdog(l Lock, ttl time.Duration) *Watchdog {
+	return &Watchdog{
+		lock:  l,
+		ttl:   ttl,
+		ratio: DEFAULT_WATCHDOG_RATIO,
+		log:   log.Sub(AppName),
+	}
+}
+
+type Watchdog struct {
+	lock  Lock
+	ttl   time.Duration
+	ratio int
+	log   *zerolog.Logger
+
+	mu     sync.Mutex
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	lost   chan struct{}
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// WithRatio 每隔ttl/ratio续期一次, 默认为3
+func (w *Watchdog) WithRatio(ratio int) *Watchdog {
+	if ratio > 1 {
+		w.ratio = ratio
+	}
+	return w
+}
+
+// 锁配置
+func (w *Watchdog) WithOpt(opt *Options) Lock {
+	w.lock.WithOpt(opt)
+	return w
+}
+
+// 获取锁, 成功后开始续期
+func (w *Watchdog) Lock(ctx context.Context) error {
+	if err := w.lock.Lock(ctx); err != nil {
+		return err
+	}
+	w.start(ctx)
+	return nil
+}
+
+// TryLock, 成功后开始续期
+func (w *Watchdog) TryLock(ctx context.Context) error {
+	if err := w.lock.TryLock(ctx); err != nil {
+		return err
+	}
+	w.start(ctx)
+	return nil
+}
+
+// 停止续期并释放锁
+func (w *Watchdog) UnLock(ctx context.Context) error {
+	w.halt(context.Canceled)
+	return w.lock.UnLock(ctx)
+}
+
+// 刷新锁
+func (w *Watchdog) Refresh(ctx context.Context, ttl time.Duration) error {
+	return w.lock.Refresh(ctx, ttl)
+}
+
+// FencingToken 本次获取锁的fencing token
+func (w *Watchdog) FencingToken() int64 {
+	return w.lock.FencingToken()
+}
+
+// Lost 锁丢失时关闭, 正常UnLock时不会关闭
+func (w *Watchdog) Lost() <-chan struct{} {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.lost == nil {
+		w.lost = make(chan struct{})
+	}
+	return w.lost
+}
+
+// Context 持有锁期间有效, 锁丢失或者UnLock后取消, 锁丢失时context.Cause为ErrLockLost
+func (w *Watchdog) Context() context.Context {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.ctx == nil {
+		ctx, cancel := context.WithCancelCause(context.Background())
+		cancel(ErrLockNotHeld)
+		return ctx
+	}
+	return w.ctx
+}
+
+func (w *Watchdog) start(ctx context.Context) {
+	// 上一次获取的锁还在续期时先停止
+	w.halt(context.Canceled)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.ctx, w.cancel = context.WithCancelCause(context.WithoutCancel(ctx))
+	w.lost = make(chan struct{})
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+	go w.renew(w.stop, w.done, w.lost, w.cancel)
+}
+
+// 停止续期并等待续期协程退出
+func (w *Watchdog) halt(cause error) {
+	w.mu.Lock()
+	stop, done, cancel := w.stop, w.done, w.cancel
+	w.stop = nil
+	w.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+	cancel(cause)
+}
+
+func (w *Watchdog) renew(stop, done, lost chan struct{}, cancel context.CancelCauseFunc) {
+	defer close(done)
+
+	interval := w.ttl / time.Duration(w.ratio)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	lastRenewed := time.Now()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		ctx, cancelRefresh := context.WithTimeout(context.Background(), interval)
+		err := w.lock.Refresh(ctx, w.ttl)
+		cancelRefresh()
+		if err == nil {
+			lastRenewed = time.Now()
+			continue
+		}
+
+		// 网络等临时错误在锁过期前继续重试
+		if !errors.Is(err, ErrNotObtained) && time.Since(lastRenewed) < w.ttl {
+			w.log.Warn().Msgf("renew lock error, %s", err)
+			continue
+		}
+
+		w.log.Error().Msgf("lock lost, %s", err)
+		close(lost)
+		cancel(ErrLockLost)
+		return
+	}
+}
@@ -0,0 +1,44 @@
+package lock_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bluele/gcache"
+	"github.com/infraboard/mcube/v2/ioc/config/lock"
+)
+
+func TestWatchdogLost(t *testing.T) {
+	ctx := context.Background()
+	cache := gcache.New(100).LRU().Build()
+	p := lock.NewGoCacheLockProviderWithCache(cache)
+
+	w := lock.NewWatchdog(p.New("watchdog.lost", 300*time.Millisecond), 300*time.Millisecond)
+	if err := w.TryLock(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// 模拟锁被其他持有者抢占
+	cache.Remove("watchdog.lost")
+	other := p.New("watchdog.lost", 10*time.Second)
+	if err := other.TryLock(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-w.Lost():
+	case <-time.After(2 * time.Second):
+		t.Fatal("expect lock lost")
+	}
+	if cause := context.Cause(w.Context()); !errors.Is(cause, lock.ErrLockLost) {
+		t.Fatalf("expect ErrLockLost, got %v", cause)
+	}
+	if other.FencingToken() <= w.FencingToken() {
+		t.Fatalf("expect fencing token of new holder %d greater than %d", other.FencingToken(), w.FencingToken())
+	}
+	if err := w.UnLock(ctx); !errors.Is(err, lock.ErrLockNotHeld) {
+		t.Fatalf("expect ErrLockNotHeld, got %v", err)
+	}
+}
@@ -1,41 +1,276 @@
 package mcron
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
 	"github.com/infraboard/mcube/v2/ioc"
+	"github.com/infraboard/mcube/v2/ioc/config/application"
 	"github.com/infraboard/mcube/v2/ioc/config/log"
 	"github.com/robfig/cron/v3"
 	"github.com/rs/zerolog"
+	"gorm.io/gorm"
 )
 
 func init() {
 	ioc.Default().Registry(defaultConfig)
 }
 
-var defaultConfig = &config{
-	cron: cron.New(cron.WithChain(
+var defaultConfig = New()
+
+// New 定时任务调度器, 单例任务使用ioc中的lock, 执行历史使用ioc中的datasource
+func New() *Scheduler {
+	nop := zerolog.Nop()
+	s := &Scheduler{
+		LockPrefix:       "mcube.cron.",
+		LockTTL:          60,
+		History:          false,
+		AutoMigrate:      true,
+		HistoryRetention: 168,
+		log:              &nop,
+		jobs:             map[string]*Job{},
+	}
+	s.cron = cron.New(cron.WithChain(
 		cron.Recover(&LogWrapper{}),
 		cron.SkipIfStillRunning(&LogWrapper{}),
 	),
 		cron.WithLogger(&LogWrapper{}),
-	),
+	)
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	return s
 }
 
-type config struct {
+type Scheduler struct {
 	cron *cron.Cron
 	ioc.ObjectImpl
 	log *zerolog.Logger
+
+	// 单例任务锁的前缀, 锁的名称为 前缀+任务名称
+	LockPrefix string `json:"lock_prefix" yaml:"lock_prefix" toml:"lock_prefix" env:"LOCK_PREFIX"`
+	// 单例任务锁的过期时间, 单位秒, 执行中自动续期, 持有锁的实例宕机后其他实例最多等待该时间
+	LockTTL int64 `json:"lock_ttl" yaml:"lock_ttl" toml:"lock_ttl" env:"LOCK_TTL"`
+	// 记录执行历史到datasource, 需要同时引入ioc/config/datasource
+	History bool `json:"history" yaml:"history" toml:"history" env:"HISTORY"`
+	// 初始化时自动创建执行历史表
+	AutoMigrate bool `json:"auto_migrate" yaml:"auto_migrate" toml:"auto_migrate" env:"AUTO_MIGRATE"`
+	// 执行历史保留时间, 单位小时, 0表示不清理
+	HistoryRetention int64 `json:"history_retention" yaml:"history_retention" toml:"history_retention" env:"HISTORY_RETENTION"`
+
+	db       *gorm.DB
+	lf       LockFactory
+	instance string
+	once     sync.Once
+
+	mu   sync.RWMutex
+	jobs map[string]*Job
+	// 服务关闭时取消, 所有任务的ctx都派生自它
+	ctx    context.Context
+	cancel context.CancelFunc
+	// 执行中的任务, 与取消一起在runMu下修改, 停止后不再开始新的执行
+	runMu sync.Mutex
+	wg    sync.WaitGroup
 }
 
-func (c *config) Name() string {
+func (s *Scheduler) Name() string {
 	return APP_NAME
 }
 
-func (c *config) Priority() int {
+func (s *Scheduler) Priority() int {
 	return PRIORITY
 }
 
-func (c *config) Init() error {
-	c.log = log.Sub(c.Name())
-	c.cron.Start()
+func (s *Scheduler) Init() error {
+	s.log = log.Sub(s.Name())
+	if s.History && s.getDB() == nil {
+		return fmt.Errorf("cron history requires datasource, import ioc/config/datasource")
+	}
+	if s.History && s.AutoMigrate {
+		if err := s.Migrate(context.Background()); err != nil {
+			return err
+		}
+	}
+	if s.History && s.HistoryRetention > 0 {
+		_, err := s.cron.AddFunc("@hourly", func() {
+			if _, err := s.Cleanup(s.ctx); err != nil {
+				s.log.Error().Msgf("cleanup cron history error, %s", err)
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	s.cron.Start()
 	return nil
 }
+
+// Close 停止调度并取消执行中任务的ctx, 等待任务退出
+func (s *Scheduler) Close(ctx context.Context) {
+	s.Stop(ctx)
+}
+
+// Stop 停止调度并取消执行中任务的ctx, 等待任务退出或者ctx超时
+func (s *Scheduler) Stop(ctx context.Context) {
+	stopped := s.cron.Stop()
+	s.runMu.Lock()
+	s.cancel()
+	s.runMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		<-stopped.Done()
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.log.Warn().Msgf("wait cron jobs exit timeout, %s", ctx.Err())
+	}
+}
+
+// Start 开始调度, 通过ioc使用时在Init中启动
+func (s *Scheduler) Start() {
+	s.cron.Start()
+}
+
+// SetDB 使用指定的数据库记录执行历史, 而不是ioc中的datasource
+func (s *Scheduler) SetDB(db *gorm.DB) *Scheduler {
+	s.db = db
+	return s
+}
+
+// SetLockFactory 单例任务使用指定的锁, 而不是ioc中的lock
+func (s *Scheduler) SetLockFactory(lf LockFactory) *Scheduler {
+	s.lf = lf
+	return s
+}
+
+// SetLogger 设置日志
+func (s *Scheduler) SetLogger(l *zerolog.Logger) *Scheduler {
+	s.log = l
+	return s
+}
+
+func (s *Scheduler) getDB() *gorm.DB {
+	if s.db != nil {
+		return s.db
+	}
+	// 不直接引用datasource, 避免只使用定时任务时也初始化数据库连接
+	ds, ok := ioc.Config().Get(DATASOURCE_APP_NAME).(interface {
+		GetTransactionOrDB(context.Context) *gorm.DB
+	})
+	if !ok {
+		return nil
+	}
+	return ds.GetTransactionOrDB(context.Background())
+}
+
+func (s *Scheduler) getLockFactory() LockFactory {
+	if s.lf != nil {
+		return s.lf
+	}
+	return iocLockFactory()
+}
+
+// 执行任务的实例, 记录在执行历史中
+func (s *Scheduler) getInstance() string {
+	s.once.Do(func() {
+		hostname, _ := os.Hostname()
+		s.instance = fmt.Sprintf("%s@%s", application.Get().GetAppName(), hostname)
+	})
+	return s.instance
+}
+
+// Cron 底层的robfig cron
+func (s *Scheduler) Cron() *cron.Cron {
+	return s.cron
+}
+
+// Register 注册命名任务, 名称重复时返回错误
+func (s *Scheduler) Register(name, spec string, fn JobFunc, opts ...JobOption) (*Job, error) {
+	j := newJob(s, name, spec, fn, opts...)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[name]; ok {
+		return nil, fmt.Errorf("cron job %s already registered", name)
+	}
+	id, err := s.cron.AddJob(spec, j)
+	if err != nil {
+		return nil, fmt.Errorf("add cron job %s error, %w", name, err)
+	}
+	j.entryId = id
+	s.jobs[name] = j
+	return j, nil
+}
+
+// Remove 移除任务, 执行中的任务不受影响
+func (s *Scheduler) Remove(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if j, ok := s.jobs[name]; ok {
+		s.cron.Remove(j.entryId)
+		delete(s.jobs, name)
+	}
+}
+
+// Job 根据名称查询任务, 不存在时返回nil
+func (s *Scheduler) Job(name string) *Job {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.jobs[name]
+}
+
+// Jobs 所有注册的任务, 按照名称排序
+func (s *Scheduler) Jobs() []*Job {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	jobs := make([]*Job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, j)
+	}
+	sort.Slice(jobs, func(i, k int) bool {
+		return jobs[i].Name < jobs[k].Name
+	})
+	return jobs
+}
+
+// Trigger 在后台立即执行一次任务, 不影响调度, 任务在本实例执行中时返回ErrJobRunning
+func (s *Scheduler) Trigger(name string) error {
+	j := s.Job(name)
+	if j == nil {
+		return fmt.Errorf("%w, %s", ErrJobNotFound, name)
+	}
+	if !j.running.CompareAndSwap(false, true) {
+		return fmt.Errorf("%w, %s", ErrJobRunning, name)
+	}
+	if !s.track() {
+		j.running.Store(false)
+		return ErrSchedulerStopped
+	}
+	go func() {
+		defer s.wg.Done()
+		defer j.running.Store(false)
+		j.execute(TRIGGER_MANUAL, time.Now())
+	}()
+	return nil
+}
+
+// 开始一次执行, 已经停止时返回false, 返回true时执行结束后需要调用wg.Done
+func (s *Scheduler) track() bool {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	if s.ctx.Err() != nil {
+		return false
+	}
+	s.wg.Add(1)
+	return true
+}
+
+func (s *Scheduler) lockTTL() time.Duration {
+	return time.Duration(max(s.LockTTL, 1)) * time.Second
+}
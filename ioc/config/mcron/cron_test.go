@@ -1 +1,274 @@
 package mcron_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bluele/gcache"
+	"github.com/glebarez/sqlite"
+	"github.com/infraboard/mcube/v2/ioc"
+	"github.com/infraboard/mcube/v2/ioc/config/lock"
+	"github.com/infraboard/mcube/v2/ioc/config/mcron"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "cron.db")), &gorm.Config{
+		Logger: logger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	return db
+}
+
+func lockFactory(lf lock.LockFactory) mcron.LockFactory {
+	return func(key string, ttl time.Duration) mcron.Lock {
+		return lf.New(key, ttl)
+	}
+}
+
+func newScheduler(t *testing.T, lf lock.LockFactory, db *gorm.DB) *mcron.Scheduler {
+	s := mcron.New().SetLockFactory(lockFactory(lf))
+	if db != nil {
+		s.History = true
+		s.SetDB(db)
+		if err := s.Migrate(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		s.Stop(ctx)
+	})
+	return s
+}
+
+func waitRuns(t *testing.T, s *mcron.Scheduler, job string, n int) []*mcron.Run {
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		runs, err := s.ListRuns(context.Background(), job, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		done := 0
+		for _, r := range runs {
+			if r.Status != mcron.STATUS_RUNNING {
+				done++
+			}
+		}
+		if done >= n {
+			return runs
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("want %d runs of %s, got %d", n, job, done)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestSingleton(t *testing.T) {
+	lf := lock.NewGoCacheLockProviderWithCache(gcache.New(100).Build())
+
+	var (
+		mu    sync.Mutex
+		ticks = map[int64]int{}
+	)
+	job := func(ctx context.Context) error {
+		mu.Lock()
+		ticks[time.Now().Unix()]++
+		mu.Unlock()
+		time.Sleep(200 * time.Millisecond)
+		return nil
+	}
+
+	// 两个实例注册相同的单例任务, 每次调度只有一个实例执行
+	for range 2 {
+		s := newScheduler(t, lf, nil)
+		if _, err := s.Register("singleton", "@every 1s", job, mcron.WithSingleton()); err != nil {
+			t.Fatal(err)
+		}
+		s.Start()
+	}
+	time.Sleep(2500 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(ticks) == 0 {
+		t.Fatal("singleton job not run")
+	}
+	for tick, count := range ticks {
+		if count != 1 {
+			t.Fatalf("tick %d run %d times", tick, count)
+		}
+	}
+}
+
+// lock的ioc对象可以作为默认的锁
+func TestIocLock(t *testing.T) {
+	if _, ok := ioc.Config().Get(lock.AppName).(interface {
+		NewLock(key string, ttl time.Duration) mcron.Lock
+	}); !ok {
+		t.Fatal("lock does not provide mcron.Lock")
+	}
+}
+
+func TestTriggerHistory(t *testing.T) {
+	lf := lock.NewGoCacheLockProviderWithCache(gcache.New(100).Build())
+	s := newScheduler(t, lf, newDB(t))
+
+	release := make(chan struct{})
+	_, err := s.Register("report", "@yearly", func(ctx context.Context) error {
+		<-release
+		return nil
+	}, mcron.WithDescription("daily report"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Register("broken", "@yearly", func(ctx context.Context) error {
+		return errors.New("broken")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Register("report", "@daily", nil); err == nil {
+		t.Fatal("want duplicate job error")
+	}
+
+	if err := s.Trigger("report"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Trigger("report"); !errors.Is(err, mcron.ErrJobRunning) {
+		t.Fatalf("want ErrJobRunning, got %v", err)
+	}
+	if err := s.Trigger("unknown"); !errors.Is(err, mcron.ErrJobNotFound) {
+		t.Fatalf("want ErrJobNotFound, got %v", err)
+	}
+	close(release)
+	if err := s.Trigger("broken"); err != nil {
+		t.Fatal(err)
+	}
+
+	runs := waitRuns(t, s, "report", 1)
+	if runs[0].Status != mcron.STATUS_SUCCESS || runs[0].Trigger != mcron.TRIGGER_MANUAL || runs[0].EndAt < runs[0].StartAt {
+		t.Fatalf("unexpected run %+v", runs[0])
+	}
+	runs = waitRuns(t, s, "broken", 1)
+	if runs[0].Status != mcron.STATUS_FAILED || runs[0].Error != "broken" {
+		t.Fatalf("unexpected run %+v", runs[0])
+	}
+
+	jobs := s.Jobs()
+	if len(jobs) != 2 || jobs[0].Name != "broken" || jobs[1].Description != "daily report" {
+		t.Fatalf("unexpected jobs %v", jobs)
+	}
+	if next := jobs[1].NextN(3); len(next) != 3 || !next[1].After(next[0]) {
+		t.Fatalf("unexpected next runs %v", next)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	lf := lock.NewGoCacheLockProviderWithCache(gcache.New(100).Build())
+	s := newScheduler(t, lf, newDB(t))
+
+	_, err := s.Register("slow", "@yearly", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, mcron.WithTimeout(50*time.Millisecond), mcron.WithSingleton())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Trigger("slow"); err != nil {
+		t.Fatal(err)
+	}
+	runs := waitRuns(t, s, "slow", 1)
+	if runs[0].Status != mcron.STATUS_FAILED || runs[0].Error != context.DeadlineExceeded.Error() {
+		t.Fatalf("unexpected run %+v", runs[0])
+	}
+}
+
+func TestStopCancel(t *testing.T) {
+	lf := lock.NewGoCacheLockProviderWithCache(gcache.New(100).Build())
+	s := mcron.New().SetLockFactory(lockFactory(lf))
+
+	started := make(chan struct{})
+	canceled := make(chan struct{})
+	_, err := s.Register("block", "@yearly", func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		close(canceled)
+		return ctx.Err()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Trigger("block"); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	s.Stop(ctx)
+	select {
+	case <-canceled:
+	default:
+		t.Fatal("job ctx not canceled on stop")
+	}
+	if err := s.Trigger("block"); !errors.Is(err, mcron.ErrSchedulerStopped) {
+		t.Fatalf("want ErrSchedulerStopped, got %v", err)
+	}
+}
+
+func TestStopTriggerRace(t *testing.T) {
+	lf := lock.NewGoCacheLockProviderWithCache(gcache.New(100).Build())
+	s := mcron.New().SetLockFactory(lockFactory(lf))
+
+	var mu sync.Mutex
+	stopped := false
+	_, err := s.Register("fast", "@yearly", func(ctx context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if stopped {
+			t.Error("job started after stop")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 停止与手动触发并发, 停止返回后不再开始新的执行
+	wg := sync.WaitGroup{}
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if err := s.Trigger("fast"); errors.Is(err, mcron.ErrSchedulerStopped) {
+					return
+				}
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	s.Stop(ctx)
+	mu.Lock()
+	stopped = true
+	mu.Unlock()
+	wg.Wait()
+}
@@ -0,0 +1,105 @@
+package mcron
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	STATUS_RUNNING = "running"
+	STATUS_SUCCESS = "success"
+	STATUS_FAILED  = "failed"
+)
+
+// Run 任务的一次执行记录
+type Run struct {
+	// 自增Id
+	Id int64 `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	// 任务名称
+	Job string `gorm:"column:job;type:varchar(255);not null;index" json:"job"`
+	// 触发方式: schedule, manual
+	Trigger string `gorm:"column:trigger_by;type:varchar(32);not null" json:"trigger"`
+	// 执行的实例
+	Instance string `gorm:"column:instance;type:varchar(255)" json:"instance"`
+	// 开始时间, unix毫秒
+	StartAt int64 `gorm:"column:start_at;not null;index" json:"start_at"`
+	// 结束时间, unix毫秒, 0表示还在执行
+	EndAt int64 `gorm:"column:end_at;not null;default:0" json:"end_at"`
+	// 执行状态: running, success, failed
+	Status string `gorm:"column:status;type:varchar(32);not null" json:"status"`
+	// 失败原因
+	Error string `gorm:"column:error;type:text" json:"error"`
+}
+
+func (Run) TableName() string {
+	return HISTORY_TABLE
+}
+
+// Migrate 创建执行历史表
+func (s *Scheduler) Migrate(ctx context.Context) error {
+	return s.getDB().WithContext(ctx).AutoMigrate(&Run{})
+}
+
+// ListRuns 查询任务最近的执行记录, 按照开始时间倒序, job为空时查询所有任务
+func (s *Scheduler) ListRuns(ctx context.Context, job string, limit int) ([]*Run, error) {
+	runs := []*Run{}
+	query := s.getDB().WithContext(ctx).Order("start_at DESC, id DESC").Limit(max(limit, 1))
+	if job != "" {
+		query = query.Where("job = ?", job)
+	}
+	if err := query.Find(&runs).Error; err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// Cleanup 删除超过保留时间的执行记录, 返回删除的数量
+func (s *Scheduler) Cleanup(ctx context.Context) (int64, error) {
+	before := time.Now().Add(-time.Duration(s.HistoryRetention) * time.Hour).UnixMilli()
+	res := s.getDB().WithContext(ctx).
+		Where("end_at > ? AND end_at < ?", 0, before).
+		Delete(&Run{})
+	return res.RowsAffected, res.Error
+}
+
+// 开始执行时写入记录, 未开启执行历史或者写入失败时返回nil
+func (s *Scheduler) begin(j *Job, trigger string) *Run {
+	if !s.History {
+		return nil
+	}
+	r := &Run{
+		Job:      j.Name,
+		Trigger:  trigger,
+		Instance: s.getInstance(),
+		StartAt:  time.Now().UnixMilli(),
+		Status:   STATUS_RUNNING,
+	}
+	// 服务关闭时也需要记录, 不使用任务的ctx
+	if err := s.getDB().Create(r).Error; err != nil {
+		s.log.Warn().Msgf("save cron job %s run error, %s", j.Name, err)
+		return nil
+	}
+	return r
+}
+
+func (s *Scheduler) end(r *Run, cause error) {
+	if r == nil {
+		return
+	}
+	r.EndAt = time.Now().UnixMilli()
+	r.Status = STATUS_SUCCESS
+	if cause != nil {
+		r.Status = STATUS_FAILED
+		r.Error = cause.Error()
+	}
+	err := s.getDB().Model(&Run{}).
+		Where("id = ?", r.Id).
+		Updates(map[string]any{
+			"end_at": r.EndAt,
+			"status": r.Status,
+			"error":  r.Error,
+		}).Error
+	if err != nil {
+		s.log.Warn().Msgf("update cron job %s run %d error, %s", r.Job, r.Id, err)
+	}
+}
@@ -1,6 +1,8 @@
 package mcron
 
 import (
+	"errors"
+
 	"github.com/infraboard/mcube/v2/ioc"
 	"github.com/robfig/cron/v3"
 )
@@ -10,8 +12,36 @@ const (
 	PRIORITY = -199
 )
 
+const (
+	// 执行历史默认保存在datasource配置的数据库
+	DATASOURCE_APP_NAME = "datasource"
+	// 单例任务默认使用lock配置的锁
+	LOCK_APP_NAME = "lock"
+	// 保存任务执行历史的表
+	HISTORY_TABLE = "mcube_cron_runs"
+)
+
+var (
+	// ErrJobNotFound 任务未注册
+	ErrJobNotFound = errors.New("cron: job not found")
+	// ErrJobRunning 任务正在本实例执行
+	ErrJobRunning = errors.New("cron: job is running")
+	// ErrSchedulerStopped 调度器已经停止
+	ErrSchedulerStopped = errors.New("cron: scheduler stopped")
+
+	errLockNotRegistered = errors.New("cron: lock not registered, import ioc/config/lock or use SetLockFactory")
+)
+
 func Get() *cron.Cron {
-	return ioc.Default().Get(APP_NAME).(*config).cron
+	return GetScheduler().cron
+}
+
+func GetScheduler() *Scheduler {
+	obj := ioc.Default().Get(APP_NAME)
+	if obj == nil {
+		return defaultConfig
+	}
+	return obj.(*Scheduler)
 }
 
 func RunAndAddFunc(spec string, cmd func()) (cron.EntryID, error) {
@@ -19,3 +49,17 @@ func RunAndAddFunc(spec string, cmd func()) (cron.EntryID, error) {
 
 	return Get().AddFunc(spec, cmd)
 }
+
+// Register 在默认的调度器上注册命名任务
+//
+//	mcron.Register("report", "@every 1m", func(ctx context.Context) error {
+//		return report(ctx)
+//	}, mcron.WithSingleton(), mcron.WithTimeout(30*time.Second))
+func Register(name, spec string, fn JobFunc, opts ...JobOption) (*Job, error) {
+	return GetScheduler().Register(name, spec, fn, opts...)
+}
+
+// Trigger 立即执行一次默认调度器上的任务
+func Trigger(name string) error {
+	return GetScheduler().Trigger(name)
+}
@@ -0,0 +1,189 @@
+package mcron
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	// 按照调度时间执行
+	TRIGGER_SCHEDULE = "schedule"
+	// 手动触发执行
+	TRIGGER_MANUAL = "manual"
+)
+
+// JobFunc 任务的执行函数, 超时或者服务关闭时ctx被取消
+type JobFunc func(ctx context.Context) error
+
+type JobOption func(*Job)
+
+// WithSingleton 多个实例中同一时刻只有一个实例执行, 每次调度也只执行一次
+func WithSingleton() JobOption {
+	return func(j *Job) {
+		j.Singleton = true
+	}
+}
+
+// WithTimeout 单次执行的超时时间, 超时后取消ctx
+func WithTimeout(timeout time.Duration) JobOption {
+	return func(j *Job) {
+		j.Timeout = timeout
+	}
+}
+
+// WithDescription 任务描述
+func WithDescription(desc string) JobOption {
+	return func(j *Job) {
+		j.Description = desc
+	}
+}
+
+func newJob(s *Scheduler, name, spec string, fn JobFunc, opts ...JobOption) *Job {
+	j := &Job{
+		Name: name,
+		Spec: spec,
+		s:    s,
+		fn:   fn,
+	}
+	for _, opt := range opts {
+		opt(j)
+	}
+	return j
+}
+
+// Job 命名的定时任务
+type Job struct {
+	// 任务名称, 在调度器中唯一
+	Name string `json:"name"`
+	// cron表达式, 支持 @every 1m 与 @daily 等描述符
+	Spec string `json:"spec"`
+	// 任务描述
+	Description string `json:"description"`
+	// 是否为单例任务
+	Singleton bool `json:"singleton"`
+	// 单次执行的超时时间, 0表示不限制
+	Timeout time.Duration `json:"timeout"`
+
+	s       *Scheduler
+	fn      JobFunc
+	entryId cron.EntryID
+	running atomic.Bool
+}
+
+// Running 任务是否正在本实例执行
+func (j *Job) Running() bool {
+	return j.running.Load()
+}
+
+// Prev 上一次调度的时间, 还没有调度过时为零值
+func (j *Job) Prev() time.Time {
+	return j.s.cron.Entry(j.entryId).Prev
+}
+
+// Next 下一次调度的时间, 调度器未启动时为零值
+func (j *Job) Next() time.Time {
+	return j.s.cron.Entry(j.entryId).Next
+}
+
+// NextN 从当前时间开始的后n次调度时间
+func (j *Job) NextN(n int) []time.Time {
+	e := j.s.cron.Entry(j.entryId)
+	if e.Schedule == nil {
+		return nil
+	}
+	next := []time.Time{}
+	t := time.Now()
+	for i := 0; i < n; i++ {
+		t = e.Schedule.Next(t)
+		if t.IsZero() {
+			break
+		}
+		next = append(next, t)
+	}
+	return next
+}
+
+// Run 实现cron.Job, 由调度器按照调度时间调用, 上一次执行还未结束时跳过
+func (j *Job) Run() {
+	if !j.running.CompareAndSwap(false, true) {
+		j.s.log.Info().Msgf("cron job %s is still running, skip", j.Name)
+		return
+	}
+	defer j.running.Store(false)
+
+	if !j.s.track() {
+		return
+	}
+	defer j.s.wg.Done()
+	j.execute(TRIGGER_SCHEDULE, j.Prev())
+}
+
+func (j *Job) execute(trigger string, scheduled time.Time) {
+	ctx, cancel := context.WithCancel(j.s.ctx)
+	defer cancel()
+
+	if j.Singleton {
+		// 同一次调度只由一个实例执行
+		if trigger == TRIGGER_SCHEDULE && !j.claim(ctx, scheduled) {
+			return
+		}
+		// 执行期间持有, 避免与其他实例的手动触发或者未结束的执行重叠, 锁丢失时取消执行
+		key := j.s.LockPrefix + j.Name
+		l := j.s.acquire(ctx, key, cancel)
+		if l == nil {
+			j.s.log.Debug().Msgf("cron job %s is running on other instance, skip", j.Name)
+			return
+		}
+		defer l.release(j.s, key)
+	}
+
+	if j.Timeout > 0 {
+		var timeoutCancel context.CancelFunc
+		ctx, timeoutCancel = context.WithTimeout(ctx, j.Timeout)
+		defer timeoutCancel()
+	}
+
+	r := j.s.begin(j, trigger)
+	err := j.call(ctx)
+	if err != nil {
+		j.s.log.Error().Msgf("cron job %s failed, %s", j.Name, err)
+	}
+	j.s.end(r, err)
+}
+
+// 每个任务一个认领锁, 持有到两次调度间隔的一半, 不释放, 过期后下一次调度可以认领,
+// 各个实例的时钟偏差小于间隔的一半时, 同一次调度只有一个实例认领成功
+func (j *Job) claim(ctx context.Context, scheduled time.Time) bool {
+	if scheduled.IsZero() {
+		scheduled = time.Now().Truncate(time.Second)
+	}
+	ttl := time.Second
+	if e := j.s.cron.Entry(j.entryId); e.Schedule != nil {
+		ttl = max(e.Schedule.Next(scheduled).Sub(scheduled)/2, time.Millisecond)
+	}
+
+	l, err := j.s.newLock(j.s.LockPrefix+j.Name+".claim", ttl)
+	if err != nil {
+		j.s.log.Error().Msgf("claim cron job %s error, %s", j.Name, err)
+		return false
+	}
+	if err := l.TryLock(ctx); err != nil {
+		j.s.log.Debug().Msgf("cron job %s claimed by other instance, %s", j.Name, err)
+		return false
+	}
+	return true
+}
+
+// 任务panic时作为失败记录
+func (j *Job) call(ctx context.Context) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return j.fn(ctx)
+}
@@ -0,0 +1,110 @@
+package mcron
+
+import (
+	"context"
+	"time"
+
+	"github.com/infraboard/mcube/v2/ioc"
+)
+
+// Lock 单例任务使用的锁, lock.Lock实现了该接口,
+// 与lock中config.NewLock的返回值是同一个类型, 修改时需要同时修改
+type Lock = interface {
+	TryLock(ctx context.Context) error
+	UnLock(ctx context.Context) error
+	Refresh(ctx context.Context, ttl time.Duration) error
+}
+
+// LockFactory 创建单例任务使用的锁
+//
+//	lf := lock.NewRedisLockProvider()
+//	s.SetLockFactory(func(key string, ttl time.Duration) mcron.Lock {
+//		return lf.New(key, ttl)
+//	})
+type LockFactory func(key string, ttl time.Duration) Lock
+
+// 通过ioc获取lock的锁, 不直接引用lock, 避免没有单例任务时也初始化锁的提供方
+func iocLockFactory() LockFactory {
+	lf, ok := ioc.Config().Get(LOCK_APP_NAME).(interface {
+		NewLock(key string, ttl time.Duration) Lock
+	})
+	if !ok {
+		return nil
+	}
+	return lf.NewLock
+}
+
+func (s *Scheduler) newLock(key string, ttl time.Duration) (Lock, error) {
+	lf := s.getLockFactory()
+	if lf == nil {
+		return nil, errLockNotRegistered
+	}
+	l := lf(key, ttl)
+	if l == nil {
+		return nil, errLockNotRegistered
+	}
+	return l, nil
+}
+
+// 执行期间持有的锁, 每隔ttl/3续期一次, 超过ttl没有续期成功时认为锁已丢失
+type heldLock struct {
+	Lock
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+// 获取锁并开始续期, 锁丢失时调用lost, 被其他实例持有或者获取失败时返回nil
+func (s *Scheduler) acquire(ctx context.Context, key string, lost func()) *heldLock {
+	l, err := s.newLock(key, s.lockTTL())
+	if err != nil {
+		s.log.Error().Msgf("acquire cron lock %s error, %s", key, err)
+		return nil
+	}
+	if err := l.TryLock(ctx); err != nil {
+		s.log.Debug().Msgf("acquire cron lock %s failed, %s", key, err)
+		return nil
+	}
+
+	ctx, stop := context.WithCancel(ctx)
+	h := &heldLock{Lock: l, stop: stop, done: make(chan struct{})}
+	go h.keepAlive(ctx, s, key, lost)
+	return h
+}
+
+func (h *heldLock) keepAlive(ctx context.Context, s *Scheduler, key string, lost func()) {
+	defer close(h.done)
+
+	ttl := s.lockTTL()
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+	renewed := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := h.Refresh(ctx, ttl); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			s.log.Warn().Msgf("refresh cron lock %s error, %s", key, err)
+			if time.Since(renewed) >= ttl {
+				s.log.Error().Msgf("cron lock %s lost", key)
+				lost()
+				return
+			}
+			continue
+		}
+		renewed = time.Now()
+	}
+}
+
+// 停止续期并释放锁
+func (h *heldLock) release(s *Scheduler, key string) {
+	h.stop()
+	<-h.done
+	if err := h.UnLock(context.Background()); err != nil {
+		s.log.Warn().Msgf("release cron lock %s error, %s", key, err)
+	}
+}
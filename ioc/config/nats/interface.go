@@ -1,8 +1,11 @@
 package nats
 
 import (
+	"context"
+
 	"github.com/infraboard/mcube/v2/ioc"
 	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
 )
 
 const (
@@ -12,3 +15,13 @@ const (
 func Get() *nats.Conn {
 	return ioc.Config().Get(APP_NAME).(*Client).conn
 }
+
+// GetJetStream JetStream客户端, 用于持久化发布与消费
+func GetJetStream() jetstream.JetStream {
+	return ioc.Config().Get(APP_NAME).(*Client).js
+}
+
+// KeyValue 获取KV Bucket, Bucket需要已经存在, 可以通过jetstream.key_values配置声明
+func KeyValue(ctx context.Context, bucket string) (jetstream.KeyValue, error) {
+	return GetJetStream().KeyValue(ctx, bucket)
+}
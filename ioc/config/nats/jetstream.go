@@ -0,0 +1,228 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// JetStream 持久化消息配置, 启动时声明配置中的Stream, Consumer与KV Bucket, 已经存在时按照配置更新
+type JetStream struct {
+	// JetStream Domain, 用于leafnode等多域部署, 默认为空
+	Domain string `toml:"domain" json:"domain" yaml:"domain" env:"DOMAIN"`
+	// 声明的超时时间, 单位秒
+	Timeout int `toml:"timeout" json:"timeout" yaml:"timeout" env:"TIMEOUT"`
+
+	// 需要声明的Stream
+	Streams []*Stream `toml:"streams" json:"streams" yaml:"streams"`
+	// 需要声明的持久化Consumer
+	Consumers []*Consumer `toml:"consumers" json:"consumers" yaml:"consumers"`
+	// 需要声明的KV Bucket
+	KeyValues []*Bucket `toml:"key_values" json:"key_values" yaml:"key_values"`
+}
+
+// Stream 持久化保存一组主题的消息
+type Stream struct {
+	// Stream名称
+	Name string `toml:"name" json:"name" yaml:"name"`
+	// 保存的主题, 支持通配符, 比如 order.>
+	Subjects []string `toml:"subjects" json:"subjects" yaml:"subjects"`
+	// 存储类型: file(默认), memory
+	Storage string `toml:"storage" json:"storage" yaml:"storage"`
+	// 保留策略: limits(默认, 达到限制后删除旧消息), interest(所有Consumer确认后删除), workqueue(任一Consumer确认后删除)
+	Retention string `toml:"retention" json:"retention" yaml:"retention"`
+	// 副本数, 默认1
+	Replicas int `toml:"replicas" json:"replicas" yaml:"replicas"`
+	// 消息保存时间, 单位秒, 0表示不限制
+	MaxAge int64 `toml:"max_age" json:"max_age" yaml:"max_age"`
+	// 最大消息数, 0表示不限制
+	MaxMsgs int64 `toml:"max_msgs" json:"max_msgs" yaml:"max_msgs"`
+	// 最大存储大小, 单位字节, 0表示不限制
+	MaxBytes int64 `toml:"max_bytes" json:"max_bytes" yaml:"max_bytes"`
+	// 消息去重(Nats-Msg-Id)的时间窗口, 单位秒, 0使用服务端默认值(2分钟)
+	Duplicates int64 `toml:"duplicates" json:"duplicates" yaml:"duplicates"`
+}
+
+func (s *Stream) Config() (jetstream.StreamConfig, error) {
+	conf := jetstream.StreamConfig{
+		Name:       s.Name,
+		Subjects:   s.Subjects,
+		Replicas:   s.Replicas,
+		MaxAge:     time.Duration(s.MaxAge) * time.Second,
+		MaxMsgs:    s.MaxMsgs,
+		MaxBytes:   s.MaxBytes,
+		Duplicates: time.Duration(s.Duplicates) * time.Second,
+	}
+	if conf.MaxMsgs == 0 {
+		conf.MaxMsgs = -1
+	}
+	if conf.MaxBytes == 0 {
+		conf.MaxBytes = -1
+	}
+	if err := parseEnum(s.Storage, &conf.Storage); err != nil {
+		return conf, err
+	}
+	if err := parseEnum(s.Retention, &conf.Retention); err != nil {
+		return conf, err
+	}
+	return conf, nil
+}
+
+// Consumer 持久化Consumer, 确认方式为显式确认(explicit),
+// 配置了DeliverSubject时为push Consumer, 否则为pull Consumer
+type Consumer struct {
+	// Consumer所属的Stream
+	Stream string `toml:"stream" json:"stream" yaml:"stream"`
+	// 持久化名称, 不能包含 . * > 与空白字符
+	Durable string `toml:"durable" json:"durable" yaml:"durable"`
+	// 过滤的主题, 默认为Stream的全部主题
+	FilterSubjects []string `toml:"filter_subjects" json:"filter_subjects" yaml:"filter_subjects"`
+	// 开始投递的位置: all(默认), new, last, last_per_subject
+	DeliverPolicy string `toml:"deliver_policy" json:"deliver_policy" yaml:"deliver_policy"`
+	// 确认超时时间, 单位秒, 超时未确认的消息重新投递, 0使用服务端默认值(30秒)
+	AckWait int64 `toml:"ack_wait" json:"ack_wait" yaml:"ack_wait"`
+	// 最大投递次数, 达到后不再投递, 0表示不限制
+	MaxDeliver int `toml:"max_deliver" json:"max_deliver" yaml:"max_deliver"`
+	// 重新投递的退避时间, 单位秒, 设置后代替AckWait, 数量不能超过MaxDeliver
+	BackOff []int64 `toml:"back_off" json:"back_off" yaml:"back_off"`
+	// 最多未确认的消息数, 0使用服务端默认值(1000)
+	MaxAckPending int `toml:"max_ack_pending" json:"max_ack_pending" yaml:"max_ack_pending"`
+	// push Consumer投递消息的主题
+	DeliverSubject string `toml:"deliver_subject" json:"deliver_subject" yaml:"deliver_subject"`
+	// push Consumer的队列组, 组内只有一个订阅收到消息
+	DeliverGroup string `toml:"deliver_group" json:"deliver_group" yaml:"deliver_group"`
+}
+
+// IsPush 是否为push Consumer
+func (c *Consumer) IsPush() bool {
+	return c.DeliverSubject != ""
+}
+
+func (c *Consumer) Config() (jetstream.ConsumerConfig, error) {
+	conf := jetstream.ConsumerConfig{
+		Durable:        c.Durable,
+		AckPolicy:      jetstream.AckExplicitPolicy,
+		AckWait:        time.Duration(c.AckWait) * time.Second,
+		MaxDeliver:     c.MaxDeliver,
+		MaxAckPending:  c.MaxAckPending,
+		DeliverSubject: c.DeliverSubject,
+		DeliverGroup:   c.DeliverGroup,
+	}
+	switch len(c.FilterSubjects) {
+	case 0:
+	case 1:
+		conf.FilterSubject = c.FilterSubjects[0]
+	default:
+		conf.FilterSubjects = c.FilterSubjects
+	}
+	for _, b := range c.BackOff {
+		conf.BackOff = append(conf.BackOff, time.Duration(b)*time.Second)
+	}
+	if err := parseEnum(c.DeliverPolicy, &conf.DeliverPolicy); err != nil {
+		return conf, err
+	}
+	return conf, nil
+}
+
+// Bucket 基于JetStream的KV存储
+type Bucket struct {
+	// Bucket名称
+	Bucket string `toml:"bucket" json:"bucket" yaml:"bucket"`
+	// 每个Key保留的历史版本数, 默认1, 最大64
+	History uint8 `toml:"history" json:"history" yaml:"history"`
+	// Key的过期时间, 单位秒, 0表示不过期
+	TTL int64 `toml:"ttl" json:"ttl" yaml:"ttl"`
+	// 最大存储大小, 单位字节, 0表示不限制
+	MaxBytes int64 `toml:"max_bytes" json:"max_bytes" yaml:"max_bytes"`
+	// 存储类型: file(默认), memory
+	Storage string `toml:"storage" json:"storage" yaml:"storage"`
+	// 副本数, 默认1
+	Replicas int `toml:"replicas" json:"replicas" yaml:"replicas"`
+}
+
+func (k *Bucket) Config() (jetstream.KeyValueConfig, error) {
+	conf := jetstream.KeyValueConfig{
+		Bucket:   k.Bucket,
+		History:  k.History,
+		TTL:      time.Duration(k.TTL) * time.Second,
+		MaxBytes: k.MaxBytes,
+		Replicas: k.Replicas,
+	}
+	if conf.MaxBytes == 0 {
+		conf.MaxBytes = -1
+	}
+	if err := parseEnum(k.Storage, &conf.Storage); err != nil {
+		return conf, err
+	}
+	return conf, nil
+}
+
+func (j *JetStream) timeout() time.Duration {
+	if j.Timeout <= 0 {
+		return 10 * time.Second
+	}
+	return time.Duration(j.Timeout) * time.Second
+}
+
+func (j *JetStream) new(conn *nats.Conn) (jetstream.JetStream, error) {
+	if j.Domain != "" {
+		return jetstream.NewWithDomain(conn, j.Domain)
+	}
+	return jetstream.New(conn)
+}
+
+// 按照Stream, KV Bucket, Consumer的顺序声明, Consumer依赖Stream
+func (j *JetStream) declare(ctx context.Context, js jetstream.JetStream) error {
+	ctx, cancel := context.WithTimeout(ctx, j.timeout())
+	defer cancel()
+
+	for _, s := range j.Streams {
+		conf, err := s.Config()
+		if err != nil {
+			return fmt.Errorf("stream %s config error, %w", s.Name, err)
+		}
+		if _, err := js.CreateOrUpdateStream(ctx, conf); err != nil {
+			return fmt.Errorf("declare stream %s error, %w", s.Name, err)
+		}
+	}
+	for _, k := range j.KeyValues {
+		conf, err := k.Config()
+		if err != nil {
+			return fmt.Errorf("key value %s config error, %w", k.Bucket, err)
+		}
+		if _, err := js.CreateOrUpdateKeyValue(ctx, conf); err != nil {
+			return fmt.Errorf("declare key value %s error, %w", k.Bucket, err)
+		}
+	}
+	for _, c := range j.Consumers {
+		conf, err := c.Config()
+		if err != nil {
+			return fmt.Errorf("consumer %s config error, %w", c.Durable, err)
+		}
+		if c.IsPush() {
+			_, err = js.CreateOrUpdatePushConsumer(ctx, c.Stream, conf)
+		} else {
+			_, err = js.CreateOrUpdateConsumer(ctx, c.Stream, conf)
+		}
+		if err != nil {
+			return fmt.Errorf("declare consumer %s/%s error, %w", c.Stream, c.Durable, err)
+		}
+	}
+	return nil
+}
+
+// 枚举类型使用jetstream的JSON格式解析, 为空时使用默认值
+func parseEnum[T any](value string, v *T) error {
+	if value == "" {
+		return nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/infraboard/mcube/v2/ioc/config/log"
 	"github.com/infraboard/mcube/v2/ioc/config/vault"
 	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
 	"github.com/rs/zerolog"
 )
 
@@ -42,7 +43,11 @@ type Client struct {
 	// VaultTokenField Vault 返回数据中的 Token 字段名，默认 "token"
 	VaultTokenField string `json:"vault_token_field" yaml:"vault_token_field" toml:"vault_token_field" env:"VAULT_TOKEN_FIELD"`
 
+	// JetStream 配置
+	JetStream JetStream `json:"jetstream" yaml:"jetstream" toml:"jetstream" envPrefix:"JETSTREAM_"`
+
 	conn *nats.Conn
+	js   jetstream.JetStream
 }
 
 func (c *Client) Name() string {
@@ -76,6 +81,15 @@ func (c *Client) Init() error {
 		return err
 	}
 	c.conn = conn
+
+	js, err := c.JetStream.new(conn)
+	if err != nil {
+		return err
+	}
+	if err := c.JetStream.declare(context.Background(), js); err != nil {
+		return err
+	}
+	c.js = js
 	return nil
 }
 
@@ -1,12 +1,14 @@
 package nats_test
 
 import (
+	"context"
 	"testing"
 	"time"
 
 	"github.com/infraboard/mcube/v2/ioc"
 	ioc_nats "github.com/infraboard/mcube/v2/ioc/config/nats"
 	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
 )
 
 const (
@@ -42,3 +44,24 @@ func init() {
 		panic(err)
 	}
 }
+
+func TestKeyValue(t *testing.T) {
+	ctx := context.Background()
+	kv, err := ioc_nats.GetJetStream().CreateOrUpdateKeyValue(ctx, jetstream.KeyValueConfig{Bucket: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kv.Put(ctx, "key", []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+
+	kv, err = ioc_nats.KeyValue(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, err := kv.Get(ctx, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(string(entry.Value()))
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/infraboard/mcube/v2/exception"
+	"github.com/spf13/cobra"
+)
+
+var (
+	catalogFormat string
+	catalogOutput string
+)
+
+var errorCodesCmd = &cobra.Command{
+	Use:   "error-codes",
+	Short: "导出应用注册的错误码目录",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var w io.Writer = os.Stdout
+		if catalogOutput != "" {
+			f, err := os.Create(catalogOutput)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+
+		switch catalogFormat {
+		case "json":
+			return exception.WriteCatalogJSON(w)
+		case "markdown", "md":
+			return exception.WriteCatalogMarkdown(w)
+		default:
+			return fmt.Errorf("unsupported format %s, [markdown/json]", catalogFormat)
+		}
+	},
+}
+
+func init() {
+	errorCodesCmd.Flags().StringVarP(&catalogFormat, "format", "o", "markdown", "the catalog format [markdown/json]")
+	errorCodesCmd.Flags().StringVar(&catalogOutput, "output", "", "the catalog output file, default stdout")
+	Root.AddCommand(errorCodesCmd)
+}